@@ -7,8 +7,10 @@ toolchain go1.22.2
 require (
 	github.com/cisco-open/go-lanai v0.14.0
 	github.com/docker/docker v26.1.2+incompatible
+	github.com/docker/go-connections v0.5.0
 	github.com/otiai10/copy v1.14.0
 	github.com/spf13/cobra v1.8.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -19,7 +21,6 @@ require (
 	github.com/cloudflare/circl v1.3.7 // indirect
 	github.com/cyphar/filepath-securejoin v0.2.4 // indirect
 	github.com/distribution/reference v0.6.0 // indirect
-	github.com/docker/go-connections v0.5.0 // indirect
 	github.com/docker/go-units v0.5.0 // indirect
 	github.com/emirpasic/gods v1.18.1 // indirect
 	github.com/felixge/httpsnoop v1.0.4 // indirect
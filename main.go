@@ -3,13 +3,21 @@ package main
 import (
 	"context"
 	"github.com/cisco-open/go-lanai/pkg/log"
+	"github.com/stonedu1011/devenvctl/pkg/devenv"
 	"github.com/stonedu1011/devenvctl/pkg/rootcmd"
 	"github.com/stonedu1011/devenvctl/pkg/rootcmd/debug"
 	"github.com/stonedu1011/devenvctl/pkg/rootcmd/info"
 	"github.com/stonedu1011/devenvctl/pkg/rootcmd/list"
+	"github.com/stonedu1011/devenvctl/pkg/rootcmd/logs"
+	"github.com/stonedu1011/devenvctl/pkg/rootcmd/render"
 	"github.com/stonedu1011/devenvctl/pkg/rootcmd/restart"
+	"github.com/stonedu1011/devenvctl/pkg/rootcmd/schema"
+	"github.com/stonedu1011/devenvctl/pkg/rootcmd/snapshot"
 	"github.com/stonedu1011/devenvctl/pkg/rootcmd/start"
+	"github.com/stonedu1011/devenvctl/pkg/rootcmd/status"
 	"github.com/stonedu1011/devenvctl/pkg/rootcmd/stop"
+	"github.com/stonedu1011/devenvctl/pkg/rootcmd/switchcmd"
+	"github.com/stonedu1011/devenvctl/pkg/rootcmd/validate"
 	"os"
 )
 
@@ -24,10 +32,17 @@ func main() {
 	cmd.AddCommand(start.Cmd)
 	cmd.AddCommand(stop.Cmd)
 	cmd.AddCommand(restart.Cmd)
+	cmd.AddCommand(status.Cmd)
+	cmd.AddCommand(logs.Cmd)
+	cmd.AddCommand(render.Cmd)
+	cmd.AddCommand(validate.Cmd)
+	cmd.AddCommand(schema.Cmd)
+	cmd.AddCommand(switchcmd.Cmd)
+	cmd.AddCommand(snapshot.Cmd)
 	cmd.AddCommand(debug.Cmd)
 
 	if e := cmd.ExecuteContext(context.Background()); e != nil {
-		log.New("CLI").Errorf(`Exited with error: %v`, e)
+		log.New("CLI").Errorf(`Exited with error: %v`, devenv.MaskSecrets(e.Error()))
 		os.Exit(1)
 	}
 }
@@ -0,0 +1,147 @@
+package compose
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var regexVarName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*`)
+
+// Interpolate substitute "$VAR", "${VAR}", "${VAR:-default}", "${VAR-default}", "${VAR:?err}" and "${VAR?err}"
+// in given string, the same way docker compose does. "$$" is an escaped "$".
+func Interpolate(s string, lookup func(name string) (string, bool)) (string, error) {
+	return interpolate(s, lookup, func(string) {})
+}
+
+// UndefinedVariables returns names of variables referenced in given string that are not defined by lookup
+// and don't have default values, i.e. variables docker compose would substitute with empty string or complain about.
+func UndefinedVariables(s string, lookup func(name string) (string, bool)) []string {
+	var names []string
+	_, _ = interpolate(s, lookup, func(name string) {
+		names = append(names, name)
+	})
+	return names
+}
+
+// interpolate substitute variables in given string. missing is invoked with name of each referenced variable
+// that is not defined and has no default value
+func interpolate(s string, lookup func(name string) (string, bool), missing func(name string)) (string, error) {
+	if !strings.Contains(s, "$") {
+		return s, nil
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '$' || i+1 >= len(s) {
+			sb.WriteByte(s[i])
+			continue
+		}
+		switch next := s[i+1]; {
+		case next == '$':
+			sb.WriteByte('$')
+			i++
+		case next == '{':
+			end := closingBrace(s[i+2:])
+			if end < 0 {
+				return "", fmt.Errorf(`invalid interpolation format "%s": missing "}"`, s)
+			}
+			v, e := interpolateExpr(s[i+2:i+2+end], lookup, missing)
+			if e != nil {
+				return "", e
+			}
+			sb.WriteString(v)
+			i += end + 2
+		default:
+			name := regexVarName.FindString(s[i+1:])
+			if len(name) == 0 {
+				sb.WriteByte(s[i])
+				continue
+			}
+			v, ok := lookup(name)
+			if !ok {
+				missing(name)
+			}
+			sb.WriteString(v)
+			i += len(name)
+		}
+	}
+	return sb.String(), nil
+}
+
+// closingBrace returns index of the "}" that closes an expression, i.e. nested expressions like "${A:-${B}}" are skipped.
+// Returns -1 if not found
+func closingBrace(s string) int {
+	depth := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			if depth == 0 {
+				return i
+			}
+			depth--
+		}
+	}
+	return -1
+}
+
+func interpolateExpr(expr string, lookup func(name string) (string, bool), missing func(name string)) (string, error) {
+	name := regexVarName.FindString(expr)
+	if len(name) == 0 {
+		return "", fmt.Errorf(`invalid interpolation format "${%s}"`, expr)
+	}
+	v, ok := lookup(name)
+	op := expr[len(name):]
+	switch {
+	case len(op) == 0:
+		if !ok {
+			missing(name)
+		}
+		return v, nil
+	case strings.HasPrefix(op, ":-"):
+		if !ok || len(v) == 0 {
+			return interpolate(op[2:], lookup, missing)
+		}
+	case strings.HasPrefix(op, "-"):
+		if !ok {
+			return interpolate(op[1:], lookup, missing)
+		}
+	case strings.HasPrefix(op, ":?"):
+		if !ok || len(v) == 0 {
+			missing(name)
+			return "", fmt.Errorf(`required variable [%s] is missing a value: %s`, name, op[2:])
+		}
+	case strings.HasPrefix(op, "?"):
+		if !ok {
+			missing(name)
+			return "", fmt.Errorf(`required variable [%s] is missing a value: %s`, name, op[1:])
+		}
+	default:
+		return "", fmt.Errorf(`invalid interpolation format "${%s}"`, expr)
+	}
+	return v, nil
+}
+
+// interpolateValue recursively interpolate all strings in a value decoded from YAML/JSON
+func interpolateValue(v interface{}, lookup func(name string) (string, bool)) (interface{}, error) {
+	switch val := v.(type) {
+	case string:
+		return Interpolate(val, lookup)
+	case map[string]interface{}:
+		for k := range val {
+			var e error
+			if val[k], e = interpolateValue(val[k], lookup); e != nil {
+				return nil, e
+			}
+		}
+	case []interface{}:
+		for i := range val {
+			var e error
+			if val[i], e = interpolateValue(val[i], lookup); e != nil {
+				return nil, e
+			}
+		}
+	}
+	return v, nil
+}
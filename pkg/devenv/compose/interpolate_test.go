@@ -0,0 +1,131 @@
+package compose
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+var testVars = map[string]string{
+	"NAME":  "db",
+	"EMPTY": "",
+	"PORT":  "5432",
+}
+
+func testLookup(name string) (string, bool) {
+	v, ok := testVars[name]
+	return v, ok
+}
+
+func TestInterpolate(t *testing.T) {
+	tests := []struct {
+		in       string
+		expected string
+		err      bool
+	}{
+		{in: "no variables", expected: "no variables"},
+		{in: "$NAME", expected: "db"},
+		{in: "${NAME}-1", expected: "db-1"},
+		{in: "$NAME-$PORT", expected: "db-5432"},
+		{in: "$$NAME", expected: "$NAME"},
+		{in: "cost $", expected: "cost $"},
+		{in: "$1", expected: "$1"},
+		{in: "${UNDEFINED}", expected: ""},
+		{in: "${UNDEFINED:-default}", expected: "default"},
+		{in: "${EMPTY:-default}", expected: "default"},
+		{in: "${EMPTY-default}", expected: ""},
+		{in: "${UNDEFINED-default}", expected: "default"},
+		{in: "${NAME:-default}", expected: "db"},
+		{in: "${UNDEFINED:-${NAME}}", expected: "db"},
+		{in: "${UNDEFINED:-${MISSING:-${PORT}}}/x", expected: "5432/x"},
+		{in: "${UNDEFINED:-a}${NAME}", expected: "adb"},
+		{in: "${UNDEFINED:-{x}}", expected: "{x}"},
+		{in: "${NAME}}", expected: "db}"},
+		{in: "${NAME:?required}", expected: "db"},
+		{in: "${EMPTY?required}", expected: ""},
+		{in: "${EMPTY:?required}", err: true},
+		{in: "${UNDEFINED?required}", err: true},
+		{in: "${NAME", err: true},
+		{in: "${UNDEFINED:-${NAME}", err: true},
+		{in: "${}", err: true},
+		{in: "${NAME+x}", err: true},
+	}
+	for _, test := range tests {
+		t.Run(test.in, func(t *testing.T) {
+			v, e := Interpolate(test.in, testLookup)
+			switch {
+			case test.err && e == nil:
+				t.Fatalf("expected error, but got %q", v)
+			case !test.err && e != nil:
+				t.Fatalf("unexpected error: %v", e)
+			case v != test.expected:
+				t.Errorf("expected %q, but got %q", test.expected, v)
+			}
+		})
+	}
+}
+
+func TestLoadProjectUnsupported(t *testing.T) {
+	// JSON is valid YAML
+	const content = `{
+		"services": {
+			"db": {
+				"image": "postgres",
+				"env_file": ".env",
+				"x-custom": true,
+				"networks": {"backend": {"aliases": ["pg"], "ipv4_address": "10.0.0.2"}},
+				"volumes": ["./data:/data", {"type": "bind", "source": ".", "target": "/src", "bind": {"create_host_path": true}}],
+				"build": {"context": ".", "ssh": ["default"]}
+			},
+			"app": {"image": "app", "depends_on": {"db": {"condition": "service_healthy"}}}
+		},
+		"networks": {"backend": {}}
+	}`
+	path := filepath.Join(t.TempDir(), "docker-compose.yml")
+	if e := os.WriteFile(path, []byte(content), 0644); e != nil {
+		t.Fatal(e)
+	}
+	p, e := LoadProject("Test", path, testLookup)
+	if e != nil {
+		t.Fatalf("unexpected error: %v", e)
+	}
+	expected := []string{
+		"services.db.build.ssh",
+		"services.db.env_file",
+		"services.db.networks.backend.ipv4_address",
+		"services.db.volumes[1].bind",
+	}
+	if !slices.Equal(p.Unsupported, expected) {
+		t.Errorf("expected %v, but got %v", expected, p.Unsupported)
+	}
+}
+
+func TestUndefinedVariables(t *testing.T) {
+	tests := []struct {
+		in       string
+		expected []string
+	}{
+		{in: "no variables"},
+		{in: "$NAME-${PORT}"},
+		{in: "$$UNDEFINED"},
+		{in: "$UNDEFINED", expected: []string{"UNDEFINED"}},
+		{in: "${UNDEFINED}-$MISSING", expected: []string{"UNDEFINED", "MISSING"}},
+		{in: "${EMPTY}"},
+		{in: "${UNDEFINED:-default}"},
+		{in: "${UNDEFINED-default}"},
+		{in: "${UNDEFINED:-${MISSING}}", expected: []string{"MISSING"}},
+		{in: "${UNDEFINED:-${MISSING:-x}}"},
+		{in: "${NAME:-${MISSING}}"},
+		{in: "${EMPTY:-${MISSING}}", expected: []string{"MISSING"}},
+		{in: "${EMPTY-${MISSING}}"},
+	}
+	for _, test := range tests {
+		t.Run(test.in, func(t *testing.T) {
+			names := UndefinedVariables(test.in, testLookup)
+			if !slices.Equal(names, test.expected) {
+				t.Errorf("expected %v, but got %v", test.expected, names)
+			}
+		})
+	}
+}
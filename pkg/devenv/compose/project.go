@@ -0,0 +1,332 @@
+package compose
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/cisco-open/go-lanai/cmd/lanai-cli/cmdutils"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const (
+	DefaultNetwork = `default`
+)
+
+// Project is the subset of docker compose file that devenvctl can run without docker compose CLI.
+// Unsupported attributes of services are not bound, they are listed in Unsupported.
+type Project struct {
+	// Name project name, normalized to lower case
+	Name string `json:"-"`
+	// WorkingDir the directory of compose file. Relative paths are resolved against it
+	WorkingDir string              `json:"-"`
+	ConfigPath string              `json:"-"`
+	Services   map[string]*Service `json:"services"`
+	Networks   map[string]*Network `json:"networks"`
+	Volumes    map[string]*Volume  `json:"volumes"`
+	// Unsupported attributes of services found in compose file, e.g. "services.db.env_file". Extensions ("x-*") are excluded
+	Unsupported []string `json:"-"`
+}
+
+type Service struct {
+	Name          string          `json:"-"`
+	Image         string          `json:"image"`
+	Build         *Build          `json:"build"`
+	ContainerName string          `json:"container_name"`
+	Hostname      string          `json:"hostname"`
+	Restart       string          `json:"restart"`
+	Command       StringOrList    `json:"command"`
+	Entrypoint    StringOrList    `json:"entrypoint"`
+	Environment   Mapping         `json:"environment"`
+	Labels        Mapping         `json:"labels"`
+	Ports         []Port          `json:"ports"`
+	Expose        []Port          `json:"expose"`
+	Volumes       []ServiceVolume `json:"volumes"`
+	DependsOn     DependsOn       `json:"depends_on"`
+	Networks      ServiceNetworks `json:"networks"`
+	Healthcheck   *Healthcheck    `json:"healthcheck"`
+	WorkingDir    string          `json:"working_dir"`
+	User          string          `json:"user"`
+	ExtraHosts    []string        `json:"extra_hosts"`
+	Privileged    bool            `json:"privileged"`
+	CapAdd        []string        `json:"cap_add"`
+	Tty           bool            `json:"tty"`
+	StdinOpen     bool            `json:"stdin_open"`
+	// Profiles compose profiles of the service. Services with profiles are only started when explicitly requested
+	Profiles []string `json:"profiles"`
+}
+
+type Network struct {
+	Name     string  `json:"name"`
+	Driver   string  `json:"driver"`
+	External bool    `json:"external"`
+	Internal bool    `json:"internal"`
+	Labels   Mapping `json:"labels"`
+	IPAM     *IPAM   `json:"ipam"`
+}
+
+type IPAM struct {
+	Driver string       `json:"driver"`
+	Config []IPAMConfig `json:"config"`
+}
+
+type IPAMConfig struct {
+	Subnet  string `json:"subnet"`
+	IPRange string `json:"ip_range"`
+	Gateway string `json:"gateway"`
+}
+
+type Volume struct {
+	Name       string            `json:"name"`
+	Driver     string            `json:"driver"`
+	DriverOpts map[string]string `json:"driver_opts"`
+	External   bool              `json:"external"`
+	Labels     Mapping           `json:"labels"`
+}
+
+// LoadProject parse given compose file. Variables in values are interpolated using given lookup function.
+func LoadProject(name, path string, lookup func(name string) (string, bool)) (*Project, error) {
+	f, e := os.Open(path)
+	if e != nil {
+		return nil, fmt.Errorf(`unable to open compose file [%s]: %v`, path, e)
+	}
+	defer func() { _ = f.Close() }()
+
+	// interpolate before binding, so that variables can be used for non-string values
+	var raw map[string]interface{}
+	if e := cmdutils.BindYaml(f, &raw); e != nil {
+		return nil, fmt.Errorf(`unable to parse compose file [%s]: %v`, path, e)
+	}
+	interpolated, e := interpolateValue(raw, lookup)
+	if e != nil {
+		return nil, fmt.Errorf(`unable to parse compose file [%s]: %v`, path, e)
+	}
+	data, e := json.Marshal(interpolated)
+	if e != nil {
+		return nil, fmt.Errorf(`unable to parse compose file [%s]: %v`, path, e)
+	}
+	p := Project{
+		Name:       strings.ToLower(name),
+		WorkingDir: filepath.Dir(path),
+		ConfigPath: path,
+	}
+	if e := json.Unmarshal(data, &p); e != nil {
+		return nil, fmt.Errorf(`unable to parse compose file [%s]: %v`, path, e)
+	}
+	if e := p.normalize(lookup); e != nil {
+		return nil, fmt.Errorf(`invalid compose file [%s]: %v`, path, e)
+	}
+	if services, ok := interpolated.(map[string]interface{})["services"]; ok {
+		p.Unsupported = unsupportedAttributes(services, reflect.TypeOf(p.Services), "services")
+		sort.Strings(p.Unsupported)
+	}
+	return &p, nil
+}
+
+// unsupportedAttributes returns paths of keys in given decoded value that cannot be bound to given type.
+// Values in short syntax (e.g. a string for a struct) are not checked.
+func unsupportedAttributes(v interface{}, t reflect.Type, path string) []string {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	var ret []string
+	switch t.Kind() {
+	case reflect.Struct:
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		fields := map[string]reflect.Type{}
+		for i := 0; i < t.NumField(); i++ {
+			name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+			if len(name) != 0 && name != "-" {
+				fields[name] = t.Field(i).Type
+			}
+		}
+		for k, val := range m {
+			switch ft, ok := fields[k]; {
+			case strings.HasPrefix(k, "x-"):
+			case !ok:
+				ret = append(ret, path+"."+k)
+			default:
+				ret = append(ret, unsupportedAttributes(val, ft, path+"."+k)...)
+			}
+		}
+	case reflect.Map:
+		if m, ok := v.(map[string]interface{}); ok {
+			for k, val := range m {
+				ret = append(ret, unsupportedAttributes(val, t.Elem(), path+"."+k)...)
+			}
+		}
+	case reflect.Slice:
+		if items, ok := v.([]interface{}); ok {
+			for i, item := range items {
+				ret = append(ret, unsupportedAttributes(item, t.Elem(), fmt.Sprintf(`%s[%d]`, path, i))...)
+			}
+		}
+	}
+	return ret
+}
+
+// ServiceOrder returns given services and all services they depend on, sorted by dependencies.
+// All services without compose profiles are returned if no name is given, same as "docker compose up" without "--profile".
+func (p *Project) ServiceOrder(names ...string) ([]string, error) {
+	if len(names) == 0 {
+		for k, svc := range p.Services {
+			if len(svc.Profiles) == 0 {
+				names = append(names, k)
+			}
+		}
+	}
+	names = append([]string{}, names...)
+	sort.Strings(names)
+	visited := map[string]bool{}
+	ordered := make([]string, 0, len(p.Services))
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		if done, ok := visited[name]; ok {
+			if !done {
+				return fmt.Errorf(`circular service dependency: %s`, strings.Join(append(path, name), " -> "))
+			}
+			return nil
+		}
+		svc, ok := p.Services[name]
+		if !ok {
+			return fmt.Errorf(`unknown service [%s]`, name)
+		}
+		visited[name] = false
+		for _, dep := range svc.DependsOn {
+			if e := visit(dep, append(path, name)); e != nil {
+				return e
+			}
+		}
+		visited[name] = true
+		ordered = append(ordered, name)
+		return nil
+	}
+	for _, name := range names {
+		if e := visit(name, nil); e != nil {
+			return nil, e
+		}
+	}
+	return ordered, nil
+}
+
+// NetworkName returns the actual name of the network with given key
+func (p *Project) NetworkName(key string) string {
+	if n, ok := p.Networks[key]; ok && len(n.Name) != 0 {
+		return n.Name
+	}
+	return p.Name + "_" + key
+}
+
+// VolumeName returns the actual name of the named volume with given key
+func (p *Project) VolumeName(key string) string {
+	if v, ok := p.Volumes[key]; ok && len(v.Name) != 0 {
+		return v.Name
+	}
+	return p.Name + "_" + key
+}
+
+// ContainerName returns the actual container name of the service
+func (p *Project) ContainerName(service string) string {
+	if s, ok := p.Services[service]; ok && len(s.ContainerName) != 0 {
+		return s.ContainerName
+	}
+	return p.Name + "-" + service + "-1"
+}
+
+// ImageName returns the image of the service. Services that are built without "image" are named "<project>-<service>"
+func (p *Project) ImageName(service string) string {
+	if s, ok := p.Services[service]; ok && len(s.Image) != 0 {
+		return s.Image
+	}
+	return p.Name + "-" + service
+}
+
+func (p *Project) normalize(lookup func(name string) (string, bool)) error {
+	if p.Networks == nil {
+		p.Networks = map[string]*Network{}
+	}
+	if p.Volumes == nil {
+		p.Volumes = map[string]*Volume{}
+	}
+	for k, n := range p.Networks {
+		if n == nil {
+			p.Networks[k] = &Network{}
+		}
+	}
+	for k, v := range p.Volumes {
+		if v == nil {
+			p.Volumes[k] = &Volume{}
+		}
+	}
+	for name, svc := range p.Services {
+		if svc == nil {
+			return fmt.Errorf(`service [%s] is empty`, name)
+		}
+		svc.Name = name
+		// environment variables without value are taken from the lookup, the same as from shell in docker compose
+		for k, v := range svc.Environment {
+			if v != nil {
+				continue
+			}
+			if str, ok := lookup(k); ok {
+				svc.Environment[k] = &str
+			}
+		}
+		if len(svc.Image) == 0 && svc.Build == nil {
+			return fmt.Errorf(`service [%s] has neither "image" nor "build"`, name)
+		}
+		if len(svc.Networks) == 0 {
+			svc.Networks = ServiceNetworks{DefaultNetwork: nil}
+		}
+		for k := range svc.Networks {
+			if _, ok := p.Networks[k]; !ok && k != DefaultNetwork {
+				return fmt.Errorf(`service [%s] refers to undefined network [%s]`, name, k)
+			}
+			if _, ok := p.Networks[k]; !ok {
+				p.Networks[k] = &Network{}
+			}
+		}
+		for i := range svc.Volumes {
+			v := &svc.Volumes[i]
+			switch v.Type {
+			case VolumeTypeBind:
+				v.Source = p.absPath(v.Source)
+			case VolumeTypeVolume:
+				if _, ok := p.Volumes[v.Source]; !ok && len(v.Source) != 0 {
+					return fmt.Errorf(`service [%s] refers to undefined volume [%s]`, name, v.Source)
+				}
+			default:
+				return fmt.Errorf(`service [%s] has unsupported volume type [%s]`, name, v.Type)
+			}
+		}
+		if svc.Build != nil {
+			svc.Build.Context = p.absPath(svc.Build.Context)
+		}
+	}
+	// validate dependencies of all services, including those with compose profiles
+	names := make([]string, 0, len(p.Services))
+	for name := range p.Services {
+		names = append(names, name)
+	}
+	if _, e := p.ServiceOrder(names...); e != nil {
+		return e
+	}
+	return nil
+}
+
+func (p *Project) absPath(path string) string {
+	switch {
+	case strings.HasPrefix(path, "~"):
+		home, _ := os.UserHomeDir()
+		return filepath.Join(home, path[1:])
+	case filepath.IsAbs(path):
+		return filepath.Clean(path)
+	default:
+		return filepath.Join(p.WorkingDir, path)
+	}
+}
@@ -0,0 +1,325 @@
+package compose
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// StringOrList accepts either a string or a list of strings.
+// A string is split into words the same way a shell would, honoring single and double quotes.
+type StringOrList []string
+
+func (v *StringOrList) UnmarshalJSON(data []byte) error {
+	var str string
+	if e := json.Unmarshal(data, &str); e == nil {
+		words, e := splitWords(str)
+		if e != nil {
+			return e
+		}
+		*v = words
+		return nil
+	}
+	var list []string
+	if e := json.Unmarshal(data, &list); e != nil {
+		return fmt.Errorf(`expect string or list of strings, but got %s`, data)
+	}
+	*v = list
+	return nil
+}
+
+// Mapping accepts either a map or a list of "KEY=VALUE". Scalar values are converted to string.
+// Entries without value (null in map form or "KEY" in list form) are kept as nil
+type Mapping map[string]*string
+
+func (v *Mapping) UnmarshalJSON(data []byte) error {
+	*v = Mapping{}
+	var list []string
+	if e := json.Unmarshal(data, &list); e == nil {
+		for _, entry := range list {
+			k, val, ok := strings.Cut(entry, "=")
+			if !ok {
+				(*v)[k] = nil
+				continue
+			}
+			(*v)[k] = &val
+		}
+		return nil
+	}
+	var m map[string]json.RawMessage
+	if e := json.Unmarshal(data, &m); e != nil {
+		return fmt.Errorf(`expect map or list of "KEY=VALUE", but got %s`, data)
+	}
+	for k, raw := range m {
+		if bytes.Equal(raw, []byte("null")) {
+			(*v)[k] = nil
+			continue
+		}
+		str, e := scalarString(raw)
+		if e != nil {
+			return fmt.Errorf(`invalid value of [%s]: %v`, k, e)
+		}
+		(*v)[k] = &str
+	}
+	return nil
+}
+
+// List returns entries with value as "KEY=VALUE", sorted by key
+func (v Mapping) List() []string {
+	ret := make([]string, 0, len(v))
+	for _, k := range v.Keys() {
+		if val := v[k]; val != nil {
+			ret = append(ret, k+"="+*val)
+		}
+	}
+	return ret
+}
+
+// Values returns entries with value as a map
+func (v Mapping) Values() map[string]string {
+	ret := make(map[string]string, len(v))
+	for k, val := range v {
+		if val != nil {
+			ret[k] = *val
+		}
+	}
+	return ret
+}
+
+func (v Mapping) Keys() []string {
+	keys := make([]string, 0, len(v))
+	for k := range v {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// DependsOn accepts either a list of service names or a map keyed by service names
+type DependsOn []string
+
+func (v *DependsOn) UnmarshalJSON(data []byte) error {
+	var list []string
+	if e := json.Unmarshal(data, &list); e == nil {
+		*v = list
+		return nil
+	}
+	var m map[string]json.RawMessage
+	if e := json.Unmarshal(data, &m); e != nil {
+		return fmt.Errorf(`expect list or map of service names, but got %s`, data)
+	}
+	*v = make([]string, 0, len(m))
+	for k := range m {
+		*v = append(*v, k)
+	}
+	sort.Strings(*v)
+	return nil
+}
+
+// Build accepts either the build context as string or an object
+type Build struct {
+	Context    string  `json:"context"`
+	Dockerfile string  `json:"dockerfile"`
+	Args       Mapping `json:"args"`
+	Target     string  `json:"target"`
+}
+
+func (v *Build) UnmarshalJSON(data []byte) error {
+	var str string
+	if e := json.Unmarshal(data, &str); e == nil {
+		*v = Build{Context: str}
+		return nil
+	}
+	type build Build
+	return json.Unmarshal(data, (*build)(v))
+}
+
+// Port accepts either short syntax "[[HOST_IP:]PUBLISHED:]TARGET[/PROTOCOL]" or long syntax.
+// The value is always normalized to short syntax
+type Port string
+
+func (v *Port) UnmarshalJSON(data []byte) error {
+	if str, e := scalarString(data); e == nil {
+		*v = Port(str)
+		return nil
+	}
+	var long struct {
+		Target    json.RawMessage `json:"target"`
+		Published json.RawMessage `json:"published"`
+		HostIP    string          `json:"host_ip"`
+		Protocol  string          `json:"protocol"`
+	}
+	if e := json.Unmarshal(data, &long); e != nil {
+		return fmt.Errorf(`invalid port %s: %v`, data, e)
+	}
+	target, e := scalarString(long.Target)
+	if e != nil {
+		return fmt.Errorf(`invalid port %s: "target" is required`, data)
+	}
+	str := target
+	if published, e := scalarString(long.Published); e == nil && len(published) != 0 {
+		str = published + ":" + str
+		if len(long.HostIP) != 0 {
+			str = long.HostIP + ":" + str
+		}
+	}
+	if len(long.Protocol) != 0 {
+		str = str + "/" + long.Protocol
+	}
+	*v = Port(str)
+	return nil
+}
+
+const (
+	VolumeTypeBind   = "bind"
+	VolumeTypeVolume = "volume"
+)
+
+// ServiceVolume accepts either short syntax "SOURCE:TARGET[:MODE]" or long syntax
+type ServiceVolume struct {
+	Type     string `json:"type"`
+	Source   string `json:"source"`
+	Target   string `json:"target"`
+	ReadOnly bool   `json:"read_only"`
+	// Mode options of short syntax, e.g. "ro", "delegated"
+	Mode string `json:"-"`
+}
+
+func (v *ServiceVolume) UnmarshalJSON(data []byte) error {
+	var str string
+	if e := json.Unmarshal(data, &str); e != nil {
+		type volume ServiceVolume
+		if e := json.Unmarshal(data, (*volume)(v)); e != nil {
+			return fmt.Errorf(`invalid volume %s: %v`, data, e)
+		}
+		if len(v.Type) == 0 {
+			v.Type = VolumeTypeVolume
+		}
+		return nil
+	}
+	parts := strings.Split(str, ":")
+	switch len(parts) {
+	case 1:
+		*v = ServiceVolume{Type: VolumeTypeVolume, Target: parts[0]}
+	case 2, 3:
+		*v = ServiceVolume{Source: parts[0], Target: parts[1]}
+		if len(parts) == 3 {
+			v.Mode = parts[2]
+			v.ReadOnly = strings.Contains(","+v.Mode+",", ",ro,")
+		}
+		v.Type = VolumeTypeVolume
+		if strings.HasPrefix(v.Source, ".") || strings.HasPrefix(v.Source, "/") || strings.HasPrefix(v.Source, "~") {
+			v.Type = VolumeTypeBind
+		}
+	default:
+		return fmt.Errorf(`invalid volume "%s"`, str)
+	}
+	return nil
+}
+
+type Healthcheck struct {
+	Test        StringOrList `json:"test"`
+	Interval    string       `json:"interval"`
+	Timeout     string       `json:"timeout"`
+	StartPeriod string       `json:"start_period"`
+	Retries     int          `json:"retries"`
+	Disable     bool         `json:"disable"`
+}
+
+func (v *Healthcheck) UnmarshalJSON(data []byte) error {
+	type healthcheck Healthcheck
+	if e := json.Unmarshal(data, (*healthcheck)(v)); e != nil {
+		return e
+	}
+	// test in string form is executed by shell
+	var raw struct {
+		Test json.RawMessage `json:"test"`
+	}
+	_ = json.Unmarshal(data, &raw)
+	var str string
+	if e := json.Unmarshal(raw.Test, &str); e == nil {
+		v.Test = []string{"CMD-SHELL", str}
+	}
+	return nil
+}
+
+// ServiceNetworks accepts either a list of network names or a map keyed by network names
+type ServiceNetworks map[string]*ServiceNetwork
+
+type ServiceNetwork struct {
+	Aliases []string `json:"aliases"`
+}
+
+func (v *ServiceNetworks) UnmarshalJSON(data []byte) error {
+	*v = ServiceNetworks{}
+	var list []string
+	if e := json.Unmarshal(data, &list); e == nil {
+		for _, name := range list {
+			(*v)[name] = nil
+		}
+		return nil
+	}
+	var m map[string]*ServiceNetwork
+	if e := json.Unmarshal(data, &m); e != nil {
+		return fmt.Errorf(`expect list or map of network names, but got %s`, data)
+	}
+	for k, n := range m {
+		(*v)[k] = n
+	}
+	return nil
+}
+
+func scalarString(raw json.RawMessage) (string, error) {
+	var v interface{}
+	if e := json.Unmarshal(raw, &v); e != nil {
+		return "", e
+	}
+	switch val := v.(type) {
+	case string:
+		return val, nil
+	case bool:
+		return strconv.FormatBool(val), nil
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64), nil
+	default:
+		return "", fmt.Errorf(`expect a scalar value, but got %s`, raw)
+	}
+}
+
+// splitWords split string into words like a shell would, without any expansion
+func splitWords(s string) ([]string, error) {
+	words := make([]string, 0, 5)
+	var sb strings.Builder
+	var quote rune
+	var inWord bool
+	for _, r := range s {
+		switch {
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+			sb.WriteRune(r)
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				words = append(words, sb.String())
+				sb.Reset()
+				inWord = false
+			}
+		default:
+			sb.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf(`unterminated quote in "%s"`, s)
+	}
+	if inWord {
+		words = append(words, sb.String())
+	}
+	return words, nil
+}
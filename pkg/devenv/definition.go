@@ -0,0 +1,525 @@
+package devenv
+
+import (
+	"encoding/json"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io"
+	"maps"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Position where something is defined in a file. Line and Column are 1-based, 0 means unknown
+type Position struct {
+	Path   string
+	Line   int
+	Column int
+}
+
+func (p Position) String() string {
+	if p.Line == 0 {
+		return p.Path
+	}
+	return fmt.Sprintf(`%s:%d:%d`, p.Path, p.Line, p.Column)
+}
+
+// Problem is an issue found in a profile definition file or in the docker compose file it renders
+type Problem struct {
+	Position
+	Message string
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf(`%v: %s`, p.Position, p.Message)
+}
+
+// Problems is an error reporting multiple problems at once
+type Problems []Problem
+
+func (p Problems) Error() string {
+	lines := make([]string, len(p))
+	for i := range p {
+		lines[i] = p[i].String()
+	}
+	return fmt.Sprintf("%d problem(s) found:\n    - %s", len(p), strings.Join(lines, "\n    - "))
+}
+
+// Sort problems by file and position
+func (p Problems) Sort() {
+	sort.SliceStable(p, func(i, j int) bool {
+		switch {
+		case p[i].Path != p[j].Path:
+			return p[i].Path < p[j].Path
+		case p[i].Line != p[j].Line:
+			return p[i].Line < p[j].Line
+		default:
+			return p[i].Column < p[j].Column
+		}
+	})
+}
+
+// SourceMap positions of services, mounts and hooks in profile definition files.
+// Definitions that are not found are reported at the beginning of the profile's definition file.
+type SourceMap struct {
+	// Path display path of the definition file
+	Path      string
+	positions map[string]Position
+}
+
+func (m *SourceMap) Service(name string) Position {
+	return m.lookup("service/" + name)
+}
+
+func (m *SourceMap) Mount(service, mount string) Position {
+	return m.lookup("mount/" + service + "/" + mount)
+}
+
+func (m *SourceMap) Hook(hook Hook) Position {
+	return m.lookup(hookSourceKey(hook.Phase, hook.Service, hook.Name))
+}
+
+func (m *SourceMap) lookup(key string) Position {
+	if m == nil {
+		return Position{}
+	}
+	if pos, ok := m.positions[key]; ok {
+		return pos
+	}
+	return Position{Path: m.Path}
+}
+
+// mergeSourceMaps combine source maps of parent and child profiles, child's positions take precedence
+func mergeSourceMaps(parent, child *SourceMap) *SourceMap {
+	if parent == nil || child == nil {
+		return child
+	}
+	ret := newSourceMap(child.Path)
+	maps.Copy(ret.positions, parent.positions)
+	maps.Copy(ret.positions, child.positions)
+	return ret
+}
+
+func newSourceMap(path string) *SourceMap {
+	return &SourceMap{
+		Path:      path,
+		positions: map[string]Position{},
+	}
+}
+
+func hookSourceKey(phase HookPhase, service, name string) string {
+	return "hook/" + string(phase) + "/" + service + "/" + name
+}
+
+// hookPhaseKeys keys of hook phases in definition files
+var hookPhaseKeys = map[string]HookPhase{
+	"pre_start":  PhasePreStart,
+	"post_start": PhasePostStart,
+	"pre_stop":   PhasePreStop,
+	"post_stop":  PhasePostStop,
+}
+
+// checkDefinition check given definition file against ProfileSchema: values of wrong kind and unsupported values
+// are reported as Problems. Unknown keys are also reported as Problems if strict, otherwise they are logged as warnings.
+// Returns positions of services, mounts and hooks if no problem is found, or nil if the format version is not supported.
+func checkDefinition(meta *ProfileMetadata, version string, strict bool) (*SourceMap, error) {
+	f, e := meta.FS.Open(meta.Path)
+	if e != nil {
+		return nil, fmt.Errorf(`unable to open profile definition file "%s": %v`, meta.DisplayPath, e)
+	}
+	defer func() { _ = f.Close() }()
+	data, e := io.ReadAll(f)
+	if e != nil {
+		return nil, fmt.Errorf(`unable to read profile definition file "%s": %v`, meta.DisplayPath, e)
+	}
+	var doc yaml.Node
+	if e := yaml.Unmarshal(data, &doc); e != nil {
+		return nil, Problems{{Position: Position{Path: meta.DisplayPath}, Message: e.Error()}}
+	}
+	if len(doc.Content) == 0 {
+		return newSourceMap(meta.DisplayPath), nil
+	}
+	root := resolveAlias(doc.Content[0])
+
+	if len(version) == 0 {
+		version = profileFormats[0].Version
+	}
+	schema := ProfileSchema()
+	format := schema.versionFormat(version)
+	if format == nil {
+		// unsupported version is reported when binding
+		return nil, nil
+	}
+	checker := definitionChecker{schema: schema, path: meta.DisplayPath, strict: strict}
+	checker.check(root, format, "")
+	for _, w := range checker.warnings {
+		logger.Warnf(`%v`, w)
+	}
+	if len(checker.problems) != 0 {
+		checker.problems.Sort()
+		return nil, checker.problems
+	}
+	sm := newSourceMap(meta.DisplayPath)
+	switch version {
+	case FormatV2:
+		indexDefinitionV2(sm, root)
+	default:
+		indexDefinitionV1(sm, root)
+	}
+	return sm, nil
+}
+
+var jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// definitionChecker check YAML nodes of definition files against the subset of JSON Schema generated by ProfileSchema.
+// Like binding YAML via JSON tags, null is accepted as any type, and any scalar is accepted as string
+type definitionChecker struct {
+	schema   *Schema
+	path     string
+	strict   bool
+	problems Problems
+	warnings Problems
+}
+
+func (c *definitionChecker) report(n *yaml.Node, format string, args ...interface{}) {
+	c.problems = append(c.problems, c.problem(n, format, args...))
+}
+
+func (c *definitionChecker) problem(n *yaml.Node, format string, args ...interface{}) Problem {
+	return Problem{
+		Position: Position{Path: c.path, Line: n.Line, Column: n.Column},
+		Message:  fmt.Sprintf(format, args...),
+	}
+}
+
+// check given node against given schema, the same way it would be bound via JSON tags
+func (c *definitionChecker) check(n *yaml.Node, s *Schema, path string) {
+	n = resolveAlias(n)
+	s = c.schema.resolve(s)
+	if s == nil || n.Kind == yaml.ScalarNode && n.Tag == "!!null" {
+		return
+	}
+	for _, sub := range s.AllOf {
+		c.check(n, sub, path)
+	}
+	if len(s.OneOf) != 0 {
+		// branches of generated schemas differ in kind of values
+		descs := make([]string, len(s.OneOf))
+		for i, sub := range s.OneOf {
+			sub = c.schema.resolve(sub)
+			if matchesKind(n, sub) {
+				c.check(n, sub, path)
+				return
+			}
+			descs[i] = describeKind(sub)
+		}
+		c.report(n, `"%s" should be %s, but got %s`, displayKey(path), strings.Join(descs, " or "), nodeKind(n))
+		return
+	}
+	if len(s.Type) != 0 && !matchesKind(n, s) {
+		c.report(n, `"%s" should be %s, but got %s`, displayKey(path), describeKind(s), nodeKind(n))
+		return
+	}
+	switch s.Type {
+	case "object":
+		for _, pair := range mappingPairs(n) {
+			name := pair[0].Value
+			if prop, ok := lookupProperty(s.Properties, name); ok {
+				c.check(pair[1], prop, joinKey(path, name))
+				continue
+			}
+			if s.AdditionalProperties == nil || !s.AdditionalProperties.deny {
+				c.check(pair[1], s.AdditionalProperties, joinKey(path, name))
+				continue
+			}
+			p := c.problem(pair[0], `unknown field "%s"%s`, joinKey(path, name), suggestion(name, s.Properties))
+			if c.strict {
+				c.problems = append(c.problems, p)
+			} else {
+				c.warnings = append(c.warnings, p)
+			}
+		}
+	case "array":
+		for i, item := range n.Content {
+			c.check(item, s.Items, fmt.Sprintf(`%s[%d]`, path, i))
+		}
+	}
+	if len(s.Enum) != 0 && n.Kind == yaml.ScalarNode {
+		values := make([]string, 0, len(s.Enum))
+		for _, value := range s.Enum {
+			if fmt.Sprint(value) == n.Value {
+				return
+			}
+			values = append(values, fmt.Sprint(value))
+		}
+		c.report(n, `"%s" should be one of [%s], but got "%s"`, displayKey(path), strings.Join(values, ", "), n.Value)
+	}
+}
+
+// matchesKind returns true if the node is of the kind required by the schema's type
+func matchesKind(n *yaml.Node, s *Schema) bool {
+	switch s.Type {
+	case "object":
+		return n.Kind == yaml.MappingNode
+	case "array":
+		return n.Kind == yaml.SequenceNode
+	case "boolean":
+		return n.Kind == yaml.ScalarNode && n.Tag == "!!bool"
+	case "integer":
+		return n.Kind == yaml.ScalarNode && n.Tag == "!!int"
+	case "number":
+		return n.Kind == yaml.ScalarNode && (n.Tag == "!!int" || n.Tag == "!!float")
+	case "string":
+		return n.Kind == yaml.ScalarNode
+	default:
+		return true
+	}
+}
+
+func describeKind(s *Schema) string {
+	switch s.Type {
+	case "object":
+		return "a mapping"
+	case "array":
+		return "a list"
+	case "boolean":
+		return "true or false"
+	case "integer":
+		return "an integer"
+	case "number":
+		return "a number"
+	case "string":
+		return "a single value"
+	default:
+		return "any value"
+	}
+}
+
+// lookupProperty find property by key, case-insensitively if there is no exact match, same as encoding/json
+func lookupProperty(props map[string]*Schema, key string) (*Schema, bool) {
+	if prop, ok := props[key]; ok {
+		return prop, true
+	}
+	for name, prop := range props {
+		if strings.EqualFold(name, key) {
+			return prop, true
+		}
+	}
+	return nil, false
+}
+
+// suggestion returns a hint of the closest known property, if any is similar to given name
+func suggestion(name string, props map[string]*Schema) string {
+	var closest string
+	minDist := len(name)/3 + 1
+	for k := range props {
+		if d := editDistance(name, k); d < minDist || d == minDist && k < closest {
+			closest, minDist = k, d
+		}
+	}
+	if len(closest) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(`, did you mean "%s"?`, closest)
+}
+
+// editDistance Levenshtein distance between given strings
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+// jsonFields returns JSON tagged fields of given struct type, including fields of embedded structs
+func jsonFields(t reflect.Type) map[string]reflect.StructField {
+	fields := map[string]reflect.StructField{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		name, _, _ := strings.Cut(tag, ",")
+		switch {
+		case name == "-":
+			continue
+		case f.Anonymous && len(tag) == 0 && f.Type.Kind() == reflect.Struct:
+			for k, v := range jsonFields(f.Type) {
+				fields[k] = v
+			}
+			continue
+		case !f.IsExported():
+			continue
+		case len(name) == 0:
+			name = f.Name
+		}
+		fields[name] = f
+	}
+	return fields
+}
+
+// indexDefinitionV1 record positions of services, mounts and hooks of a v1 definition
+func indexDefinitionV1(sm *SourceMap, root *yaml.Node) {
+	for _, pair := range mappingPairs(root) {
+		if phase, ok := hookPhaseKeys[pair[0].Value]; ok {
+			indexHooks(sm, resolveAlias(pair[1]), phase, "")
+			continue
+		}
+		if pair[0].Value != "services" || resolveAlias(pair[1]).Kind != yaml.SequenceNode {
+			continue
+		}
+		for _, item := range resolveAlias(pair[1]).Content {
+			item = resolveAlias(item)
+			name := mappingValue(item, "service")
+			if name == nil {
+				continue
+			}
+			sm.positions["service/"+name.Value] = nodePosition(sm.Path, item)
+			indexMounts(sm, mappingValue(item, "mounts"), name.Value)
+		}
+	}
+}
+
+// indexDefinitionV2 record positions of services, mounts and hooks of a v2 definition
+func indexDefinitionV2(sm *SourceMap, root *yaml.Node) {
+	if hooks := mappingValue(root, "hooks"); hooks != nil {
+		indexPhases(sm, hooks, "")
+	}
+	services := mappingValue(root, "services")
+	if services == nil || services.Kind != yaml.MappingNode {
+		return
+	}
+	for _, pair := range mappingPairs(services) {
+		name := pair[0].Value
+		sm.positions["service/"+name] = nodePosition(sm.Path, pair[0])
+		svc := resolveAlias(pair[1])
+		indexMounts(sm, mappingValue(svc, "mounts"), name)
+		if hooks := mappingValue(svc, "hooks"); hooks != nil {
+			indexPhases(sm, hooks, name)
+		}
+	}
+}
+
+func indexPhases(sm *SourceMap, hooks *yaml.Node, service string) {
+	for _, pair := range mappingPairs(hooks) {
+		if phase, ok := hookPhaseKeys[pair[0].Value]; ok {
+			indexHooks(sm, resolveAlias(pair[1]), phase, service)
+		}
+	}
+}
+
+// indexHooks record positions of hooks in given list. Hooks are identified by their names, which are resolved the same way as HookV2
+func indexHooks(sm *SourceMap, list *yaml.Node, phase HookPhase, service string) {
+	if list.Kind != yaml.SequenceNode {
+		return
+	}
+	for _, item := range list.Content {
+		var raw interface{}
+		if e := resolveAlias(item).Decode(&raw); e != nil {
+			continue
+		}
+		data, e := json.Marshal(raw)
+		if e != nil {
+			continue
+		}
+		var h HookV2
+		if e := json.Unmarshal(data, &h); e != nil {
+			continue
+		}
+		if hook, e := h.toHook(phase, service); e == nil {
+			sm.positions[hookSourceKey(phase, service, hook.Name)] = nodePosition(sm.Path, item)
+		}
+	}
+}
+
+func indexMounts(sm *SourceMap, mounts *yaml.Node, service string) {
+	if mounts == nil || mounts.Kind != yaml.SequenceNode {
+		return
+	}
+	for _, item := range mounts.Content {
+		sm.positions["mount/"+service+"/"+resolveAlias(item).Value] = nodePosition(sm.Path, item)
+	}
+}
+
+// mappingPairs returns key-value pairs of a mapping node, with merge keys ("<<") expanded
+func mappingPairs(n *yaml.Node) [][2]*yaml.Node {
+	n = resolveAlias(n)
+	if n.Kind != yaml.MappingNode {
+		return nil
+	}
+	pairs := make([][2]*yaml.Node, 0, len(n.Content)/2)
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		key, value := n.Content[i], n.Content[i+1]
+		if key.Tag != "!!merge" {
+			pairs = append(pairs, [2]*yaml.Node{key, value})
+			continue
+		}
+		value = resolveAlias(value)
+		if value.Kind == yaml.SequenceNode {
+			for _, merged := range value.Content {
+				pairs = append(pairs, mappingPairs(merged)...)
+			}
+		} else {
+			pairs = append(pairs, mappingPairs(value)...)
+		}
+	}
+	return pairs
+}
+
+// mappingValue returns value of given key in a mapping node, or nil if not found
+func mappingValue(n *yaml.Node, key string) *yaml.Node {
+	for _, pair := range mappingPairs(n) {
+		if pair[0].Value == key {
+			return resolveAlias(pair[1])
+		}
+	}
+	return nil
+}
+
+func resolveAlias(n *yaml.Node) *yaml.Node {
+	for n.Kind == yaml.AliasNode && n.Alias != nil {
+		n = n.Alias
+	}
+	return n
+}
+
+func nodePosition(path string, n *yaml.Node) Position {
+	return Position{Path: path, Line: n.Line, Column: n.Column}
+}
+
+func nodeKind(n *yaml.Node) string {
+	switch n.Kind {
+	case yaml.MappingNode:
+		return "a mapping"
+	case yaml.SequenceNode:
+		return "a list"
+	default:
+		return fmt.Sprintf(`"%s"`, n.Value)
+	}
+}
+
+func joinKey(path, key string) string {
+	if len(path) == 0 {
+		return key
+	}
+	return path + "." + key
+}
+
+func displayKey(path string) string {
+	if len(path) == 0 {
+		return "(root)"
+	}
+	return path
+}
@@ -0,0 +1,194 @@
+package devenv
+
+import (
+	"errors"
+	"testing"
+	"testing/fstest"
+)
+
+const testDefinitionV1 = `services:
+  - service: db
+    mounts:
+      - data
+      - logs
+  - service: app
+pre_start:
+  - init.sh
+post_start:
+  - migrate
+`
+
+const testDefinitionV2 = `version: 2
+hooks:
+  pre_start:
+    - init.sh
+services:
+  db:
+    mounts:
+      - data
+    hooks:
+      post_start:
+        - name: seed
+          run: echo seed
+        - container: migrate
+  app:
+    depends_on: [db]
+`
+
+func testMetadata(content string) *ProfileMetadata {
+	return &ProfileMetadata{
+		FS:          fstest.MapFS{"devenv-test.yml": {Data: []byte(content)}},
+		Name:        "test",
+		Path:        "devenv-test.yml",
+		DisplayPath: "/profiles/devenv-test.yml",
+	}
+}
+
+func TestCheckDefinitionPositions(t *testing.T) {
+	const path = "/profiles/devenv-test.yml"
+	tests := []struct {
+		name     string
+		content  string
+		version  string
+		lookup   func(sm *SourceMap) Position
+		expected Position
+	}{
+		{name: "v1 service", content: testDefinitionV1, version: FormatV1,
+			lookup: func(sm *SourceMap) Position { return sm.Service("app") }, expected: Position{path, 6, 5}},
+		{name: "v1 mount", content: testDefinitionV1, version: FormatV1,
+			lookup: func(sm *SourceMap) Position { return sm.Mount("db", "logs") }, expected: Position{path, 5, 9}},
+		{name: "v1 hook", content: testDefinitionV1, version: FormatV1,
+			lookup:   func(sm *SourceMap) Position { return sm.Hook(Hook{Phase: PhasePostStart, Name: "migrate"}) },
+			expected: Position{path, 10, 5}},
+		{name: "v1 without version", content: testDefinitionV1,
+			lookup: func(sm *SourceMap) Position { return sm.Service("db") }, expected: Position{path, 2, 5}},
+		{name: "v2 service", content: testDefinitionV2, version: FormatV2,
+			lookup: func(sm *SourceMap) Position { return sm.Service("app") }, expected: Position{path, 14, 3}},
+		{name: "v2 mount", content: testDefinitionV2, version: FormatV2,
+			lookup: func(sm *SourceMap) Position { return sm.Mount("db", "data") }, expected: Position{path, 8, 9}},
+		{name: "v2 profile hook", content: testDefinitionV2, version: FormatV2,
+			lookup:   func(sm *SourceMap) Position { return sm.Hook(Hook{Phase: PhasePreStart, Name: "init.sh"}) },
+			expected: Position{path, 4, 7}},
+		{name: "v2 named service hook", content: testDefinitionV2, version: FormatV2,
+			lookup:   func(sm *SourceMap) Position { return sm.Hook(Hook{Phase: PhasePostStart, Service: "db", Name: "seed"}) },
+			expected: Position{path, 11, 11}},
+		{name: "v2 unnamed service hook", content: testDefinitionV2, version: FormatV2,
+			lookup: func(sm *SourceMap) Position {
+				return sm.Hook(Hook{Phase: PhasePostStart, Service: "db", Name: "migrate"})
+			},
+			expected: Position{path, 13, 11}},
+		{name: "not found", content: testDefinitionV2, version: FormatV2,
+			lookup: func(sm *SourceMap) Position { return sm.Service("unknown") }, expected: Position{Path: path}},
+		{name: "empty file", content: "",
+			lookup: func(sm *SourceMap) Position { return sm.Service("db") }, expected: Position{Path: path}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sm, e := checkDefinition(testMetadata(test.content), test.version, true)
+			if e != nil {
+				t.Fatalf("unexpected error: %v", e)
+			}
+			if pos := test.lookup(sm); pos != test.expected {
+				t.Errorf("expected %v, but got %v", test.expected, pos)
+			}
+		})
+	}
+}
+
+func TestCheckDefinitionProblems(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		version  string
+		expected []string
+	}{
+		{name: "services not a list", version: FormatV1,
+			content:  "services:\n  db: {}\n",
+			expected: []string{`/profiles/devenv-test.yml:2:3: "services" should be a list, but got a mapping`}},
+		{name: "mounts not a list", version: FormatV2,
+			content:  "version: 2\nservices:\n  db:\n    mounts: data\n",
+			expected: []string{`/profiles/devenv-test.yml:4:13: "services.db.mounts" should be a list, but got "data"`}},
+		{name: "sorted by position", version: FormatV2,
+			content: "version: 2\nservices:\n  db:\n    readiness: tcp\n  app:\n    depends_on: db\n",
+			expected: []string{
+				`/profiles/devenv-test.yml:4:16: "services.db.readiness" should be a mapping, but got "tcp"`,
+				`/profiles/devenv-test.yml:6:17: "services.app.depends_on" should be a list, but got "db"`,
+			}},
+		{name: "invalid yaml", version: FormatV1,
+			content:  "services: [\n",
+			expected: []string{`/profiles/devenv-test.yml: yaml: line 1: did not find expected node content`}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sm, e := checkDefinition(testMetadata(test.content), test.version, true)
+			var problems Problems
+			if !errors.As(e, &problems) {
+				t.Fatalf("expected problems, but got source map %v and error %v", sm, e)
+			}
+			if len(problems) != len(test.expected) {
+				t.Fatalf("expected %d problem(s), but got %v", len(test.expected), problems)
+			}
+			for i := range problems {
+				if problems[i].String() != test.expected[i] {
+					t.Errorf("expected %q, but got %q", test.expected[i], problems[i].String())
+				}
+			}
+		})
+	}
+}
+
+func TestCheckDefinitionSchema(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		version  string
+		strict   bool
+		expected []string
+	}{
+		{name: "unknown field", version: FormatV2, strict: true,
+			content:  "version: 2\nservices:\n  db:\n    build_arg: {}\n",
+			expected: []string{`/profiles/devenv-test.yml:4:5: unknown field "services.db.build_arg", did you mean "build_args"?`}},
+		{name: "unknown field without suggestion", version: FormatV1, strict: true,
+			content:  "services: []\ncompletely_different: true\n",
+			expected: []string{`/profiles/devenv-test.yml:2:1: unknown field "completely_different"`}},
+		{name: "unknown field not strict", version: FormatV2,
+			content: "version: 2\nservices:\n  db:\n    build_arg: {}\n"},
+		{name: "case insensitive field", version: FormatV2, strict: true,
+			content: "version: 2\nDisplay_Name: Test\n"},
+		{name: "unknown version", version: "3", strict: true,
+			content: "version: 3\nunknown: true\n"},
+		{name: "unsupported value", version: FormatV2,
+			content:  "version: 2\nprune: all\n",
+			expected: []string{`/profiles/devenv-test.yml:2:8: "prune" should be one of [none, profile, global], but got "all"`}},
+		{name: "wrong kind", version: FormatV2,
+			content: "version: 2\nservices:\n  db:\n    hooks:\n      pre_start:\n        - name: a\n          retries: many\n          continue_on_error: 1\n",
+			expected: []string{
+				`/profiles/devenv-test.yml:7:20: "services.db.hooks.pre_start[0].retries" should be an integer, but got "many"`,
+				`/profiles/devenv-test.yml:8:30: "services.db.hooks.pre_start[0].continue_on_error" should be true or false, but got "1"`,
+			}},
+		{name: "hook as string or mapping", version: FormatV2,
+			content:  "version: 2\nhooks:\n  pre_start:\n    - init.sh\n    - script: seed.sh\n    - [init.sh]\n",
+			expected: []string{`/profiles/devenv-test.yml:6:7: "hooks.pre_start[2]" should be a single value or a mapping, but got a list`}},
+		{name: "strings accept any scalar", version: FormatV1,
+			content: "services:\n  - service: db\n    build_args:\n      version: 2.13\n      enabled: true\n"},
+		{name: "null accepted", version: FormatV2,
+			content: "version: 2\nservices:\n  db:\n    mounts:\n    hooks: ~\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, e := checkDefinition(testMetadata(test.content), test.version, test.strict)
+			var problems Problems
+			if e != nil && !errors.As(e, &problems) {
+				t.Fatalf("unexpected error: %v", e)
+			}
+			if len(problems) != len(test.expected) {
+				t.Fatalf("expected %d problem(s), but got %v", len(test.expected), problems)
+			}
+			for i := range problems {
+				if problems[i].String() != test.expected[i] {
+					t.Errorf("expected %q, but got %q", test.expected[i], problems[i].String())
+				}
+			}
+		})
+	}
+}
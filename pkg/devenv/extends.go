@@ -0,0 +1,175 @@
+package devenv
+
+import (
+	"fmt"
+	lanaiutils "github.com/cisco-open/go-lanai/pkg/utils"
+	"io/fs"
+	"maps"
+)
+
+// ProfileInheritance is the part of profile definition that controls how a profile inherits another profile
+type ProfileInheritance struct {
+	// Extends name of parent profile
+	Extends string `json:"extends" desc:"name of the parent profile"`
+	// Remove services and hooks inherited from parent profile
+	Remove ProfileRemoval `json:"remove" desc:"services and hooks to remove from the parent profile"`
+}
+
+type ProfileRemoval struct {
+	// Services names of services to remove
+	Services []string `json:"services" desc:"names of services to remove"`
+	// Hooks names of hooks to remove, both profile-level and service-level hooks are affected
+	Hooks []string `json:"hooks" desc:"names of hooks to remove, both profile-level and service-level hooks are affected"`
+}
+
+// MergeProfile merge child profile into parent profile and returns a new Profile. Merging rules:
+//   - Metadata (name, definition file, local data dir) are from child.
+//   - Display name, engine, prune policy and hook timeout are from child, if set. Otherwise, parent's are used.
+//   - Compose template and resource directory are from child, if exist. Otherwise, parent's are used.
+//   - Services with same name are merged field by field. See mergeService.
+//   - Default values of variables and secrets are merged, child's definitions take precedence.
+//   - Profile-level hooks of child are appended to parent's.
+//   - Services and hooks listed in child's "remove" are removed from parent before merging.
+func MergeProfile(parent, child *Profile) (*Profile, error) {
+	removedSvcs := lanaiutils.NewStringSet(child.Remove.Services...)
+	removedHooks := lanaiutils.NewStringSet(child.Remove.Hooks...)
+	for name := range removedSvcs {
+		if _, ok := parent.Services[name]; !ok {
+			return nil, fmt.Errorf(`cannot remove service [%s]: not defined in parent profile`, name)
+		}
+	}
+
+	ret := Profile{
+		ProfileMetadata:    child.ProfileMetadata,
+		ProfileInheritance: child.ProfileInheritance,
+		DisplayName:        child.DisplayName,
+		Engine:             child.Engine,
+		Prune:              child.Prune,
+		HookTimeout:        child.HookTimeout,
+		Variables:          mergeMap(parent.Variables, child.Variables),
+		Secrets:            mergeSecrets(parent.Secrets, child.Secrets),
+		Services:           map[string]Service{},
+		Hooks:              Hooks{},
+	}
+	if len(ret.DisplayName) == 0 {
+		ret.DisplayName = parent.DisplayName
+	}
+	if len(ret.Engine) == 0 {
+		ret.Engine = parent.Engine
+	}
+	if len(ret.Prune) == 0 {
+		ret.Prune = parent.Prune
+	}
+	if ret.HookTimeout == 0 {
+		ret.HookTimeout = parent.HookTimeout
+	}
+	if !existsInFS(child.ComposeFS, child.ComposePath) {
+		ret.ComposeFS = parent.ComposeFS
+		ret.ComposePath = parent.ComposePath
+	}
+	if !existsInFS(child.ResourceFS, child.ResourceDir) {
+		ret.ResourceFS = parent.ResourceFS
+		ret.ResourceDir = parent.ResourceDir
+	}
+
+	// services
+	for name, s := range parent.Services {
+		if removedSvcs.Has(name) {
+			continue
+		}
+		s.Hooks = removeHooks(s.Hooks, removedHooks)
+		ret.Services[name] = s
+	}
+	for name, s := range child.Services {
+		if existing, ok := ret.Services[name]; ok {
+			s = mergeService(existing, s)
+		}
+		ret.Services[name] = s
+	}
+
+	// profile-level hooks
+	parentHooks := removeHooks(parent.Hooks, removedHooks)
+	for _, phase := range []HookPhase{PhasePreStart, PhasePostStart, PhasePreStop, PhasePostStop} {
+		hooks := make([]Hook, 0, len(parentHooks[phase])+len(child.Hooks[phase]))
+		hooks = append(hooks, parentHooks[phase]...)
+		ret.Hooks[phase] = append(hooks, child.Hooks[phase]...)
+	}
+	return &ret, nil
+}
+
+// mergeService merge child service into parent service:
+//   - non-empty values override parent's
+//   - build args and environment are merged, child's values take precedence
+//   - mounts are combined
+//   - dependencies, readiness and hooks of each phase are replaced if defined in child
+func mergeService(parent, child Service) Service {
+	ret := parent
+	if len(child.DisplayName) != 0 && child.DisplayName != child.Name {
+		ret.DisplayName = child.DisplayName
+	}
+	if len(child.DisplayVersion) != 0 {
+		ret.DisplayVersion = child.DisplayVersion
+	}
+	if len(child.Image) != 0 {
+		ret.Image = child.Image
+	}
+	ret.Mounts = make([]string, 0, len(parent.Mounts)+len(child.Mounts))
+	ret.Mounts = append(ret.Mounts, parent.Mounts...)
+	mounts := lanaiutils.NewStringSet(parent.Mounts...)
+	for _, m := range child.Mounts {
+		if !mounts.Has(m) {
+			ret.Mounts = append(ret.Mounts, m)
+		}
+	}
+	ret.BuildArgs = mergeMap(parent.BuildArgs, child.BuildArgs)
+	ret.Environment = mergeMap(parent.Environment, child.Environment)
+	if child.DependsOn != nil {
+		ret.DependsOn = child.DependsOn
+	}
+	if child.Readiness != nil {
+		ret.Readiness = child.Readiness
+	}
+	ret.Hooks = Hooks{}
+	for phase, hooks := range parent.Hooks {
+		ret.Hooks[phase] = hooks
+	}
+	for phase, hooks := range child.Hooks {
+		if len(hooks) != 0 {
+			ret.Hooks[phase] = hooks
+		}
+	}
+	return ret
+}
+
+func removeHooks(hooks Hooks, names lanaiutils.StringSet) Hooks {
+	ret := Hooks{}
+	for phase := range hooks {
+		ret[phase] = make([]Hook, 0, len(hooks[phase]))
+		for _, h := range hooks[phase] {
+			if !names.Has(h.Name) {
+				ret[phase] = append(ret[phase], h)
+			}
+		}
+	}
+	return ret
+}
+
+func mergeMap(parent, child map[string]string) map[string]string {
+	if parent == nil && child == nil {
+		return nil
+	}
+	ret := maps.Clone(parent)
+	if ret == nil {
+		ret = map[string]string{}
+	}
+	maps.Copy(ret, child)
+	return ret
+}
+
+func existsInFS(fsys fs.FS, path string) bool {
+	if fsys == nil {
+		return false
+	}
+	_, e := fs.Stat(fsys, path)
+	return e == nil
+}
@@ -0,0 +1,232 @@
+package devenv
+
+import (
+	"maps"
+	"slices"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func testParentProfile() *Profile {
+	return &Profile{
+		ProfileMetadata: ProfileMetadata{
+			Name:        "parent",
+			ComposeFS:   fstest.MapFS{"parent/docker-compose.yml": {}},
+			ComposePath: "parent/docker-compose.yml",
+			ResourceFS:  fstest.MapFS{"parent/res/init.sh": {}},
+			ResourceDir: "parent/res",
+		},
+		DisplayName: "Parent",
+		Prune:       PruneGlobal,
+		HookTimeout: time.Minute,
+		Variables:   map[string]string{"REGION": "us-east-1", "TIER": "dev"},
+		Secrets:     []Secret{{Name: "TOKEN", Env: "GITHUB_TOKEN"}, {Name: "PASSWORD", File: "~/.db-password"}},
+		Services: map[string]Service{
+			"db": {
+				Name:        "db",
+				DisplayName: "Database",
+				Image:       "postgres:15",
+				Mounts:      []string{"data"},
+				BuildArgs:   map[string]string{"version": "15", "locale": "en"},
+				DependsOn:   []string{"net"},
+				Hooks: Hooks{
+					PhasePreStart:  {{Name: "prepare", Phase: PhasePreStart, Service: "db"}},
+					PhasePostStart: {{Name: "seed", Phase: PhasePostStart, Service: "db"}},
+				},
+			},
+			"net":   {Name: "net"},
+			"cache": {Name: "cache"},
+		},
+		Hooks: Hooks{
+			PhasePreStart: {{Name: "init.sh", Phase: PhasePreStart}, {Name: "check.sh", Phase: PhasePreStart}},
+		},
+	}
+}
+
+func hookNames(hooks []Hook) []string {
+	names := make([]string, len(hooks))
+	for i := range hooks {
+		names[i] = hooks[i].Name
+	}
+	return names
+}
+
+func TestMergeProfile(t *testing.T) {
+	tests := []struct {
+		name  string
+		child *Profile
+		err   string
+		check func(t *testing.T, p *Profile)
+	}{
+		{
+			name:  "inherit from parent",
+			child: &Profile{ProfileMetadata: ProfileMetadata{Name: "child"}},
+			check: func(t *testing.T, p *Profile) {
+				switch {
+				case p.Name != "child":
+					t.Errorf("expected name from child, but got %s", p.Name)
+				case p.DisplayName != "Parent":
+					t.Errorf("expected display name from parent, but got %q", p.DisplayName)
+				case p.Prune != PruneGlobal:
+					t.Errorf("expected prune policy from parent, but got %q", p.Prune)
+				case p.HookTimeout != time.Minute:
+					t.Errorf("expected hook timeout from parent, but got %v", p.HookTimeout)
+				case p.ComposePath != "parent/docker-compose.yml" || p.ResourceDir != "parent/res":
+					t.Errorf("expected compose file and resources from parent, but got %s %s", p.ComposePath, p.ResourceDir)
+				case len(p.Services) != 3:
+					t.Errorf("expected services from parent, but got %v", p.Services)
+				}
+			},
+		},
+		{
+			name: "override by child",
+			child: &Profile{
+				ProfileMetadata: ProfileMetadata{
+					Name:        "child",
+					ComposeFS:   fstest.MapFS{"child/docker-compose.yml": {}},
+					ComposePath: "child/docker-compose.yml",
+					ResourceFS:  fstest.MapFS{},
+					ResourceDir: "child/res",
+				},
+				DisplayName: "Child",
+				Prune:       PruneNone,
+				HookTimeout: 10 * time.Second,
+			},
+			check: func(t *testing.T, p *Profile) {
+				switch {
+				case p.DisplayName != "Child":
+					t.Errorf("expected display name from child, but got %q", p.DisplayName)
+				case p.Prune != PruneNone:
+					t.Errorf("expected prune policy from child, but got %q", p.Prune)
+				case p.HookTimeout != 10*time.Second:
+					t.Errorf("expected hook timeout from child, but got %v", p.HookTimeout)
+				case p.ComposePath != "child/docker-compose.yml":
+					t.Errorf("expected compose file from child, but got %s", p.ComposePath)
+				case p.ResourceDir != "parent/res":
+					t.Errorf("expected missing resource directory to fall back to parent's, but got %s", p.ResourceDir)
+				}
+			},
+		},
+		{
+			name: "merge services",
+			child: &Profile{
+				ProfileMetadata: ProfileMetadata{Name: "child"},
+				Services: map[string]Service{
+					"db": {
+						Name:      "db",
+						Image:     "postgres:16",
+						Mounts:    []string{"logs", "data"},
+						BuildArgs: map[string]string{"version": "16"},
+						DependsOn: []string{},
+						Hooks: Hooks{
+							PhasePostStart: {{Name: "migrate", Phase: PhasePostStart, Service: "db"}},
+						},
+					},
+					"app": {Name: "app", DependsOn: []string{"db"}},
+				},
+			},
+			check: func(t *testing.T, p *Profile) {
+				db := p.Services["db"]
+				expectedArgs := map[string]string{"version": "16", "locale": "en"}
+				switch {
+				case len(p.Services) != 4:
+					t.Errorf("expected services of parent and child, but got %v", p.Services)
+				case db.DisplayName != "Database" || db.Image != "postgres:16":
+					t.Errorf("expected non-empty values of child to override, but got %q %q", db.DisplayName, db.Image)
+				case !slices.Equal(db.Mounts, []string{"data", "logs"}):
+					t.Errorf("expected combined mounts, but got %v", db.Mounts)
+				case !maps.Equal(db.BuildArgs, expectedArgs):
+					t.Errorf("expected build args %v, but got %v", expectedArgs, db.BuildArgs)
+				case db.DependsOn == nil || len(db.DependsOn) != 0:
+					t.Errorf("expected dependencies replaced by child, but got %v", db.DependsOn)
+				case !slices.Equal(hookNames(db.Hooks[PhasePreStart]), []string{"prepare"}):
+					t.Errorf("expected pre-start hooks from parent, but got %v", hookNames(db.Hooks[PhasePreStart]))
+				case !slices.Equal(hookNames(db.Hooks[PhasePostStart]), []string{"migrate"}):
+					t.Errorf("expected post-start hooks replaced by child, but got %v", hookNames(db.Hooks[PhasePostStart]))
+				}
+			},
+		},
+		{
+			name: "merge variables",
+			child: &Profile{
+				ProfileMetadata: ProfileMetadata{Name: "child"},
+				Variables:       map[string]string{"TIER": "test", "DEBUG": "true"},
+			},
+			check: func(t *testing.T, p *Profile) {
+				expected := map[string]string{"REGION": "us-east-1", "TIER": "test", "DEBUG": "true"}
+				if !maps.Equal(p.Variables, expected) {
+					t.Errorf("expected variables %v, but got %v", expected, p.Variables)
+				}
+			},
+		},
+		{
+			name: "merge secrets",
+			child: &Profile{
+				ProfileMetadata: ProfileMetadata{Name: "child"},
+				Secrets:         []Secret{{Name: "TOKEN", Command: "gh auth token"}, {Name: "API_KEY", Env: "API_KEY"}},
+			},
+			check: func(t *testing.T, p *Profile) {
+				expected := []Secret{
+					{Name: "API_KEY", Env: "API_KEY"},
+					{Name: "PASSWORD", File: "~/.db-password"},
+					{Name: "TOKEN", Command: "gh auth token"},
+				}
+				if !slices.Equal(p.Secrets, expected) {
+					t.Errorf("expected secrets %v, but got %v", expected, p.Secrets)
+				}
+			},
+		},
+		{
+			name: "remove services and hooks",
+			child: &Profile{
+				ProfileMetadata: ProfileMetadata{Name: "child"},
+				ProfileInheritance: ProfileInheritance{
+					Remove: ProfileRemoval{Services: []string{"cache"}, Hooks: []string{"check.sh", "seed"}},
+				},
+				Hooks: Hooks{
+					PhasePreStart: {{Name: "child.sh", Phase: PhasePreStart}},
+				},
+			},
+			check: func(t *testing.T, p *Profile) {
+				switch {
+				case len(p.Services) != 2 || p.Services["cache"].Name != "":
+					t.Errorf("expected service removed, but got %v", p.Services)
+				case len(p.Services["db"].Hooks[PhasePostStart]) != 0:
+					t.Errorf("expected service hook removed, but got %v", hookNames(p.Services["db"].Hooks[PhasePostStart]))
+				case !slices.Equal(hookNames(p.Hooks[PhasePreStart]), []string{"init.sh", "child.sh"}):
+					t.Errorf("expected child's hooks appended to parent's, but got %v", hookNames(p.Hooks[PhasePreStart]))
+				}
+			},
+		},
+		{
+			name: "remove unknown service",
+			child: &Profile{
+				ProfileMetadata: ProfileMetadata{Name: "child"},
+				ProfileInheritance: ProfileInheritance{
+					Remove: ProfileRemoval{Services: []string{"unknown"}},
+				},
+			},
+			err: `cannot remove service [unknown]: not defined in parent profile`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			parent := testParentProfile()
+			p, e := MergeProfile(parent, test.child)
+			if len(test.err) != 0 {
+				if e == nil || e.Error() != test.err {
+					t.Fatalf("expected error %q, but got %v", test.err, e)
+				}
+				return
+			}
+			if e != nil {
+				t.Fatalf("unexpected error: %v", e)
+			}
+			test.check(t, p)
+			if !slices.Equal(hookNames(parent.Hooks[PhasePreStart]), []string{"init.sh", "check.sh"}) {
+				t.Errorf("expected parent unchanged, but got hooks %v", hookNames(parent.Hooks[PhasePreStart]))
+			}
+		})
+	}
+}
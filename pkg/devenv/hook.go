@@ -1,5 +1,10 @@
 package devenv
 
+import (
+	"fmt"
+	"time"
+)
+
 type Hooks map[HookPhase][]Hook
 
 func (h Hooks) Phase(phaseStr HookPhase) []Hook {
@@ -11,7 +16,46 @@ type Hook struct {
 	Name  string
 	Phase HookPhase
 	Type  HookType
+	// Value script filename for TypeScript, command block for TypeInline, compose service name for TypeContainer
 	Value interface{}
+	// Service name of the service this hook belongs to. Empty for profile-level hooks
+	Service string
+	// Timeout optional, how long the hook may run. Profile.HookTimeout is used if not set
+	Timeout time.Duration
+	// Interpreter optional, program that runs the script or command block, e.g. "bash" or "python3".
+	// Script files are executed directly and command blocks are run with "sh" if not set
+	Interpreter string
+	// Args optional, arguments passed to the script or command block
+	Args []string
+	// Env optional, additional environment variables of the script or command block
+	Env map[string]string
+	// WorkDir optional, working directory of the script or command block. Relative path is resolved against the plan's working directory
+	WorkDir string
+	// ContinueOnError if true, failure of this hook is reported but doesn't fail the plan
+	ContinueOnError bool
+	// Condition optional, the hook is skipped unless all conditions are met
+	Condition HookCondition
+	// Retries how many times the hook is retried after failure
+	Retries int
+	// RetryDelay delay before the first retry, doubled for each subsequent retry
+	RetryDelay time.Duration
+}
+
+// HookCondition conditions evaluated when the plan is created. Zero value means unconditional
+type HookCondition struct {
+	// FirstStart run only if all services of the profile have never been started successfully, according to recorded state
+	FirstStart bool
+	// IfMissing run only if given path doesn't exist. Relative path is resolved against profile's local data directory
+	IfMissing string
+	// IfEnv run only if given variable is set and not empty. In "NAME=value" format, the variable should equal to given value.
+	// Profile's variables are checked first, then environment variables
+	IfEnv string
+	// OnlyOn run only if the command's action is one of given values: "start", "stop" or "restart"
+	OnlyOn []string
+}
+
+func (c HookCondition) IsZero() bool {
+	return !c.FirstStart && len(c.IfMissing) == 0 && len(c.IfEnv) == 0 && len(c.OnlyOn) == 0
 }
 
 const (
@@ -25,7 +69,23 @@ type HookPhase string
 
 const (
 	TypeScript    HookType = "script"
+	TypeInline    HookType = "inline"
 	TypeContainer HookType = "container"
 )
 
 type HookType string
+
+// parseHookTimeout parse timeout string such as "45s" or "2m". Empty string means not set
+func parseHookTimeout(value string) (time.Duration, error) {
+	if len(value) == 0 {
+		return 0, nil
+	}
+	d, e := time.ParseDuration(value)
+	switch {
+	case e != nil:
+		return 0, fmt.Errorf(`invalid hook timeout "%s": %v`, value, e)
+	case d < 0:
+		return 0, fmt.Errorf(`invalid hook timeout "%s": should not be negative`, value)
+	}
+	return d, nil
+}
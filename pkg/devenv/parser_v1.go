@@ -16,11 +16,17 @@ var (
 
 type ProfileV1 struct {
 	ProfileMetadata
-	Services  []ServiceV1 `json:"services"`
-	PreStart  []string    `json:"pre_start"`
-	PostStart []string    `json:"post_start"`
-	PreStop   []string    `json:"pre_stop"`
-	PostStop  []string    `json:"post_stop"`
+	ProfileInheritance
+	Engine      string                      `json:"engine" desc:"how the profile is run: \"compose\" (docker compose CLI, default) or \"docker\" (Docker Engine API)"`
+	Prune       PrunePolicy                 `json:"prune" desc:"what to prune after the profile is started or stopped, \"profile\" by default"`
+	HookTimeout string                      `json:"hook_timeout" desc:"default timeout of hooks, e.g. \"45s\" or \"2m\""`
+	Variables   map[string]string           `json:"variables" desc:"default values of user variables"`
+	Secrets     map[string]SecretDefinition `json:"secrets" desc:"variables resolved right before use and masked when printed"`
+	Services    []ServiceV1                 `json:"services" desc:"services of the profile, should match services in the docker compose file"`
+	PreStart    []string                    `json:"pre_start" desc:"script filenames in \"<resource-dir>/pre-start/\""`
+	PostStart   []string                    `json:"post_start" desc:"docker compose services that run once after services are started"`
+	PreStop     []string                    `json:"pre_stop" desc:"script filenames in \"<resource-dir>/pre-stop/\""`
+	PostStop    []string                    `json:"post_stop" desc:"script filenames in \"<resource-dir>/post-stop/\""`
 }
 
 func (p *ProfileV1) ResourceDir() string {
@@ -35,10 +41,27 @@ func (p *ProfileV1) LocalDataDir() string {
 	return filepath.Clean(tmplutils.MustSprint(TemplateV1LocalDataDir, p))
 }
 
-func (p *ProfileV1) ToProfile() *Profile {
+func (p *ProfileV1) ToProfile() (*Profile, error) {
+	if e := p.Prune.Validate(); e != nil {
+		return nil, fmt.Errorf(`invalid profile "%s": %v`, p.DisplayPath, e)
+	}
+	hookTimeout, e := parseHookTimeout(p.HookTimeout)
+	if e != nil {
+		return nil, fmt.Errorf(`invalid profile "%s": %v`, p.DisplayPath, e)
+	}
+	secrets, e := toSecrets(p.Secrets)
+	if e != nil {
+		return nil, fmt.Errorf(`invalid profile "%s": %v`, p.DisplayPath, e)
+	}
 	ret := Profile{
-		ProfileMetadata: p.ProfileMetadata,
-		Services:        map[string]Service{},
+		ProfileMetadata:    p.ProfileMetadata,
+		ProfileInheritance: p.ProfileInheritance,
+		Engine:             p.Engine,
+		Prune:              p.Prune,
+		HookTimeout:        hookTimeout,
+		Variables:          p.Variables,
+		Secrets:            secrets,
+		Services:           map[string]Service{},
 		Hooks: Hooks{
 			PhasePreStart:  utils.ConvertSlice(p.PreStart, p.hookConverter(PhasePreStart)),
 			PhasePostStart: utils.ConvertSlice(p.PostStart, p.hookConverter(PhasePostStart)),
@@ -60,7 +83,9 @@ func (p *ProfileV1) ToProfile() *Profile {
 	ret.ResourceDir = p.ResourceDir()
 	ret.ComposePath = p.ComposePath()
 	ret.LocalDataDir = p.LocalDataDir()
-	return &ret
+	ret.ComposeFS = p.FS
+	ret.ResourceFS = p.FS
+	return &ret, nil
 }
 
 func (p *ProfileV1) hookConverter(phase HookPhase) func(string) Hook {
@@ -79,12 +104,12 @@ func (p *ProfileV1) hookConverter(phase HookPhase) func(string) Hook {
 }
 
 type ServiceV1 struct {
-	Name           string            `json:"service"`
-	DisplayName    string            `json:"display_name"`
-	DisplayVersion string            `json:"display_version"`
-	ImageName      string            `json:"image"`
-	Mounts         []string          `json:"mounts"`
-	BuildArgs      map[string]string `json:"build_args"`
+	Name           string            `json:"service" desc:"name of the service in the docker compose file"`
+	DisplayName    string            `json:"display_name" desc:"name displayed instead of the service name"`
+	DisplayVersion string            `json:"display_version" desc:"version displayed with the service"`
+	ImageName      string            `json:"image" desc:"image of the service, available to the docker compose template"`
+	Mounts         []string          `json:"mounts" desc:"directories created in the local data directory before services are started"`
+	BuildArgs      map[string]string `json:"build_args" desc:"build arguments, available to the docker compose template and hooks as variables"`
 }
 
 func LoadProfileV1(meta *ProfileMetadata) (*ProfileV1, error) {
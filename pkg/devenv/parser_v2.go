@@ -0,0 +1,350 @@
+package devenv
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/cisco-open/go-lanai/cmd/lanai-cli/cmdutils"
+	"github.com/stonedu1011/devenvctl/pkg/utils/tmplutils"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+type ProfileV2 struct {
+	ProfileMetadata
+	ProfileInheritance
+	Version     json.Number                 `json:"version"`
+	DisplayName string                      `json:"display_name" desc:"name displayed instead of the profile name"`
+	Engine      string                      `json:"engine" desc:"how the profile is run: \"compose\" (docker compose CLI, default) or \"docker\" (Docker Engine API)"`
+	Prune       PrunePolicy                 `json:"prune" desc:"what to prune after the profile is started or stopped, \"profile\" by default"`
+	HookTimeout string                      `json:"hook_timeout" desc:"default timeout of hooks, e.g. \"45s\" or \"2m\""`
+	Variables   map[string]string           `json:"variables" desc:"default values of user variables"`
+	Secrets     map[string]SecretDefinition `json:"secrets" desc:"variables resolved right before use and masked when printed"`
+	Services    map[string]ServiceV2        `json:"services" desc:"services of the profile by name, should match services in the docker compose file"`
+	Hooks       HooksV2                     `json:"hooks" desc:"profile-level hooks"`
+}
+
+func (p *ProfileV2) ResourceDir() string {
+	return filepath.Clean(tmplutils.MustSprint(TemplateV1ResourceDir, p))
+}
+
+func (p *ProfileV2) ComposePath() string {
+	return filepath.Clean(tmplutils.MustSprint(TemplateV1ComposePath, p))
+}
+
+func (p *ProfileV2) LocalDataDir() string {
+	return filepath.Clean(tmplutils.MustSprint(TemplateV1LocalDataDir, p))
+}
+
+// ToProfile convert to Profile. Note: service hooks are not merged into Profile.Hooks until the profile is resolved by LoadProfile
+func (p *ProfileV2) ToProfile() (*Profile, error) {
+	ret := Profile{
+		ProfileMetadata:    p.ProfileMetadata,
+		ProfileInheritance: p.ProfileInheritance,
+		DisplayName:        p.DisplayName,
+		Engine:             p.Engine,
+		Prune:              p.Prune,
+		Variables:          p.Variables,
+		Services:           map[string]Service{},
+		Hooks:              Hooks{},
+	}
+	if e := p.Prune.Validate(); e != nil {
+		return nil, fmt.Errorf(`invalid profile "%s": %v`, p.DisplayPath, e)
+	}
+	var e error
+	if ret.HookTimeout, e = parseHookTimeout(p.HookTimeout); e != nil {
+		return nil, fmt.Errorf(`invalid profile "%s": %v`, p.DisplayPath, e)
+	}
+	if ret.Secrets, e = toSecrets(p.Secrets); e != nil {
+		return nil, fmt.Errorf(`invalid profile "%s": %v`, p.DisplayPath, e)
+	}
+	for name, sv2 := range p.Services {
+		s, e := sv2.toService(name)
+		if e != nil {
+			return nil, fmt.Errorf(`invalid service [%s] in "%s": %v`, name, p.DisplayPath, e)
+		}
+		s.owner = &ret
+		ret.Services[name] = s
+	}
+
+	for _, phase := range []HookPhase{PhasePreStart, PhasePostStart, PhasePreStop, PhasePostStop} {
+		hooks, e := p.Hooks.toHooks(phase, "")
+		if e != nil {
+			return nil, fmt.Errorf(`invalid hooks in "%s": %v`, p.DisplayPath, e)
+		}
+		ret.Hooks[phase] = hooks
+	}
+
+	ret.ResourceDir = p.ResourceDir()
+	ret.ComposePath = p.ComposePath()
+	ret.LocalDataDir = p.LocalDataDir()
+	ret.ComposeFS = p.FS
+	ret.ResourceFS = p.FS
+	return &ret, nil
+}
+
+type ServiceV2 struct {
+	DisplayName    string            `json:"display_name" desc:"name displayed instead of the service name"`
+	DisplayVersion string            `json:"display_version" desc:"version displayed with the service"`
+	ImageName      string            `json:"image" desc:"image of the service, available to the docker compose template"`
+	Mounts         []string          `json:"mounts" desc:"directories created in the local data directory before the service is started"`
+	BuildArgs      map[string]string `json:"build_args" desc:"build arguments, available to the docker compose template and hooks as variables"`
+	DependsOn      []string          `json:"depends_on" desc:"services this service depends on, affecting order of hooks and readiness checks"`
+	Environment    map[string]string `json:"environment" desc:"environment variables, available to the docker compose template"`
+	Readiness      *ReadinessV2      `json:"readiness" desc:"how to tell the service is ready after it is started"`
+	Hooks          HooksV2           `json:"hooks" desc:"hooks of the service"`
+}
+
+func (s ServiceV2) toService(name string) (svc Service, err error) {
+	svc = Service{
+		Name:           name,
+		DisplayName:    s.DisplayName,
+		DisplayVersion: s.DisplayVersion,
+		Image:          s.ImageName,
+		Mounts:         s.Mounts,
+		BuildArgs:      s.BuildArgs,
+		DependsOn:      s.DependsOn,
+		Environment:    s.Environment,
+		Hooks:          Hooks{},
+	}
+	if len(svc.DisplayName) == 0 {
+		svc.DisplayName = name
+	}
+	if s.Readiness != nil {
+		if svc.Readiness, err = s.Readiness.toReadiness(); err != nil {
+			return
+		}
+	}
+	for _, phase := range []HookPhase{PhasePreStart, PhasePostStart, PhasePreStop, PhasePostStop} {
+		if svc.Hooks[phase], err = s.Hooks.toHooks(phase, name); err != nil {
+			return
+		}
+	}
+	return
+}
+
+type ReadinessV2 struct {
+	Type     ReadinessType `json:"type" desc:"\"healthcheck\" by default"`
+	Target   string        `json:"target" desc:"\"host:port\" or port for \"tcp\", URL for \"http\", regex for \"log\""`
+	Interval string        `json:"interval" desc:"interval between checks, e.g. \"1s\""`
+	Timeout  string        `json:"timeout" desc:"how long to wait for the service, e.g. \"2m\""`
+}
+
+func (r ReadinessV2) toReadiness() (*Readiness, error) {
+	ret := Readiness{
+		Type:   r.Type,
+		Target: r.Target,
+	}
+	switch ret.Type {
+	case "":
+		ret.Type = ReadinessHealthcheck
+	case ReadinessHealthcheck, ReadinessRunning:
+	case ReadinessTCP, ReadinessHTTP, ReadinessLog:
+		if len(ret.Target) == 0 {
+			return nil, fmt.Errorf(`readiness type [%s] requires "target"`, ret.Type)
+		}
+	default:
+		return nil, fmt.Errorf(`unsupported readiness type [%s]`, ret.Type)
+	}
+	if ret.Type == ReadinessLog {
+		if _, e := regexp.Compile(ret.Target); e != nil {
+			return nil, fmt.Errorf(`invalid readiness log pattern "%s": %v`, ret.Target, e)
+		}
+	}
+	var e error
+	if len(r.Interval) != 0 {
+		if ret.Interval, e = time.ParseDuration(r.Interval); e != nil {
+			return nil, fmt.Errorf(`invalid readiness interval "%s": %v`, r.Interval, e)
+		}
+	}
+	if len(r.Timeout) != 0 {
+		if ret.Timeout, e = time.ParseDuration(r.Timeout); e != nil {
+			return nil, fmt.Errorf(`invalid readiness timeout "%s": %v`, r.Timeout, e)
+		}
+	}
+	return &ret, nil
+}
+
+type HooksV2 struct {
+	PreStart  []HookV2 `json:"pre_start" desc:"hooks run before services are started"`
+	PostStart []HookV2 `json:"post_start" desc:"hooks run after services are started"`
+	PreStop   []HookV2 `json:"pre_stop" desc:"hooks run before services are stopped"`
+	PostStop  []HookV2 `json:"post_stop" desc:"hooks run after services are stopped"`
+}
+
+func (h HooksV2) toHooks(phase HookPhase, service string) ([]Hook, error) {
+	var hooks []HookV2
+	switch phase {
+	case PhasePreStart:
+		hooks = h.PreStart
+	case PhasePostStart:
+		hooks = h.PostStart
+	case PhasePreStop:
+		hooks = h.PreStop
+	case PhasePostStop:
+		hooks = h.PostStop
+	}
+	ret := make([]Hook, len(hooks))
+	for i := range hooks {
+		var e error
+		if ret[i], e = hooks[i].toHook(phase, service); e != nil {
+			return nil, e
+		}
+	}
+	return ret, nil
+}
+
+// HookV2 can be either a plain string or an object with "script", "run" or "container".
+// Plain string is interpreted the same way as v1 format: container in post-start phase, script in other phases.
+type HookV2 struct {
+	Name            string            `json:"name" desc:"name of the hook, also a script filename or container if none of \"script\", \"run\" and \"container\" is set"`
+	Script          string            `json:"script" desc:"script filename in \"<resource-dir>/<phase>/\""`
+	Run             string            `json:"run" desc:"command block"`
+	Container       string            `json:"container" desc:"docker compose service to run"`
+	Interpreter     string            `json:"interpreter" desc:"program that runs the script or command block, e.g. \"bash\""`
+	Args            []string          `json:"args" desc:"arguments passed to the script or command block"`
+	Env             map[string]string `json:"env" desc:"additional environment variables of the script or command block"`
+	WorkDir         string            `json:"workdir" desc:"working directory of the script or command block"`
+	ContinueOnError bool              `json:"continue_on_error" desc:"report failure without failing the plan"`
+	Timeout         string            `json:"timeout" desc:"how long the hook may run, e.g. \"45s\""`
+	FirstStart      bool              `json:"first_start" desc:"run only when the profile is started for the first time"`
+	IfMissing       string            `json:"if_missing" desc:"run only if the path does not exist, relative to the local data directory"`
+	IfEnv           string            `json:"if_env" desc:"run only if the variable is set, or equals to the value in \"NAME=value\" format"`
+	OnlyOn          StringOrList      `json:"only_on" desc:"run only on given actions: \"start\", \"stop\" or \"restart\""`
+	Retries         int               `json:"retries" desc:"how many times the hook is retried after failure"`
+	RetryDelay      string            `json:"retry_delay" desc:"delay before the first retry, doubled for each subsequent retry"`
+}
+
+// StringOrList a list of strings that can also be written as a single string in YAML
+type StringOrList []string
+
+func (l *StringOrList) UnmarshalJSON(data []byte) error {
+	var str string
+	if e := json.Unmarshal(data, &str); e == nil {
+		*l = StringOrList{str}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(l))
+}
+
+func (h *HookV2) UnmarshalJSON(data []byte) error {
+	var str string
+	if e := json.Unmarshal(data, &str); e == nil {
+		*h = HookV2{Name: str}
+		return nil
+	}
+	type hookV2 HookV2
+	return json.Unmarshal(data, (*hookV2)(h))
+}
+
+func (h HookV2) toHook(phase HookPhase, service string) (Hook, error) {
+	hook := Hook{
+		Name:            h.Name,
+		Phase:           phase,
+		Service:         service,
+		Interpreter:     h.Interpreter,
+		Args:            h.Args,
+		Env:             h.Env,
+		WorkDir:         h.WorkDir,
+		ContinueOnError: h.ContinueOnError,
+		Condition: HookCondition{
+			FirstStart: h.FirstStart,
+			IfMissing:  h.IfMissing,
+			IfEnv:      h.IfEnv,
+			OnlyOn:     h.OnlyOn,
+		},
+		Retries: h.Retries,
+	}
+	var count int
+	for _, v := range []string{h.Script, h.Run, h.Container} {
+		if len(v) != 0 {
+			count++
+		}
+	}
+	switch {
+	case count > 1:
+		return hook, fmt.Errorf(`hook [%s] should have only one of "script", "run" and "container"`, h.Name)
+	case len(h.Container) != 0 && (len(h.Interpreter) != 0 || len(h.Args) != 0 || len(h.Env) != 0 || len(h.WorkDir) != 0):
+		return hook, fmt.Errorf(`container hook [%s] doesn't support "interpreter", "args", "env" and "workdir"`, h.Name)
+	case len(h.Script) != 0:
+		hook.Type = TypeScript
+		hook.Value = h.Script
+	case len(h.Run) != 0:
+		hook.Type = TypeInline
+		hook.Value = h.Run
+		if len(hook.Name) == 0 {
+			hook.Name = inlineHookName(h.Run)
+		}
+	case len(h.Container) != 0:
+		hook.Type = TypeContainer
+		hook.Value = h.Container
+	case len(h.Name) != 0:
+		hook.Type = TypeScript
+		hook.Value = h.Name
+		if phase == PhasePostStart {
+			hook.Type = TypeContainer
+		}
+	default:
+		return hook, fmt.Errorf(`hook in phase [%s] requires "script", "run" or "container"`, phase)
+	}
+	if len(hook.Name) == 0 {
+		hook.Name = hook.Value.(string)
+	}
+	var e error
+	if hook.Timeout, e = parseHookTimeout(h.Timeout); e != nil {
+		return hook, fmt.Errorf(`hook [%s]: %v`, hook.Name, e)
+	}
+	if e := h.validateConditionAndRetry(hook); e != nil {
+		return hook, fmt.Errorf(`hook [%s]: %v`, hook.Name, e)
+	}
+	if len(h.RetryDelay) != 0 {
+		if hook.RetryDelay, e = time.ParseDuration(h.RetryDelay); e != nil {
+			return hook, fmt.Errorf(`hook [%s]: invalid retry delay "%s": %v`, hook.Name, h.RetryDelay, e)
+		}
+	}
+	return hook, nil
+}
+
+func (h HookV2) validateConditionAndRetry(hook Hook) error {
+	if hook.Type == TypeContainer && hook.Phase == PhasePostStart && (!hook.Condition.IsZero() || hook.Retries != 0) {
+		return fmt.Errorf(`conditions and retries are not supported by post-start container hooks, which are started together with services`)
+	}
+	if hook.Retries < 0 {
+		return fmt.Errorf(`"retries" should not be negative`)
+	}
+	for _, action := range hook.Condition.OnlyOn {
+		switch action {
+		case "start", "stop", "restart":
+		default:
+			return fmt.Errorf(`unsupported "only_on" value [%s], should be one of [start, stop, restart]`, action)
+		}
+	}
+	return nil
+}
+
+// inlineHookName returns first line of the command block, truncated
+func inlineHookName(run string) string {
+	const maxLen = 40
+	line, _, _ := strings.Cut(strings.TrimSpace(run), "\n")
+	line = strings.TrimSpace(line)
+	if len(line) > maxLen {
+		line = line[:maxLen-3] + "..."
+	}
+	return line
+}
+
+func LoadProfileV2(meta *ProfileMetadata) (*ProfileV2, error) {
+	f, e := meta.FS.Open(meta.Path)
+	if e != nil {
+		return nil, fmt.Errorf(`unable to open profile definition file "%s": %v`, meta.DisplayPath, e)
+	}
+	defer func() { _ = f.Close() }()
+	p := &ProfileV2{
+		ProfileMetadata: *meta,
+	}
+	if e := cmdutils.BindYaml(f, p); e != nil {
+		return nil, fmt.Errorf(`unable to parse profile definition file "%s" as v2 format: %v`, meta.Path, e)
+	}
+	return p, nil
+}
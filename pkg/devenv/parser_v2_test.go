@@ -0,0 +1,72 @@
+package devenv
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestHookV2ToHook(t *testing.T) {
+	tests := []struct {
+		name     string
+		phase    HookPhase
+		hook     string
+		expected Hook
+		err      string
+	}{
+		{name: "script by name", phase: PhasePreStart, hook: `"init.sh"`,
+			expected: Hook{Name: "init.sh", Phase: PhasePreStart, Type: TypeScript, Value: "init.sh"}},
+		{name: "container by name", phase: PhasePostStart, hook: `"seed"`,
+			expected: Hook{Name: "seed", Phase: PhasePostStart, Type: TypeContainer, Value: "seed"}},
+		{name: "inline", phase: PhasePreStart,
+			hook: `{"run": "\n  echo hello\n  echo world\n", "interpreter": "bash -e", "args": ["a b"], "env": {"K": "V"}, "workdir": "sub", "continue_on_error": true}`,
+			expected: Hook{Name: "echo hello", Phase: PhasePreStart, Type: TypeInline, Value: "\n  echo hello\n  echo world\n",
+				Interpreter: "bash -e", Args: []string{"a b"}, Env: map[string]string{"K": "V"}, WorkDir: "sub", ContinueOnError: true}},
+		{name: "inline with long first line", phase: PhasePreStart,
+			hook: `{"run": "curl -X POST http://localhost:8500/v1/kv/config/app -d @config.json"}`,
+			expected: Hook{Name: "curl -X POST http://localhost:8500/v1...", Phase: PhasePreStart, Type: TypeInline,
+				Value: "curl -X POST http://localhost:8500/v1/kv/config/app -d @config.json"}},
+		{name: "named inline", phase: PhasePostStop, hook: `{"name": "cleanup", "run": "rm -rf tmp"}`,
+			expected: Hook{Name: "cleanup", Phase: PhasePostStop, Type: TypeInline, Value: "rm -rf tmp"}},
+		{name: "conditions and retries", phase: PhasePreStart,
+			hook: `{"script": "seed.sh", "first_start": true, "if_missing": "db/seeded", "if_env": "SEED=true", "only_on": "start", "retries": 3, "retry_delay": "2s"}`,
+			expected: Hook{Name: "seed.sh", Phase: PhasePreStart, Type: TypeScript, Value: "seed.sh", Retries: 3, RetryDelay: 2 * time.Second,
+				Condition: HookCondition{FirstStart: true, IfMissing: "db/seeded", IfEnv: "SEED=true", OnlyOn: []string{"start"}}}},
+		{name: "condition of post-start container", phase: PhasePostStart, hook: `{"container": "seed", "first_start": true}`,
+			err: `hook [seed]: conditions and retries are not supported by post-start container hooks, which are started together with services`},
+		{name: "negative retries", phase: PhasePreStart, hook: `{"script": "seed.sh", "retries": -1}`,
+			err: `hook [seed.sh]: "retries" should not be negative`},
+		{name: "unsupported action", phase: PhasePreStart, hook: `{"script": "seed.sh", "only_on": ["start", "deploy"]}`,
+			err: `hook [seed.sh]: unsupported "only_on" value [deploy], should be one of [start, stop, restart]`},
+		{name: "invalid retry delay", phase: PhasePreStart, hook: `{"script": "seed.sh", "retries": 1, "retry_delay": "soon"}`,
+			err: `hook [seed.sh]: invalid retry delay "soon": time: invalid duration "soon"`},
+		{name: "multiple types", phase: PhasePreStart, hook: `{"name": "init", "script": "init.sh", "run": "echo"}`,
+			err: `hook [init] should have only one of "script", "run" and "container"`},
+		{name: "container with interpreter", phase: PhasePreStart, hook: `{"container": "init", "interpreter": "bash"}`,
+			err: `container hook [] doesn't support "interpreter", "args", "env" and "workdir"`},
+		{name: "nothing to run", phase: PhasePreStop, hook: `{"timeout": "5s"}`,
+			err: `hook in phase [pre-stop] requires "script", "run" or "container"`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var hv2 HookV2
+			if e := json.Unmarshal([]byte(test.hook), &hv2); e != nil {
+				t.Fatalf("invalid hook: %v", e)
+			}
+			hook, e := hv2.toHook(test.phase, "")
+			if len(test.err) != 0 {
+				if e == nil || e.Error() != test.err {
+					t.Fatalf("expected error %q, but got %v", test.err, e)
+				}
+				return
+			}
+			if e != nil {
+				t.Fatalf("unexpected error: %v", e)
+			}
+			if !reflect.DeepEqual(hook, test.expected) {
+				t.Errorf("expected %+v, but got %+v", test.expected, hook)
+			}
+		})
+	}
+}
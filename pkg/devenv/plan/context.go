@@ -4,6 +4,9 @@ import (
 	"context"
 	"errors"
 	"github.com/cisco-open/go-lanai/pkg/log"
+	"github.com/stonedu1011/devenvctl/pkg/devenv/state"
+	"io"
+	"time"
 )
 
 var logger = log.New("CLI")
@@ -12,7 +15,10 @@ var (
 	ErrPlanNotAvailable = errors.New(`plan for given action is not available`)
 )
 
-var DefaultExecOption ExecOption
+var DefaultExecOption = ExecOption{
+	StateStore:  state.DefaultStore(),
+	Concurrency: DefaultConcurrency,
+}
 
 const (
 	ActionStart   Action = "start"
@@ -30,6 +36,16 @@ type ExecOptions func(opt *ExecOption)
 type ExecOption struct {
 	Verbose bool
 	DryRun  bool
+	// StateStore optional, where profile's state is recorded. See NewStateRecordExecutables
+	StateStore state.Store
+	// Concurrency max number of steps running at same time. Only DependentExecutable may run concurrently.
+	// Steps are executed sequentially if Concurrency <= 1 or in dry-run mode
+	Concurrency int
+	// Rollback execute compensating actions of started steps in reverse order, if any step fails.
+	// See CompensableExecutable and RegisterCompensation
+	Rollback bool
+	// EventSink optional, receives machine-readable events of plan execution. See Event
+	EventSink EventSink
 }
 
 type Executable interface {
@@ -71,12 +87,47 @@ func (p execPlan) Execute(ctx context.Context, opts...ExecOptions) error {
 	if opt.DryRun {
 		p.prepareDryRun(ctx)
 	}
-	for _, exec := range p.steps {
-		if e := exec.Exec(ctx, opt); e != nil {
-			return e
+	start := time.Now()
+	emitEvent(ctx, opt, Event{Type: EventPlanStart, Steps: len(p.steps), DryRun: opt.DryRun})
+	ctx, compensations := withCompensationRegistry(ctx)
+	failedIdx, e := p.execute(ctx, opt, compensations)
+	result := Event{
+		Type:     EventPlanFinish,
+		Steps:    len(p.steps),
+		Status:   StatusSuccess,
+		Duration: time.Since(start).Seconds(),
+		DryRun:   opt.DryRun,
+	}
+	if e == nil {
+		emitEvent(ctx, opt, result)
+		return nil
+	}
+	if failedIdx >= 0 {
+		recordFailure(ctx, opt, p.steps, failedIdx, e)
+	}
+	if opt.Rollback && !opt.DryRun {
+		result.RolledBack = compensations.rollback(ctx, opt)
+	}
+	result.Status = StatusFailure
+	result.Step = failedIdx + 1
+	result.Error = e.Error()
+	result.Duration = time.Since(start).Seconds()
+	emitEvent(ctx, opt, result)
+	return e
+}
+
+// execute run all steps, returns index of the failed step and error, or -1 if all steps succeeded
+func (p execPlan) execute(ctx context.Context, opt ExecOption, compensations *compensationRegistry) (int, error) {
+	if !opt.DryRun && opt.Concurrency > 1 {
+		return executeConcurrently(ctx, opt, p.steps, compensations)
+	}
+	for i, exec := range p.steps {
+		compensations.registerStep(exec)
+		if e := execStep(ctx, opt, i, exec); e != nil {
+			return i, e
 		}
 	}
-	return nil
+	return -1, nil
 }
 
 func (p execPlan) prepareDryRun(ctx context.Context) {
@@ -105,3 +156,22 @@ func (p closerPlan) Close() error {
 	}
 	return nil
 }
+
+// CombineExecutionPlans combine steps of given plans into single ExecutionPlan, in given order.
+// Any closable plans among given plans are closed when the combined plan is closed.
+func CombineExecutionPlans(metadata interface{}, plans ...ExecutionPlan) ExecutionPlan {
+	steps := make([]Executable, 0, len(plans)*5)
+	for _, p := range plans {
+		steps = append(steps, p.Steps()...)
+	}
+	return NewClosableExecutionPlan(metadata, func() (err error) {
+		for _, p := range plans {
+			if closer, ok := p.(io.Closer); ok {
+				if e := closer.Close(); e != nil && err == nil {
+					err = e
+				}
+			}
+		}
+		return
+	}, steps...)
+}
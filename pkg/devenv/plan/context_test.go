@@ -0,0 +1,60 @@
+package plan
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"testing"
+)
+
+func TestCombineExecutionPlans(t *testing.T) {
+	var closed []string
+	closerOf := func(name string, err error) func() error {
+		return func() error {
+			closed = append(closed, name)
+			return err
+		}
+	}
+	p := CombineExecutionPlans("meta",
+		NewClosableExecutionPlan(nil, closerOf("stop", errors.New("oops")), PrintExecutable("a"), PrintExecutable("b")),
+		NewExecutionPlan(nil, PrintExecutable("c")),
+		NewClosableExecutionPlan(nil, closerOf("start", errors.New("ignored")), PrintExecutable("d")),
+	)
+
+	expected := []Executable{PrintExecutable("a"), PrintExecutable("b"), PrintExecutable("c"), PrintExecutable("d")}
+	if !slices.Equal(p.Steps(), expected) {
+		t.Errorf("expected steps %v, but got %v", expected, p.Steps())
+	}
+	if p.Metadata() != "meta" {
+		t.Errorf("expected given metadata, but got %v", p.Metadata())
+	}
+
+	closer, ok := p.(io.Closer)
+	if !ok {
+		t.Fatalf("expected combined plan to be closable")
+	}
+	if e := closer.Close(); e == nil || e.Error() != "oops" {
+		t.Errorf("expected first error of closers, but got %v", e)
+	}
+	if !slices.Equal(closed, []string{"stop", "start"}) {
+		t.Errorf("expected all closable plans closed in order, but got %v", closed)
+	}
+}
+
+// funcExecutable is an Executable of given function, named for printing
+type funcExecutable struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+func (exec *funcExecutable) Exec(ctx context.Context, _ ExecOption) error {
+	if exec.fn == nil {
+		return nil
+	}
+	return exec.fn(ctx)
+}
+
+func (exec *funcExecutable) String() string {
+	return exec.name
+}
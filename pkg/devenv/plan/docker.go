@@ -0,0 +1,88 @@
+package plan
+
+import (
+	"context"
+	"fmt"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/filters"
+	"github.com/docker/docker/api/types/volume"
+	dockerclient "github.com/docker/docker/client"
+	"sort"
+	"strings"
+)
+
+const (
+	LabelComposeProject = `com.docker.compose.project`
+	LabelComposeService = `com.docker.compose.service`
+	LabelPersist        = `devenv.persist`
+	// following labels are only set by DockerEnginePlanner, so that docker compose CLI recognizes the resources it created
+	LabelComposeNetwork         = `com.docker.compose.network`
+	LabelComposeVolume          = `com.docker.compose.volume`
+	LabelComposeOneOff          = `com.docker.compose.oneoff`
+	LabelComposeContainerNumber = `com.docker.compose.container-number`
+	LabelComposeWorkingDir      = `com.docker.compose.project.working_dir`
+	LabelComposeConfigFiles     = `com.docker.compose.project.config_files`
+)
+
+// NewDockerClient create a docker client with API version negotiation.
+// Note: this client is not used by docker compose CLI
+func NewDockerClient() (*dockerclient.Client, error) {
+	client, e := dockerclient.NewClientWithOpts(dockerclient.WithAPIVersionNegotiation())
+	if e != nil {
+		return nil, fmt.Errorf("docker client not available: %v", e)
+	}
+	return client, nil
+}
+
+// ComposeProjectName returns the project name docker compose would use for given profile.
+// Docker compose normalize project name to lower case
+func ComposeProjectName(profileName string) string {
+	return strings.ToLower(profileName)
+}
+
+// ListComposeContainers list all containers, including stopped ones, that belong to docker compose project of given profile
+func ListComposeContainers(ctx context.Context, client *dockerclient.Client, profileName string) ([]types.Container, error) {
+	label := fmt.Sprintf(`%s=%s`, LabelComposeProject, ComposeProjectName(profileName))
+	return client.ContainerList(ctx, container.ListOptions{
+		All:     true,
+		Filters: filters.NewArgs(filters.Arg("label", label)),
+	})
+}
+
+// ListRunningComposeProjects returns names of docker compose projects that have running containers
+func ListRunningComposeProjects(ctx context.Context, client *dockerclient.Client) ([]string, error) {
+	containers, e := client.ContainerList(ctx, container.ListOptions{
+		Filters: filters.NewArgs(filters.Arg("label", LabelComposeProject)),
+	})
+	if e != nil {
+		return nil, e
+	}
+	projects := make([]string, 0, 5)
+	found := map[string]struct{}{}
+	for i := range containers {
+		name := containers[i].Labels[LabelComposeProject]
+		if _, ok := found[name]; ok || len(name) == 0 {
+			continue
+		}
+		found[name] = struct{}{}
+		projects = append(projects, name)
+	}
+	sort.Strings(projects)
+	return projects, nil
+}
+
+// ListPersistentVolumes list volumes labeled with LabelPersist that belong to docker compose project of given profile
+func ListPersistentVolumes(ctx context.Context, client *dockerclient.Client, profileName string) ([]*volume.Volume, error) {
+	resp, e := client.VolumeList(ctx, volume.ListOptions{
+		Filters: filters.NewArgs(
+			filters.Arg("label", LabelPersist),
+			filters.Arg("label", fmt.Sprintf(`%s=%s`, LabelComposeProject, ComposeProjectName(profileName))),
+		),
+	})
+	if e != nil {
+		return nil, e
+	}
+	sort.SliceStable(resp.Volumes, func(i, j int) bool { return resp.Volumes[i].Name < resp.Volumes[j].Name })
+	return resp.Volumes, nil
+}
@@ -0,0 +1,127 @@
+package plan
+
+import (
+	"context"
+	"encoding/json"
+	dockerclient "github.com/docker/docker/client"
+	"github.com/stonedu1011/devenvctl/pkg/devenv"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+)
+
+// fakeDocker serves canned responses of Docker Engine API, keyed by "<method> <path>" without API version prefix.
+// Responses are encoded as JSON, except status codes and raw bytes, e.g. multiplexed logs. Requests are recorded with their query
+type fakeDocker struct {
+	mtx       sync.Mutex
+	responses map[string]interface{}
+	requests  []string
+}
+
+var regexAPIVersion = regexp.MustCompile(`^/v[0-9.]+`)
+
+func (d *fakeDocker) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	key := r.Method + " " + regexAPIVersion.ReplaceAllString(r.URL.Path, "")
+	d.mtx.Lock()
+	d.requests = append(d.requests, key+"?"+r.URL.RawQuery)
+	resp, ok := d.responses[key]
+	d.mtx.Unlock()
+	rw.Header().Set("Content-Type", "application/json")
+	switch v := resp.(type) {
+	case nil:
+		if !ok {
+			rw.WriteHeader(http.StatusNotFound)
+			_, _ = rw.Write([]byte(`{"message":"not found: ` + key + `"}`))
+		}
+	case []byte:
+		rw.Header().Set("Content-Type", "application/vnd.docker.multiplexed-stream")
+		_, _ = rw.Write(v)
+	case int:
+		rw.WriteHeader(v)
+		_, _ = rw.Write([]byte(`{"message":"` + http.StatusText(v) + `"}`))
+	default:
+		_ = json.NewEncoder(rw).Encode(v)
+	}
+}
+
+// Requested returns recorded requests that start with given prefix, e.g. "POST /containers/prune"
+func (d *fakeDocker) Requested(prefix string) []string {
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+	var ret []string
+	for _, r := range d.requests {
+		if strings.HasPrefix(r, prefix) {
+			ret = append(ret, r)
+		}
+	}
+	return ret
+}
+
+// containsAll returns true if given string contains all substrings
+func containsAll(s string, substrs ...string) bool {
+	for _, sub := range substrs {
+		if !strings.Contains(s, sub) {
+			return false
+		}
+	}
+	return true
+}
+
+func newFakeDocker(t *testing.T, responses map[string]interface{}) (*fakeDocker, *dockerclient.Client) {
+	fake := &fakeDocker{responses: responses}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+	client, e := dockerclient.NewClientWithOpts(
+		dockerclient.WithHost("tcp://"+srv.Listener.Addr().String()),
+		dockerclient.WithVersion("1.45"),
+	)
+	if e != nil {
+		t.Fatalf("unable to create docker client: %v", e)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+	return fake, client
+}
+
+// loadTestProfile load profile "test" from given files. Definition files are written in JSON,
+// which is also valid YAML. Local data directory is a temporary directory
+func loadTestProfile(t *testing.T, files map[string]string) *devenv.Profile {
+	fsys := fstest.MapFS{}
+	for path, content := range files {
+		fsys[path] = &fstest.MapFile{Data: []byte(content)}
+	}
+	profiles, e := devenv.FindProfiles(fsys, ".", regexp.MustCompile(`devenv-(?P<profile>[a-zA-Z][\w-_]+)\.yml`))
+	if e != nil {
+		t.Fatalf("unable to find profiles: %v", e)
+	}
+	p, e := devenv.LoadProfile(profiles["test"], devenv.WithProfiles(profiles))
+	if e != nil {
+		t.Fatalf("unable to load profile: %v", e)
+	}
+	p.LocalDataDir = t.TempDir()
+	return p
+}
+
+func TestListRunningComposeProjects(t *testing.T) {
+	fake, client := newFakeDocker(t, map[string]interface{}{
+		"GET /containers/json": []map[string]interface{}{
+			{"Id": "1", "Labels": map[string]string{LabelComposeProject: "golanai"}},
+			{"Id": "2", "Labels": map[string]string{LabelComposeProject: "another"}},
+			{"Id": "3", "Labels": map[string]string{LabelComposeProject: "golanai"}},
+			{"Id": "4", "Labels": map[string]string{LabelComposeProject: ""}},
+		},
+	})
+	projects, e := ListRunningComposeProjects(context.Background(), client)
+	if e != nil {
+		t.Fatalf("unexpected error: %v", e)
+	}
+	if strings.Join(projects, ",") != "another,golanai" {
+		t.Errorf("expected [another golanai], but got %v", projects)
+	}
+	if reqs := fake.Requested("GET /containers/json"); len(reqs) != 1 || strings.Contains(reqs[0], "all=1") {
+		t.Errorf("expected running containers to be listed, but got %v", reqs)
+	}
+}
@@ -0,0 +1,127 @@
+package plan
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/stonedu1011/devenvctl/pkg/devenv"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	// EventPlanStart emitted before the first step is executed. Event.Steps is the number of planned steps
+	EventPlanStart EventType = "plan_start"
+	// EventStepStart emitted before a step is executed
+	EventStepStart EventType = "step_start"
+	// EventStepFinish emitted after a step finished successfully
+	EventStepFinish EventType = "step_finish"
+	// EventStepError emitted after a step failed. Event.Error is the error message
+	EventStepError EventType = "step_error"
+	// EventContainerLog emitted for each log line of containers monitored by ContainerMonitorExecutable
+	EventContainerLog EventType = "container_log"
+	// EventPlanFinish emitted after plan execution, regardless of its result. Event.Status is the final result
+	EventPlanFinish EventType = "plan_finish"
+)
+
+const (
+	StatusSuccess = "success"
+	StatusFailure = "failure"
+)
+
+type EventType string
+
+// Event is a machine-readable record of plan execution. Fields not applicable to the event type are left empty.
+type Event struct {
+	Type EventType `json:"type"`
+	Time time.Time `json:"time"`
+	// Step 1-based index of the step within the plan
+	Step int `json:"step,omitempty"`
+	// Steps total number of steps of the plan
+	Steps int `json:"steps,omitempty"`
+	// Description String() of the step
+	Description string `json:"description,omitempty"`
+	// Duration in seconds, of the step or the entire plan
+	Duration float64 `json:"duration,omitempty"`
+	Error    string  `json:"error,omitempty"`
+	// Status final result of the plan, "success" or "failure"
+	Status     string `json:"status,omitempty"`
+	RolledBack bool   `json:"rolled_back,omitempty"`
+	DryRun     bool   `json:"dry_run,omitempty"`
+	Container  string `json:"container,omitempty"`
+	Line       string `json:"line,omitempty"`
+}
+
+// EventSink receives events of plan execution. Implementations should be safe for concurrent use,
+// because steps may run concurrently. See ExecOption.Concurrency
+type EventSink interface {
+	Emit(evt Event)
+}
+
+type EventSinkFunc func(evt Event)
+
+func (fn EventSinkFunc) Emit(evt Event) {
+	fn(evt)
+}
+
+// NewJsonEventSink returns an EventSink that writes events as newline-delimited JSON
+func NewJsonEventSink(w io.Writer) EventSink {
+	return &jsonEventSink{encoder: json.NewEncoder(w)}
+}
+
+type jsonEventSink struct {
+	mtx     sync.Mutex
+	encoder *json.Encoder
+}
+
+func (s *jsonEventSink) Emit(evt Event) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	if e := s.encoder.Encode(evt); e != nil {
+		logger.Warnf(`unable to write event: %v`, e)
+	}
+}
+
+// emitEvent send event to ExecOption.EventSink, if configured.
+// Step index is populated from context if the event is emitted within a step
+func emitEvent(ctx context.Context, opts ExecOption, evt Event) {
+	if opts.EventSink == nil {
+		return
+	}
+	if evt.Time.IsZero() {
+		evt.Time = time.Now()
+	}
+	if step, ok := ctx.Value(stepIndexKey{}).(int); ok && evt.Step == 0 {
+		evt.Step = step
+	}
+	evt.Description = devenv.MaskSecrets(evt.Description)
+	evt.Error = devenv.MaskSecrets(evt.Error)
+	evt.Line = devenv.MaskSecrets(evt.Line)
+	opts.EventSink.Emit(evt)
+}
+
+type stepIndexKey struct{}
+
+// execStep execute i-th step of a plan and emit corresponding events
+func execStep(ctx context.Context, opts ExecOption, i int, step Executable) error {
+	if opts.EventSink == nil {
+		return step.Exec(ctx, opts)
+	}
+	ctx = context.WithValue(ctx, stepIndexKey{}, i+1)
+	desc := fmt.Sprintf(`%v`, step)
+	emitEvent(ctx, opts, Event{Type: EventStepStart, Description: desc})
+	start := time.Now()
+	e := step.Exec(ctx, opts)
+	evt := Event{
+		Type:        EventStepFinish,
+		Description: desc,
+		Duration:    time.Since(start).Seconds(),
+	}
+	if e != nil {
+		evt.Type = EventStepError
+		evt.Error = e.Error()
+	}
+	emitEvent(ctx, opts, evt)
+	return e
+}
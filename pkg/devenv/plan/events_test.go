@@ -0,0 +1,69 @@
+package plan
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+// logExecutable emits a container log event and fails
+type logExecutable string
+
+func (exec logExecutable) Exec(ctx context.Context, opts ExecOption) error {
+	emitEvent(ctx, opts, Event{Type: EventContainerLog, Container: "db", Line: "ready"})
+	return errors.New("oops")
+}
+
+func (exec logExecutable) String() string {
+	return string(exec)
+}
+
+func TestJsonEventSink(t *testing.T) {
+	var buf bytes.Buffer
+	steps := []Executable{
+		&funcExecutable{name: "first"},
+		logExecutable("second"),
+		&funcExecutable{name: "not started"},
+	}
+	e := NewExecutionPlan(nil, steps...).Execute(context.Background(), func(opt *ExecOption) {
+		opt.StateStore = nil
+		opt.EventSink = NewJsonEventSink(&buf)
+	})
+	if e == nil {
+		t.Fatalf("expected error of failed step")
+	}
+
+	var events []Event
+	decoder := json.NewDecoder(&buf)
+	for decoder.More() {
+		var evt Event
+		if e := decoder.Decode(&evt); e != nil {
+			t.Fatalf("invalid event: %v", e)
+		}
+		if evt.Time.IsZero() {
+			t.Errorf("expected time of event %v", evt.Type)
+		}
+		events = append(events, evt)
+	}
+	expected := []Event{
+		{Type: EventPlanStart, Steps: 3},
+		{Type: EventStepStart, Step: 1, Description: "first"},
+		{Type: EventStepFinish, Step: 1, Description: "first"},
+		{Type: EventStepStart, Step: 2, Description: "second"},
+		{Type: EventContainerLog, Step: 2, Container: "db", Line: "ready"},
+		{Type: EventStepError, Step: 2, Description: "second", Error: "oops"},
+		{Type: EventPlanFinish, Steps: 3, Step: 2, Status: StatusFailure, Error: "oops"},
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("expected %d events, but got %v", len(expected), events)
+	}
+	for i := range expected {
+		actual := events[i]
+		actual.Time, actual.Duration = expected[i].Time, 0
+		if actual != expected[i] {
+			t.Errorf("expected event %+v, but got %+v", expected[i], actual)
+		}
+	}
+}
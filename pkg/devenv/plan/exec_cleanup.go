@@ -11,6 +11,21 @@ import (
 
 type pruneExecutable struct{}
 
+// filters returns prune filters. When project is set, only resources labeled with given docker compose project are pruned
+func (pruneExecutable) filters(project string, args ...filters.KeyValuePair) filters.Args {
+	if len(project) != 0 {
+		args = append(args, filters.Arg("label", fmt.Sprintf(`%s=%s`, LabelComposeProject, project)))
+	}
+	return filters.NewArgs(args...)
+}
+
+func (pruneExecutable) describe(desc string, project string) string {
+	if len(project) == 0 {
+		return desc
+	}
+	return fmt.Sprintf(`%s of project [%s]`, desc, project)
+}
+
 func (pruneExecutable) formatSize(size uint64) string {
 	units := []string{"B", "KB", "MB", "GB"}
 	v := float64(size)
@@ -42,15 +57,17 @@ func (exec pruneExecutable) printVerboseReport(header string, values[]string, re
 type PruneContainersExecutable struct {
 	pruneExecutable
 	ApiClient *dockerclient.Client
+	// Project optional, docker compose project name. When set, only containers of given project are pruned
+	Project string
 }
 
 func (exec *PruneContainersExecutable) Exec(ctx context.Context, opts ExecOption) error {
 	if opts.DryRun {
-		fmt.Printf("- %v\n", exec)
+		printPlanned(exec)
 		return nil
 	}
 	logger.WithContext(ctx).Infof(`Pruning containers...`)
-	report, e := exec.ApiClient.ContainersPrune(ctx, filters.NewArgs())
+	report, e := exec.ApiClient.ContainersPrune(ctx, exec.filters(exec.Project))
 	if e != nil {
 		return e
 	}
@@ -64,21 +81,23 @@ func (exec *PruneContainersExecutable) Exec(ctx context.Context, opts ExecOption
 }
 
 func (exec *PruneContainersExecutable) String() string {
-	return `prune containers`
+	return exec.describe(`prune containers`, exec.Project)
 }
 
 type PruneVolumesExecutable struct {
 	pruneExecutable
 	ApiClient *dockerclient.Client
+	// Project optional, docker compose project name. When set, only volumes of given project are pruned
+	Project string
 }
 
 func (exec *PruneVolumesExecutable) Exec(ctx context.Context, opts ExecOption) error {
 	if opts.DryRun {
-		fmt.Printf("- %v\n", exec)
+		printPlanned(exec)
 		return nil
 	}
 	logger.WithContext(ctx).Infof(`Pruning volumes...`)
-	report, e := exec.ApiClient.VolumesPrune(ctx, filters.NewArgs(filters.Arg("label!", "devenv.persist")))
+	report, e := exec.ApiClient.VolumesPrune(ctx, exec.filters(exec.Project, filters.Arg("label!", LabelPersist)))
 	if e != nil {
 		return e
 	}
@@ -92,21 +111,23 @@ func (exec *PruneVolumesExecutable) Exec(ctx context.Context, opts ExecOption) e
 }
 
 func (exec *PruneVolumesExecutable) String() string {
-	return `prune volumes`
+	return exec.describe(`prune volumes`, exec.Project)
 }
 
 type PruneImagesExecutable struct {
 	pruneExecutable
 	ApiClient *dockerclient.Client
+	// Project optional, docker compose project name. When set, only images of given project are pruned
+	Project string
 }
 
 func (exec *PruneImagesExecutable) Exec(ctx context.Context, opts ExecOption) error {
 	if opts.DryRun {
-		fmt.Printf("- %v\n", exec)
+		printPlanned(exec)
 		return nil
 	}
 	logger.WithContext(ctx).Infof(`Pruning images...`)
-	report, e := exec.ApiClient.ImagesPrune(ctx, filters.NewArgs())
+	report, e := exec.ApiClient.ImagesPrune(ctx, exec.filters(exec.Project))
 	if e != nil {
 		return e
 	}
@@ -122,5 +143,5 @@ func (exec *PruneImagesExecutable) Exec(ctx context.Context, opts ExecOption) er
 }
 
 func (exec *PruneImagesExecutable) String() string {
-	return `prune images`
+	return exec.describe(`prune images`, exec.Project)
 }
@@ -0,0 +1,91 @@
+package plan
+
+import (
+	"context"
+	"fmt"
+	"github.com/stonedu1011/devenvctl/pkg/devenv"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestCleanupPlan(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   devenv.PrunePolicy
+		noPrune  bool
+		expected []string
+	}{
+		{name: "default", expected: []string{
+			"prune containers of project [test]", "prune volumes of project [test]", "prune images of project [test]",
+		}},
+		{name: "profile", policy: devenv.PruneProfile, expected: []string{
+			"prune containers of project [test]", "prune volumes of project [test]", "prune images of project [test]",
+		}},
+		{name: "global", policy: devenv.PruneGlobal, expected: []string{"prune containers", "prune volumes", "prune images"}},
+		{name: "none", policy: devenv.PruneNone},
+		{name: "disabled by flag", policy: devenv.PruneGlobal, noPrune: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := &devenv.Profile{ProfileMetadata: devenv.ProfileMetadata{Name: "Test"}, Prune: test.policy}
+			pl := NewDockerComposePlanner(p, t.TempDir(), func(cfg *PlannerConfig) {
+				cfg.NoPrune = test.noPrune
+			})
+			execs := pl.cleanupPlan()
+			if len(execs) != len(test.expected) {
+				t.Fatalf("expected %v, but got %v", test.expected, execs)
+			}
+			for i := range execs {
+				if desc := fmt.Sprint(execs[i]); desc != test.expected[i] {
+					t.Errorf("expected %q, but got %q", test.expected[i], desc)
+				}
+			}
+		})
+	}
+}
+
+func TestPruneExecutables(t *testing.T) {
+	tests := []struct {
+		name     string
+		project  string
+		expected []string
+	}{
+		{name: "project", project: "test", expected: []string{
+			`"label":{"com.docker.compose.project=test":true}`,
+			`"label":{"com.docker.compose.project=test":true}`,
+			`"label":{"com.docker.compose.project=test":true}`,
+		}},
+		{name: "global", expected: []string{``, `"label!":{"devenv.persist":true}`, ``}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake, client := newFakeDocker(t, map[string]interface{}{
+				"POST /containers/prune": map[string]interface{}{"ContainersDeleted": []string{"c1"}, "SpaceReclaimed": 1024},
+				"POST /volumes/prune":    map[string]interface{}{"VolumesDeleted": []string{}},
+				"POST /images/prune":     map[string]interface{}{"ImagesDeleted": []map[string]interface{}{{"Deleted": "sha256:abc"}}},
+			})
+			execs := []Executable{
+				&PruneContainersExecutable{ApiClient: client, Project: test.project},
+				&PruneVolumesExecutable{ApiClient: client, Project: test.project},
+				&PruneImagesExecutable{ApiClient: client, Project: test.project},
+			}
+			for i, path := range []string{"POST /containers/prune", "POST /volumes/prune", "POST /images/prune"} {
+				if e := execs[i].Exec(context.Background(), DefaultExecOption); e != nil {
+					t.Fatalf("unexpected error: %v", e)
+				}
+				reqs := fake.Requested(path)
+				if len(reqs) != 1 {
+					t.Fatalf("expected single request of %s, but got %v", path, reqs)
+				}
+				query, _ := url.QueryUnescape(reqs[0])
+				switch {
+				case !containsAll(query, test.expected[i]):
+					t.Errorf("expected filters %s, but got %s", test.expected[i], query)
+				case len(test.project) == 0 && strings.Contains(query, LabelComposeProject):
+					t.Errorf("expected no project filter, but got %s", query)
+				}
+			}
+		})
+	}
+}
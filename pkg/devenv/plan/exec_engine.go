@@ -0,0 +1,805 @@
+package plan
+
+import (
+	"archive/tar"
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/network"
+	"github.com/docker/docker/api/types/volume"
+	dockerclient "github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/docker/go-connections/nat"
+	"github.com/stonedu1011/devenvctl/pkg/devenv"
+	"github.com/stonedu1011/devenvctl/pkg/devenv/compose"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ResourceNetwork   = `network`
+	ResourceVolume    = `volume`
+	ResourceImage     = `image`
+	ResourceContainer = `container`
+)
+
+// EngineError is returned by Docker Engine executables. It identifies the resource and operation that failed.
+type EngineError struct {
+	// Op the failed operation, e.g. "create", "start", "pull"
+	Op string
+	// Resource one of ResourceNetwork, ResourceVolume, ResourceImage or ResourceContainer
+	Resource string
+	// Name name of the resource
+	Name string
+	// Service the compose service the resource belongs to, if applicable
+	Service string
+	Err     error
+}
+
+func (e *EngineError) Error() string {
+	if len(e.Service) != 0 {
+		return fmt.Sprintf(`unable to %s %s [%s] of service [%s]: %v`, e.Op, e.Resource, e.Name, e.Service, e.Err)
+	}
+	return fmt.Sprintf(`unable to %s %s [%s]: %v`, e.Op, e.Resource, e.Name, e.Err)
+}
+
+func (e *EngineError) Unwrap() error {
+	return e.Err
+}
+
+/**********************
+	Networks & Volumes
+ **********************/
+
+// EngineResourcesExecutable create networks and named volumes of compose project, if not exist.
+type EngineResourcesExecutable struct {
+	ApiClient *dockerclient.Client
+	Project   *compose.Project
+}
+
+func (exec *EngineResourcesExecutable) Exec(ctx context.Context, opts ExecOption) error {
+	if opts.DryRun {
+		printPlanned(exec)
+		return nil
+	}
+	for _, key := range sortedKeys(exec.Project.Networks) {
+		if e := exec.ensureNetwork(ctx, key, exec.Project.Networks[key]); e != nil {
+			return e
+		}
+	}
+	for _, key := range sortedKeys(exec.Project.Volumes) {
+		if e := exec.ensureVolume(ctx, key, exec.Project.Volumes[key]); e != nil {
+			return e
+		}
+	}
+	return nil
+}
+
+func (exec *EngineResourcesExecutable) String() string {
+	names := make([]string, 0, len(exec.Project.Networks)+len(exec.Project.Volumes))
+	for _, key := range sortedKeys(exec.Project.Networks) {
+		names = append(names, "network "+exec.Project.NetworkName(key))
+	}
+	for _, key := range sortedKeys(exec.Project.Volumes) {
+		names = append(names, "volume "+exec.Project.VolumeName(key))
+	}
+	return fmt.Sprintf("create networks and volumes: \n    %s", strings.Join(names, "\n    "))
+}
+
+func (exec *EngineResourcesExecutable) ensureNetwork(ctx context.Context, key string, n *compose.Network) error {
+	name := exec.Project.NetworkName(key)
+	_, e := exec.ApiClient.NetworkInspect(ctx, name, types.NetworkInspectOptions{})
+	switch {
+	case e == nil:
+		return nil
+	case !dockerclient.IsErrNotFound(e):
+		return &EngineError{Op: "inspect", Resource: ResourceNetwork, Name: name, Err: e}
+	case n.External:
+		return &EngineError{Op: "find", Resource: ResourceNetwork, Name: name, Err: errors.New("external network not found")}
+	}
+	req := types.NetworkCreate{
+		Driver:   n.Driver,
+		Internal: n.Internal,
+		Labels: mergeLabels(n.Labels.Values(), map[string]string{
+			LabelComposeProject: exec.Project.Name,
+			LabelComposeNetwork: key,
+		}),
+	}
+	if n.IPAM != nil {
+		req.IPAM = &network.IPAM{Driver: n.IPAM.Driver}
+		for _, c := range n.IPAM.Config {
+			req.IPAM.Config = append(req.IPAM.Config, network.IPAMConfig{Subnet: c.Subnet, IPRange: c.IPRange, Gateway: c.Gateway})
+		}
+	}
+	if _, e := exec.ApiClient.NetworkCreate(ctx, name, req); e != nil {
+		return &EngineError{Op: "create", Resource: ResourceNetwork, Name: name, Err: e}
+	}
+	logger.WithContext(ctx).Infof(`Network [%s] Created`, name)
+	return nil
+}
+
+func (exec *EngineResourcesExecutable) ensureVolume(ctx context.Context, key string, v *compose.Volume) error {
+	name := exec.Project.VolumeName(key)
+	_, e := exec.ApiClient.VolumeInspect(ctx, name)
+	switch {
+	case e == nil:
+		return nil
+	case !dockerclient.IsErrNotFound(e):
+		return &EngineError{Op: "inspect", Resource: ResourceVolume, Name: name, Err: e}
+	case v.External:
+		return &EngineError{Op: "find", Resource: ResourceVolume, Name: name, Err: errors.New("external volume not found")}
+	}
+	if _, e := exec.ApiClient.VolumeCreate(ctx, volume.CreateOptions{
+		Name:       name,
+		Driver:     v.Driver,
+		DriverOpts: v.DriverOpts,
+		Labels: mergeLabels(v.Labels.Values(), map[string]string{
+			LabelComposeProject: exec.Project.Name,
+			LabelComposeVolume:  key,
+		}),
+	}); e != nil {
+		return &EngineError{Op: "create", Resource: ResourceVolume, Name: name, Err: e}
+	}
+	logger.WithContext(ctx).Infof(`Volume [%s] Created`, name)
+	return nil
+}
+
+/**********************
+	Images
+ **********************/
+
+// EngineImagesExecutable pull or build images of given services, if not exist.
+// Services with "build" are built, others are pulled.
+type EngineImagesExecutable struct {
+	ApiClient *dockerclient.Client
+	Project   *compose.Project
+	Services  []string
+}
+
+func (exec *EngineImagesExecutable) Exec(ctx context.Context, opts ExecOption) error {
+	if opts.DryRun {
+		printPlanned(exec)
+		return nil
+	}
+	for _, name := range exec.Services {
+		svc := exec.Project.Services[name]
+		img := exec.Project.ImageName(name)
+		switch _, _, e := exec.ApiClient.ImageInspectWithRaw(ctx, img); {
+		case e == nil:
+			continue
+		case !dockerclient.IsErrNotFound(e):
+			return &EngineError{Op: "inspect", Resource: ResourceImage, Name: img, Service: name, Err: e}
+		case svc.Build != nil:
+			if e := exec.build(ctx, svc, img, opts); e != nil {
+				return &EngineError{Op: "build", Resource: ResourceImage, Name: img, Service: name, Err: e}
+			}
+		default:
+			if e := exec.pull(ctx, img, opts); e != nil {
+				return &EngineError{Op: "pull", Resource: ResourceImage, Name: img, Service: name, Err: e}
+			}
+		}
+	}
+	return nil
+}
+
+func (exec *EngineImagesExecutable) String() string {
+	return fmt.Sprintf(`pull or build images if not exist: %s`, strings.Join(exec.Services, ", "))
+}
+
+func (exec *EngineImagesExecutable) pull(ctx context.Context, img string, opts ExecOption) error {
+	logger.WithContext(ctx).Infof(`Image [%s] Pulling ...`, img)
+	rc, e := exec.ApiClient.ImagePull(ctx, img, image.PullOptions{})
+	if e != nil {
+		return e
+	}
+	defer func() { _ = rc.Close() }()
+	if e := readEngineMessages(rc, opts.Verbose); e != nil {
+		return e
+	}
+	logger.WithContext(ctx).Infof(`Image [%s] Pulled`, img)
+	return nil
+}
+
+func (exec *EngineImagesExecutable) build(ctx context.Context, svc *compose.Service, img string, opts ExecOption) error {
+	logger.WithContext(ctx).Infof(`Image [%s] Building ...`, img)
+	pr, pw := io.Pipe()
+	go func() {
+		_ = pw.CloseWithError(tarDir(pw, svc.Build.Context))
+	}()
+	defer func() { _ = pr.Close() }()
+
+	args := map[string]*string{}
+	for k, v := range svc.Build.Args {
+		args[k] = v
+	}
+	resp, e := exec.ApiClient.ImageBuild(ctx, pr, types.ImageBuildOptions{
+		Tags:        []string{img},
+		Dockerfile:  svc.Build.Dockerfile,
+		BuildArgs:   args,
+		Target:      svc.Build.Target,
+		Labels:      map[string]string{LabelComposeProject: exec.Project.Name},
+		Remove:      true,
+		ForceRemove: true,
+	})
+	if e != nil {
+		return e
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if e := readEngineMessages(resp.Body, opts.Verbose); e != nil {
+		return e
+	}
+	logger.WithContext(ctx).Infof(`Image [%s] Built`, img)
+	return nil
+}
+
+/**********************
+	Containers
+ **********************/
+
+// EngineUpExecutable (re)create and start containers of given services, in given order.
+// Existing containers of the services are removed first.
+type EngineUpExecutable struct {
+	ApiClient *dockerclient.Client
+	Project   *compose.Project
+	// Services names of services to start, in dependency order
+	Services []string
+	// RemoveOrphans remove containers of the project that are not defined in compose file
+	RemoveOrphans bool
+}
+
+func (exec *EngineUpExecutable) Exec(ctx context.Context, opts ExecOption) error {
+	if opts.DryRun {
+		printPlanned(exec)
+		return nil
+	}
+	containers, e := ListComposeContainers(ctx, exec.ApiClient, exec.Project.Name)
+	if e != nil {
+		return &EngineError{Op: "list", Resource: ResourceContainer, Name: exec.Project.Name, Err: e}
+	}
+	if exec.RemoveOrphans {
+		for i := range containers {
+			if _, ok := exec.Project.Services[containers[i].Labels[LabelComposeService]]; !ok {
+				if e := removeContainer(ctx, exec.ApiClient, &containers[i], false); e != nil {
+					return e
+				}
+			}
+		}
+	}
+	for _, name := range exec.Services {
+		// recreate
+		for i := range containers {
+			if containers[i].Labels[LabelComposeService] == name {
+				if e := removeContainer(ctx, exec.ApiClient, &containers[i], false); e != nil {
+					return e
+				}
+			}
+		}
+		if e := exec.start(ctx, exec.Project.Services[name]); e != nil {
+			return e
+		}
+	}
+	return nil
+}
+
+func (exec *EngineUpExecutable) String() string {
+	return fmt.Sprintf(`recreate and start containers: %s`, strings.Join(exec.Services, ", "))
+}
+
+func (exec *EngineUpExecutable) start(ctx context.Context, svc *compose.Service) error {
+	cName := exec.Project.ContainerName(svc.Name)
+	// container with same name may still exist, only if it belongs to this project it can be removed.
+	// Otherwise, it's a conflict, the same as docker compose would report
+	if info, e := exec.ApiClient.ContainerInspect(ctx, cName); e == nil {
+		var project string
+		if info.Config != nil {
+			project = info.Config.Labels[LabelComposeProject]
+		}
+		if project != exec.Project.Name {
+			owner := "not managed by docker compose"
+			if len(project) != 0 {
+				owner = fmt.Sprintf(`of project [%s]`, project)
+			}
+			return &EngineError{Op: "create", Resource: ResourceContainer, Name: cName, Service: svc.Name,
+				Err: fmt.Errorf(`conflict, the name is already in use by container [%.12s] %s. Remove or rename that container first`, info.ID, owner)}
+		}
+		if e := exec.ApiClient.ContainerRemove(ctx, cName, container.RemoveOptions{Force: true}); e != nil {
+			return &EngineError{Op: "remove", Resource: ResourceContainer, Name: cName, Service: svc.Name, Err: e}
+		}
+	}
+
+	cfg, hostCfg, networks, e := exec.containerConfig(svc)
+	if e != nil {
+		return &EngineError{Op: "configure", Resource: ResourceContainer, Name: cName, Service: svc.Name, Err: e}
+	}
+	id, e := createContainer(ctx, exec.ApiClient, cName, svc.Name, cfg, hostCfg, networks)
+	if e != nil {
+		return e
+	}
+	if e := exec.ApiClient.ContainerStart(ctx, id, container.StartOptions{}); e != nil {
+		return &EngineError{Op: "start", Resource: ResourceContainer, Name: cName, Service: svc.Name, Err: e}
+	}
+	logger.WithContext(ctx).Infof(`Container [%s] Started`, cName)
+	return nil
+}
+
+type endpoint struct {
+	name     string
+	endpoint *network.EndpointSettings
+}
+
+func (exec *EngineUpExecutable) containerConfig(svc *compose.Service) (*container.Config, *container.HostConfig, []endpoint, error) {
+	exposed, bindings, e := nat.ParsePortSpecs(toStrings(svc.Ports))
+	if e != nil {
+		return nil, nil, nil, e
+	}
+	for _, p := range svc.Expose {
+		port, e := nat.NewPort(nat.SplitProtoPort(string(p)))
+		if e != nil {
+			return nil, nil, nil, e
+		}
+		exposed[port] = struct{}{}
+	}
+	cfg := &container.Config{
+		Image:        exec.Project.ImageName(svc.Name),
+		Hostname:     svc.Hostname,
+		Cmd:          []string(svc.Command),
+		Entrypoint:   []string(svc.Entrypoint),
+		Env:          svc.Environment.List(),
+		WorkingDir:   svc.WorkingDir,
+		User:         svc.User,
+		Tty:          svc.Tty,
+		OpenStdin:    svc.StdinOpen,
+		ExposedPorts: exposed,
+		Labels: mergeLabels(svc.Labels.Values(), map[string]string{
+			LabelComposeProject:         exec.Project.Name,
+			LabelComposeService:         svc.Name,
+			LabelComposeOneOff:          "False",
+			LabelComposeContainerNumber: "1",
+			LabelComposeWorkingDir:      exec.Project.WorkingDir,
+			LabelComposeConfigFiles:     exec.Project.ConfigPath,
+		}),
+	}
+	if svc.Healthcheck != nil {
+		if cfg.Healthcheck, e = healthConfig(svc.Healthcheck); e != nil {
+			return nil, nil, nil, e
+		}
+	}
+	restart, e := restartPolicy(svc.Restart)
+	if e != nil {
+		return nil, nil, nil, e
+	}
+	hostCfg := &container.HostConfig{
+		PortBindings:  bindings,
+		RestartPolicy: restart,
+		ExtraHosts:    svc.ExtraHosts,
+		Privileged:    svc.Privileged,
+		CapAdd:        svc.CapAdd,
+	}
+	for _, v := range svc.Volumes {
+		if v.Type == compose.VolumeTypeVolume && len(v.Source) != 0 {
+			v.Source = exec.Project.VolumeName(v.Source)
+		}
+		bind := v.Source + ":" + v.Target
+		switch {
+		case len(v.Source) == 0:
+			// anonymous volume
+			bind = v.Target
+		case len(v.Mode) != 0:
+			bind = bind + ":" + v.Mode
+		case v.ReadOnly:
+			bind = bind + ":ro"
+		}
+		hostCfg.Binds = append(hostCfg.Binds, bind)
+	}
+
+	keys := make([]string, 0, len(svc.Networks))
+	for k := range svc.Networks {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	networks := make([]endpoint, len(keys))
+	for i, k := range keys {
+		aliases := []string{svc.Name}
+		if n := svc.Networks[k]; n != nil {
+			aliases = append(aliases, n.Aliases...)
+		}
+		networks[i] = endpoint{
+			name:     exec.Project.NetworkName(k),
+			endpoint: &network.EndpointSettings{Aliases: aliases},
+		}
+	}
+	return cfg, hostCfg, networks, nil
+}
+
+// EngineDownExecutable stop and remove containers of given services, in reverse order.
+// If no service is specified, all containers of the project, including orphans, are removed, as well as its networks.
+type EngineDownExecutable struct {
+	ApiClient *dockerclient.Client
+	Project   *compose.Project
+	// Services optional, names of services to stop, in dependency order
+	Services []string
+	// RemoveVolumes remove anonymous volumes attached to the containers
+	RemoveVolumes bool
+}
+
+func (exec *EngineDownExecutable) Exec(ctx context.Context, opts ExecOption) error {
+	if opts.DryRun {
+		printPlanned(exec)
+		return nil
+	}
+	containers, e := ListComposeContainers(ctx, exec.ApiClient, exec.Project.Name)
+	if e != nil {
+		return &EngineError{Op: "list", Resource: ResourceContainer, Name: exec.Project.Name, Err: e}
+	}
+	order := exec.Services
+	if len(order) == 0 {
+		if order, e = exec.Project.ServiceOrder(); e != nil {
+			return e
+		}
+	}
+	// stop and remove in reverse order, orphans go first
+	rank := map[string]int{}
+	for i, name := range order {
+		rank[name] = len(order) - i
+	}
+	sort.SliceStable(containers, func(i, j int) bool {
+		return rank[containers[i].Labels[LabelComposeService]] < rank[containers[j].Labels[LabelComposeService]]
+	})
+	for i := range containers {
+		_, inScope := rank[containers[i].Labels[LabelComposeService]]
+		if len(exec.Services) != 0 && !inScope {
+			continue
+		}
+		if e := removeContainer(ctx, exec.ApiClient, &containers[i], exec.RemoveVolumes); e != nil {
+			return e
+		}
+	}
+	if len(exec.Services) != 0 {
+		return nil
+	}
+	return exec.removeNetworks(ctx)
+}
+
+func (exec *EngineDownExecutable) String() string {
+	if len(exec.Services) == 0 {
+		return fmt.Sprintf(`stop and remove containers and networks of project: %s`, exec.Project.Name)
+	}
+	return fmt.Sprintf(`stop and remove containers: %s`, strings.Join(exec.Services, ", "))
+}
+
+func (exec *EngineDownExecutable) removeNetworks(ctx context.Context) error {
+	for _, key := range sortedKeys(exec.Project.Networks) {
+		if exec.Project.Networks[key].External {
+			continue
+		}
+		name := exec.Project.NetworkName(key)
+		switch e := exec.ApiClient.NetworkRemove(ctx, name); {
+		case e == nil:
+			logger.WithContext(ctx).Infof(`Network [%s] Removed`, name)
+		case !dockerclient.IsErrNotFound(e):
+			return &EngineError{Op: "remove", Resource: ResourceNetwork, Name: name, Err: e}
+		}
+	}
+	return nil
+}
+
+/**********************
+	One-off Containers
+ **********************/
+
+// EngineRunExecutable run a one-off container of given service and wait for it to finish,
+// similar to "docker compose run --rm --no-deps". Missing networks, volumes and image are created first.
+// Container output is streamed, and non-zero exit code is reported as error.
+type EngineRunExecutable struct {
+	ApiClient *dockerclient.Client
+	Project   *compose.Project
+	Service   string
+	Desc      string
+}
+
+func (exec *EngineRunExecutable) Exec(ctx context.Context, opts ExecOption) error {
+	if opts.DryRun {
+		printPlanned(exec)
+		return nil
+	}
+	svc, ok := exec.Project.Services[exec.Service]
+	if !ok {
+		return fmt.Errorf(`service [%s] is not defined in [%s]`, exec.Service, exec.Project.ConfigPath)
+	}
+	prepare := []Executable{
+		&EngineResourcesExecutable{ApiClient: exec.ApiClient, Project: exec.Project},
+		&EngineImagesExecutable{ApiClient: exec.ApiClient, Project: exec.Project, Services: []string{svc.Name}},
+	}
+	for _, step := range prepare {
+		if e := step.Exec(ctx, opts); e != nil {
+			return e
+		}
+	}
+
+	cName := fmt.Sprintf(`%s-%s-run-%x`, exec.Project.Name, svc.Name, time.Now().UnixNano())
+	cfg, hostCfg, networks, e := (&EngineUpExecutable{Project: exec.Project}).containerConfig(svc)
+	if e != nil {
+		return &EngineError{Op: "configure", Resource: ResourceContainer, Name: cName, Service: svc.Name, Err: e}
+	}
+	cfg.Labels[LabelComposeOneOff] = "True"
+	hostCfg.RestartPolicy = container.RestartPolicy{}
+	id, e := createContainer(ctx, exec.ApiClient, cName, svc.Name, cfg, hostCfg, networks)
+	if e != nil {
+		return e
+	}
+	defer func() {
+		if e := exec.ApiClient.ContainerRemove(context.WithoutCancel(ctx), id, container.RemoveOptions{RemoveVolumes: true, Force: true}); e != nil {
+			logger.WithContext(ctx).Warnf(`unable to remove container [%s]: %v`, cName, e)
+		}
+	}()
+
+	// start waiting before the container is started, so the exit is not missed
+	waitCh, errCh := exec.ApiClient.ContainerWait(ctx, id, container.WaitConditionNextExit)
+	if e := exec.ApiClient.ContainerStart(ctx, id, container.StartOptions{}); e != nil {
+		return &EngineError{Op: "start", Resource: ResourceContainer, Name: cName, Service: svc.Name, Err: e}
+	}
+	if e := exec.streamLogs(ctx, opts, id, cfg.Tty); e != nil {
+		logger.WithContext(ctx).Warnf(`unable to read logs of container [%s]: %v`, cName, e)
+	}
+	select {
+	case resp := <-waitCh:
+		switch {
+		case resp.Error != nil:
+			return &EngineError{Op: "wait", Resource: ResourceContainer, Name: cName, Service: svc.Name, Err: errors.New(resp.Error.Message)}
+		case resp.StatusCode != 0:
+			return &EngineError{Op: "run", Resource: ResourceContainer, Name: cName, Service: svc.Name, Err: fmt.Errorf(`exited with code %d`, resp.StatusCode)}
+		}
+	case e := <-errCh:
+		return &EngineError{Op: "wait", Resource: ResourceContainer, Name: cName, Service: svc.Name, Err: e}
+	}
+	return nil
+}
+
+func (exec *EngineRunExecutable) String() string {
+	if len(exec.Desc) == 0 {
+		exec.Desc = "run container"
+	}
+	return fmt.Sprintf(`%s: %s`, exec.Desc, exec.Service)
+}
+
+// streamLogs print container's output line by line until the container stops
+func (exec *EngineRunExecutable) streamLogs(ctx context.Context, opts ExecOption, id string, tty bool) error {
+	reader, e := exec.ApiClient.ContainerLogs(ctx, id, container.LogsOptions{ShowStdout: true, ShowStderr: true, Follow: true})
+	if e != nil {
+		return e
+	}
+	defer func() { _ = reader.Close() }()
+	pr, pw := io.Pipe()
+	go func() {
+		var e error
+		if tty {
+			_, e = io.Copy(pw, reader)
+		} else {
+			_, e = stdcopy.StdCopy(pw, pw, reader)
+		}
+		_ = pw.CloseWithError(e)
+	}()
+	var out io.Writer = os.Stdout
+	if w := StepOutput(ctx); w != nil {
+		out = w
+	}
+	scanner := bufio.NewScanner(pr)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		emitEvent(ctx, opts, Event{Type: EventContainerLog, Description: exec.String(), Container: exec.Service, Line: line})
+		_, _ = fmt.Fprintf(out, "%s | %s\n", exec.Service, devenv.MaskSecrets(line))
+	}
+	return scanner.Err()
+}
+
+/**********************
+	Helpers
+ **********************/
+
+// createContainer create container with given configs and connect it to given networks. Returns ID of the container
+func createContainer(ctx context.Context, client *dockerclient.Client, cName, svcName string,
+	cfg *container.Config, hostCfg *container.HostConfig, networks []endpoint) (string, error) {
+	// only one network can be specified at creation with older API versions. Others are connected after creation
+	netCfg := &network.NetworkingConfig{EndpointsConfig: map[string]*network.EndpointSettings{}}
+	if len(networks) != 0 {
+		netCfg.EndpointsConfig[networks[0].name] = networks[0].endpoint
+		networks = networks[1:]
+	}
+	resp, e := client.ContainerCreate(ctx, cfg, hostCfg, netCfg, nil, cName)
+	if e != nil {
+		return "", &EngineError{Op: "create", Resource: ResourceContainer, Name: cName, Service: svcName, Err: e}
+	}
+	for _, n := range networks {
+		if e := client.NetworkConnect(ctx, n.name, resp.ID, n.endpoint); e != nil {
+			return "", &EngineError{Op: "connect", Resource: ResourceContainer, Name: cName, Service: svcName, Err: e}
+		}
+	}
+	logger.WithContext(ctx).Infof(`Container [%s] Created`, cName)
+	return resp.ID, nil
+}
+
+func removeContainer(ctx context.Context, client *dockerclient.Client, c *types.Container, removeVolumes bool) error {
+	name := c.ID
+	if len(c.Names) != 0 {
+		name = strings.TrimPrefix(c.Names[0], "/")
+	}
+	svc := c.Labels[LabelComposeService]
+	if c.State == "running" {
+		if e := client.ContainerStop(ctx, c.ID, container.StopOptions{}); e != nil {
+			return &EngineError{Op: "stop", Resource: ResourceContainer, Name: name, Service: svc, Err: e}
+		}
+		logger.WithContext(ctx).Infof(`Container [%s] Stopped`, name)
+	}
+	if e := client.ContainerRemove(ctx, c.ID, container.RemoveOptions{RemoveVolumes: removeVolumes, Force: true}); e != nil && !dockerclient.IsErrNotFound(e) {
+		return &EngineError{Op: "remove", Resource: ResourceContainer, Name: name, Service: svc, Err: e}
+	}
+	logger.WithContext(ctx).Infof(`Container [%s] Removed`, name)
+	return nil
+}
+
+func healthConfig(hc *compose.Healthcheck) (*container.HealthConfig, error) {
+	if hc.Disable {
+		return &container.HealthConfig{Test: []string{"NONE"}}, nil
+	}
+	ret := container.HealthConfig{
+		Test:    hc.Test,
+		Retries: hc.Retries,
+	}
+	for _, d := range []struct {
+		value string
+		dest  *time.Duration
+	}{
+		{hc.Interval, &ret.Interval}, {hc.Timeout, &ret.Timeout}, {hc.StartPeriod, &ret.StartPeriod},
+	} {
+		if len(d.value) == 0 {
+			continue
+		}
+		v, e := time.ParseDuration(d.value)
+		if e != nil {
+			return nil, fmt.Errorf(`invalid healthcheck duration "%s": %v`, d.value, e)
+		}
+		*d.dest = v
+	}
+	return &ret, nil
+}
+
+func restartPolicy(restart string) (container.RestartPolicy, error) {
+	mode, count, _ := strings.Cut(restart, ":")
+	ret := container.RestartPolicy{Name: container.RestartPolicyMode(mode)}
+	switch ret.Name {
+	case "":
+		ret.Name = container.RestartPolicyDisabled
+	case container.RestartPolicyDisabled, container.RestartPolicyAlways, container.RestartPolicyUnlessStopped:
+	case container.RestartPolicyOnFailure:
+		if len(count) != 0 {
+			var e error
+			if ret.MaximumRetryCount, e = strconv.Atoi(count); e != nil {
+				return ret, fmt.Errorf(`invalid restart policy "%s"`, restart)
+			}
+		}
+	default:
+		return ret, fmt.Errorf(`invalid restart policy "%s"`, restart)
+	}
+	return ret, nil
+}
+
+// engineMessage is the JSON message streamed by image pull and build API
+type engineMessage struct {
+	Stream      string `json:"stream"`
+	Status      string `json:"status"`
+	Progress    string `json:"progress"`
+	Error       string `json:"error"`
+	ErrorDetail *struct {
+		Message string `json:"message"`
+	} `json:"errorDetail"`
+}
+
+// readEngineMessages consume JSON message stream until end. Returns error if any message is an error
+func readEngineMessages(r io.Reader, verbose bool) error {
+	dec := json.NewDecoder(r)
+	for {
+		var msg engineMessage
+		switch e := dec.Decode(&msg); {
+		case errors.Is(e, io.EOF):
+			return nil
+		case e != nil:
+			return e
+		}
+		switch {
+		case msg.ErrorDetail != nil && len(msg.ErrorDetail.Message) != 0:
+			return errors.New(msg.ErrorDetail.Message)
+		case len(msg.Error) != 0:
+			return errors.New(msg.Error)
+		case !verbose:
+		case len(msg.Stream) != 0:
+			fmt.Print(msg.Stream)
+		case len(msg.Status) != 0 && len(msg.Progress) == 0:
+			fmt.Println(msg.Status)
+		}
+	}
+}
+
+// tarDir write content of given directory as tar stream, used as docker build context
+func tarDir(w io.Writer, dir string) error {
+	tw := tar.NewWriter(w)
+	e := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, e := filepath.Rel(dir, p)
+		if e != nil || rel == "." {
+			return e
+		}
+		info, e := d.Info()
+		if e != nil {
+			return e
+		}
+		var link string
+		if info.Mode()&fs.ModeSymlink != 0 {
+			if link, e = os.Readlink(p); e != nil {
+				return e
+			}
+		}
+		hdr, e := tar.FileInfoHeader(info, link)
+		if e != nil {
+			return e
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if e := tw.WriteHeader(hdr); e != nil {
+			return e
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		f, e := os.Open(p)
+		if e != nil {
+			return e
+		}
+		defer func() { _ = f.Close() }()
+		_, e = io.Copy(tw, f)
+		return e
+	})
+	if e != nil {
+		return e
+	}
+	return tw.Close()
+}
+
+func mergeLabels(labels map[string]string, additional map[string]string) map[string]string {
+	ret := make(map[string]string, len(labels)+len(additional))
+	for k, v := range labels {
+		ret[k] = v
+	}
+	for k, v := range additional {
+		ret[k] = v
+	}
+	return ret
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func toStrings(ports []compose.Port) []string {
+	ret := make([]string, len(ports))
+	for i := range ports {
+		ret[i] = string(ports[i])
+	}
+	return ret
+}
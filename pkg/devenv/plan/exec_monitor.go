@@ -8,6 +8,7 @@ import (
 	"github.com/docker/docker/api/types"
 	"github.com/docker/docker/api/types/container"
 	"github.com/docker/docker/client"
+	"github.com/stonedu1011/devenvctl/pkg/devenv"
 	"github.com/stonedu1011/devenvctl/pkg/utils/tmplutils"
 	"io"
 	"os"
@@ -32,8 +33,8 @@ func ExactNameContainerResolver() ContainerResolver {
 func ComposeContainerResolver(profileName string) ContainerResolver {
 	return func(name string, c *types.Container) string {
 		// first try to use labels
-		if p, ok := c.Labels["com.docker.compose.project"]; ok && p == profileName {
-			if s, ok := c.Labels["com.docker.compose.service"]; ok && s == name {
+		if p, ok := c.Labels[LabelComposeProject]; ok && p == ComposeProjectName(profileName) {
+			if s, ok := c.Labels[LabelComposeService]; ok && s == name {
 				return c.ID
 			}
 		}
@@ -73,14 +74,19 @@ type containerEvent struct {
 type ContainerMonitorExecutable struct {
 	ApiClient *client.Client
 	Names    []string
+	// Timeouts optional, how long to wait for each container to finish, by name. No timeout if absent
+	Timeouts map[string]time.Duration
 	Resolver ContainerResolver
 	Desc     string
+	// LogsOptions overrides how container logs are streamed. When set, the executable works as a log viewer:
+	// stopped containers are included, and end of logs is not reported as container exit.
+	LogsOptions *container.LogsOptions
 	tmpls     map[string]*template.Template
 }
 
 func (exec *ContainerMonitorExecutable) Exec(ctx context.Context, opts ExecOption) error {
 	if opts.DryRun {
-		fmt.Printf("- %v\n", exec)
+		printPlanned(exec)
 		return nil
 	}
 
@@ -94,7 +100,9 @@ func (exec *ContainerMonitorExecutable) Exec(ctx context.Context, opts ExecOptio
 	exec.prepareTemplates()
 
 	// log
-	logger.Infof(`Waiting for %s to finish ...`, exec.Desc)
+	if exec.LogsOptions == nil {
+		logger.Infof(`Waiting for %s to finish ...`, exec.Desc)
+	}
 	if opts.Verbose {
 		logger.Debugf("Containers:")
 		for k, v := range mapping {
@@ -104,14 +112,14 @@ func (exec *ContainerMonitorExecutable) Exec(ctx context.Context, opts ExecOptio
 	}
 
 	// start monitor all containers
+	// Note: channel is not closed, monitoring goroutines stop sending once ctx is cancelled
 	ch := make(chan containerEvent, 1)
-	defer close(ch)
 	for _, name := range exec.Names {
 		cName, ok := mapping[name]
 		if !ok {
 			return fmt.Errorf(`unable to find container for [%s]`, name)
 		}
-		go exec.monitor(ctx, name, cName, ch)
+		go exec.monitor(ctx, name, cName, exec.Timeouts[name], ch)
 	}
 
 	// wait for all containers to finish
@@ -124,13 +132,14 @@ func (exec *ContainerMonitorExecutable) Exec(ctx context.Context, opts ExecOptio
 			switch v := evt.Entry.(type) {
 			case error:
 				finished[evt.Container] = v
-				if errors.Is(v, io.EOF) {
-					exec.printEvent(containerEvent{Container: evt.Container, Entry: "exited with code 0"})
-				} else {
-					exec.printEvent(containerEvent{Container: evt.Container, Entry: fmt.Sprintf("error: %v", e)})
+				switch {
+				case !errors.Is(v, io.EOF):
+					exec.printEvent(ctx, opts, containerEvent{Container: evt.Container, Entry: fmt.Sprintf("error: %v", v)})
+				case exec.LogsOptions == nil:
+					exec.printEvent(ctx, opts, containerEvent{Container: evt.Container, Entry: "exited with code 0"})
 				}
 			case string:
-				exec.printEvent(evt)
+				exec.printEvent(ctx, opts, evt)
 			}
 		}
 	}
@@ -152,13 +161,20 @@ func (exec *ContainerMonitorExecutable) String() string {
 	if len(exec.Desc) == 0 {
 		exec.Desc = "containers"
 	}
+	names := make([]string, len(exec.Names))
+	for i, name := range exec.Names {
+		names[i] = name
+		if timeout, ok := exec.Timeouts[name]; ok {
+			names[i] = fmt.Sprintf(`%s (timeout %v)`, name, timeout)
+		}
+	}
 	switch {
-	case len(exec.Names) == 1:
-		return fmt.Sprintf("%s: %s", exec.Desc, exec.Names[0])
-	case len(exec.Names) == 0:
+	case len(names) == 1:
+		return fmt.Sprintf("%s: %s", exec.Desc, names[0])
+	case len(names) == 0:
 		return "no-op"
 	default:
-		return fmt.Sprintf("%s: \n    %s", exec.Desc, strings.Join(exec.Names, "\n    "))
+		return fmt.Sprintf("%s: \n    %s", exec.Desc, strings.Join(names, "\n    "))
 	}
 }
 
@@ -187,7 +203,14 @@ func (exec *ContainerMonitorExecutable) prepareTemplates() {
 	}
 }
 
-func (exec *ContainerMonitorExecutable) printEvent(evt containerEvent) {
+func (exec *ContainerMonitorExecutable) printEvent(ctx context.Context, opts ExecOption, evt containerEvent) {
+	emitEvent(ctx, opts, Event{
+		Type:        EventContainerLog,
+		Description: exec.Desc,
+		Container:   evt.Container,
+		Line:        strings.TrimRight(evt.Entry.(string), "\r\n"),
+	})
+	evt.Entry = devenv.MaskSecrets(evt.Entry.(string))
 	if !strings.HasSuffix(evt.Entry.(string), "\n") {
 		evt.Entry = evt.Entry.(string) + "\n"
 	}
@@ -200,7 +223,7 @@ func (exec *ContainerMonitorExecutable) printEvent(evt containerEvent) {
 }
 
 func (exec *ContainerMonitorExecutable) resolveContainerNames(ctx context.Context) (map[string]string, error) {
-	containers, e := exec.ApiClient.ContainerList(ctx, container.ListOptions{})
+	containers, e := exec.ApiClient.ContainerList(ctx, container.ListOptions{All: exec.LogsOptions != nil})
 	if e != nil {
 		return nil, e
 	}
@@ -216,15 +239,40 @@ func (exec *ContainerMonitorExecutable) resolveContainerNames(ctx context.Contex
 	return idMapping, nil
 }
 
-func (exec *ContainerMonitorExecutable) monitor(ctx context.Context, name string, cName string, ch chan containerEvent) {
-	reader, e := exec.ApiClient.ContainerLogs(ctx, cName, container.LogsOptions{
+// monitor stream logs of given container until it stops, or until timeout if positive
+func (exec *ContainerMonitorExecutable) monitor(ctx context.Context, name string, cName string, timeout time.Duration, ch chan containerEvent) {
+	logsOpts := container.LogsOptions{
 		ShowStdout: true,
 		ShowStderr: true,
 		Follow:     true,
 		Details:    true,
-	})
+	}
+	if exec.LogsOptions != nil {
+		logsOpts = *exec.LogsOptions
+	}
+	send := func(evt containerEvent) bool {
+		select {
+		case <-ctx.Done():
+			return false
+		case ch <- evt:
+			return true
+		}
+	}
+	logsCtx := ctx
+	if timeout > 0 {
+		var cancelFn context.CancelFunc
+		logsCtx, cancelFn = context.WithTimeout(ctx, timeout)
+		defer cancelFn()
+	}
+	sendErr := func(e error) {
+		if ctx.Err() == nil && errors.Is(logsCtx.Err(), context.DeadlineExceeded) {
+			e = fmt.Errorf(`timed out after %v`, timeout)
+		}
+		send(containerEvent{Container: name, Entry: e})
+	}
+	reader, e := exec.ApiClient.ContainerLogs(logsCtx, cName, logsOpts)
 	if e != nil {
-		ch <- containerEvent{Container: name, Entry: e}
+		sendErr(e)
 		return
 	}
 	defer func() { _ = reader.Close() }()
@@ -232,22 +280,25 @@ func (exec *ContainerMonitorExecutable) monitor(ctx context.Context, name string
 LOOP:
 	for {
 		select {
-		case <-ctx.Done():
+		case <-logsCtx.Done():
+			sendErr(logsCtx.Err())
 			break LOOP
 		default:
 		}
-		switch _, e := reader.Read(header); {
+		switch _, e := io.ReadFull(reader, header); {
 		case e != nil:
-			ch <- containerEvent{Container: name, Entry: e}
+			sendErr(e)
 			break LOOP
 		}
 		size := binary.BigEndian.Uint32(header[4:])
 		buf := make([]byte, size)
-		if _, e = reader.Read(buf); e != nil {
-			ch <- containerEvent{Container: name, Entry: e}
+		if _, e = io.ReadFull(reader, buf); e != nil {
+			sendErr(e)
+			break LOOP
+		}
+		if !send(containerEvent{Container: name, Entry: string(buf)}) {
 			break LOOP
 		}
-		ch <- containerEvent{Container: name, Entry: string(buf)}
 	}
 	return
 }
@@ -0,0 +1,103 @@
+package plan
+
+import (
+	"context"
+	"encoding/binary"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"slices"
+	"sync"
+	"testing"
+)
+
+// multiplexedLogs encode given lines as docker's multiplexed stdout stream
+func multiplexedLogs(lines ...string) []byte {
+	var data []byte
+	for _, line := range lines {
+		header := make([]byte, 8)
+		header[0] = 1
+		binary.BigEndian.PutUint32(header[4:], uint32(len(line)))
+		data = append(append(data, header...), line...)
+	}
+	return data
+}
+
+func TestComposeContainerResolver(t *testing.T) {
+	resolver := ComposeContainerResolver("Test")
+	tests := []struct {
+		name      string
+		service   string
+		container types.Container
+		expected  string
+	}{
+		{name: "by labels", service: "db", expected: "c-db",
+			container: types.Container{ID: "c-db", Labels: map[string]string{LabelComposeProject: "test", LabelComposeService: "db"}}},
+		{name: "other service", service: "db",
+			container: types.Container{ID: "c-app", Labels: map[string]string{LabelComposeProject: "test", LabelComposeService: "app"}}},
+		{name: "other project", service: "db",
+			container: types.Container{ID: "c-db", Labels: map[string]string{LabelComposeProject: "another", LabelComposeService: "db"}}},
+		{name: "by name", service: "db", expected: "/Test-db-1",
+			container: types.Container{ID: "c-db", Names: []string{"/Test-db-1"}}},
+		{name: "name of other service", service: "db",
+			container: types.Container{ID: "c-db", Names: []string{"/Test-db_admin"}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if name := resolver(test.service, &test.container); name != test.expected {
+				t.Errorf("expected %q, but got %q", test.expected, name)
+			}
+		})
+	}
+}
+
+func TestContainerMonitorLogs(t *testing.T) {
+	fake, client := newFakeDocker(t, map[string]interface{}{
+		"GET /containers/json": []map[string]interface{}{
+			{"Id": "c-db", "State": "exited", "Labels": map[string]string{LabelComposeProject: "test", LabelComposeService: "db"}},
+			{"Id": "c-app", "State": "running", "Labels": map[string]string{LabelComposeProject: "test", LabelComposeService: "app"}},
+		},
+		"GET /containers/c-db/logs":  multiplexedLogs("db started\n", "db ready\n"),
+		"GET /containers/c-app/logs": multiplexedLogs("app started\n"),
+	})
+	exec := NewContainerMonitorExecutable(client, func(exec *ContainerMonitorExecutable) {
+		exec.Names = []string{"db", "app"}
+		exec.Desc = "logs"
+		exec.Resolver = ComposeContainerResolver("test")
+		exec.LogsOptions = &container.LogsOptions{ShowStdout: true, Tail: "10"}
+	})
+
+	var mtx sync.Mutex
+	lines := map[string][]string{}
+	e := exec.Exec(context.Background(), ExecOption{EventSink: EventSinkFunc(func(evt Event) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		if evt.Type == EventContainerLog {
+			lines[evt.Container] = append(lines[evt.Container], evt.Line)
+		}
+	})})
+	if e != nil {
+		t.Fatalf("expected end of logs not to be an error, but got %v", e)
+	}
+	if !slices.Equal(lines["db"], []string{"db started", "db ready"}) || !slices.Equal(lines["app"], []string{"app started"}) {
+		t.Errorf("unexpected log lines: %v", lines)
+	}
+	if reqs := fake.Requested("GET /containers/json"); len(reqs) != 1 || !containsAll(reqs[0], "all=1") {
+		t.Errorf("expected stopped containers to be included, but got %v", reqs)
+	}
+	if reqs := fake.Requested("GET /containers/c-db/logs"); len(reqs) != 1 || !containsAll(reqs[0], "stdout=1", "tail=10") {
+		t.Errorf("expected logs options to be used, but got %v", reqs)
+	}
+}
+
+func TestContainerMonitorMissingContainer(t *testing.T) {
+	_, client := newFakeDocker(t, map[string]interface{}{
+		"GET /containers/json": []map[string]interface{}{},
+	})
+	exec := NewContainerMonitorExecutable(client, func(exec *ContainerMonitorExecutable) {
+		exec.Names = []string{"db"}
+		exec.Resolver = ComposeContainerResolver("test")
+	})
+	if e := exec.Exec(context.Background(), ExecOption{}); e == nil || e.Error() != "unable to find container for [db]" {
+		t.Errorf("expected missing container to be reported, but got %v", e)
+	}
+}
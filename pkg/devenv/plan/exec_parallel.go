@@ -0,0 +1,201 @@
+package plan
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sync"
+)
+
+const DefaultConcurrency = 4
+
+// DependentExecutable is an Executable that declares its dependencies on other steps of the same plan.
+// It depends on declared dependencies and the closest preceding step that is not a DependentExecutable.
+// Steps that are not DependentExecutable depend on all preceding steps.
+// In other words, regular steps split the plan into stages, and DependentExecutable within a stage may run concurrently.
+type DependentExecutable interface {
+	Executable
+	Dependencies() []Executable
+}
+
+// WithDependencies wrap given executable as DependentExecutable. Dependencies must be preceding steps of the same plan.
+func WithDependencies(exec Executable, deps ...Executable) DependentExecutable {
+	return &dependentExecutable{Executable: exec, deps: deps}
+}
+
+type dependentExecutable struct {
+	Executable
+	deps []Executable
+}
+
+func (exec *dependentExecutable) Dependencies() []Executable {
+	return exec.deps
+}
+
+func (exec *dependentExecutable) String() string {
+	return fmt.Sprintf(`%v`, exec.Executable)
+}
+
+// executeConcurrently run steps as a DAG with bounded concurrency. The first failure cancels all other steps.
+// Returns index of the failed step and the error, or -1 if all steps succeeded
+func executeConcurrently(ctx context.Context, opts ExecOption, steps []Executable, compensations *compensationRegistry) (int, error) {
+	deps, e := resolveStepDependencies(steps)
+	if e != nil {
+		return -1, e
+	}
+
+	ctx, cancelFn := context.WithCancel(ctx)
+	defer cancelFn()
+	sem := make(chan struct{}, opts.Concurrency)
+	done := make([]chan struct{}, len(steps))
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+	var once sync.Once
+	var failedIdx = -1
+	var failedErr error
+	var wg sync.WaitGroup
+	output := &sync.Mutex{}
+	for i := range steps {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer close(done[i])
+			for _, dep := range deps[i] {
+				select {
+				case <-done[dep]:
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				return
+			}
+			if ctx.Err() != nil {
+				return
+			}
+			compensations.registerStep(steps[i])
+			stepCtx := ctx
+			var w *prefixWriter
+			if _, ok := steps[i].(DependentExecutable); ok {
+				w = newPrefixWriter(fmt.Sprintf(`[#%d] `, i+1), os.Stdout, output)
+				stepCtx = withStepOutput(ctx, w)
+			}
+			e := execStep(stepCtx, opts, i, steps[i])
+			if w != nil {
+				w.Flush()
+			}
+			if e != nil {
+				once.Do(func() {
+					failedIdx, failedErr = i, e
+					cancelFn()
+				})
+			}
+		}(i)
+	}
+	wg.Wait()
+	return failedIdx, failedErr
+}
+
+// resolveStepDependencies returns indices of dependencies of each step. See DependentExecutable
+func resolveStepDependencies(steps []Executable) ([][]int, error) {
+	indices := map[Executable]int{}
+	for i := range steps {
+		if reflect.TypeOf(steps[i]).Comparable() {
+			indices[steps[i]] = i
+		}
+	}
+	deps := make([][]int, len(steps))
+	barrier := -1
+	for i := range steps {
+		dependent, ok := steps[i].(DependentExecutable)
+		if !ok {
+			// regular step depends on all preceding steps
+			for j := barrier + 1; j < i; j++ {
+				deps[i] = append(deps[i], j)
+			}
+			if barrier >= 0 {
+				deps[i] = append(deps[i], barrier)
+			}
+			barrier = i
+			continue
+		}
+		if barrier >= 0 {
+			deps[i] = append(deps[i], barrier)
+		}
+		for _, dep := range dependent.Dependencies() {
+			if dep == nil || !reflect.TypeOf(dep).Comparable() {
+				return nil, fmt.Errorf(`invalid dependency of step [%v]`, steps[i])
+			}
+			j, ok := indices[dep]
+			if !ok || j >= i {
+				return nil, fmt.Errorf(`step [%v] depends on [%v], which is not a preceding step`, steps[i], dep)
+			}
+			deps[i] = append(deps[i], j)
+		}
+	}
+	return deps, nil
+}
+
+type stepOutputKey struct{}
+
+func withStepOutput(ctx context.Context, w io.Writer) context.Context {
+	return context.WithValue(ctx, stepOutputKey{}, w)
+}
+
+// StepOutput returns the writer an Executable should use for its output when it runs concurrently with other steps.
+// Returns nil if the step should write to stdout directly
+func StepOutput(ctx context.Context) io.Writer {
+	if w, ok := ctx.Value(stepOutputKey{}).(io.Writer); ok {
+		return w
+	}
+	return nil
+}
+
+// prefixWriter prefix each line with given prefix. Lines of concurrent writers sharing same lock are not interleaved.
+type prefixWriter struct {
+	prefix []byte
+	out    io.Writer
+	lock   *sync.Mutex
+	buf    bytes.Buffer
+}
+
+func newPrefixWriter(prefix string, out io.Writer, lock *sync.Mutex) *prefixWriter {
+	return &prefixWriter{prefix: []byte(prefix), out: out, lock: lock}
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.buf.Write(p)
+	for {
+		idx := bytes.IndexByte(w.buf.Bytes(), '\n')
+		if idx < 0 {
+			return len(p), nil
+		}
+		if e := w.writeLine(w.buf.Next(idx + 1)); e != nil {
+			return len(p), e
+		}
+	}
+}
+
+// Flush write remaining partial line, if any
+func (w *prefixWriter) Flush() {
+	if w.buf.Len() != 0 {
+		_ = w.writeLine(append(w.buf.Next(w.buf.Len()), '\n'))
+	}
+}
+
+func (w *prefixWriter) writeLine(line []byte) error {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	if _, e := w.out.Write(w.prefix); e != nil {
+		return e
+	}
+	_, e := w.out.Write(line)
+	return e
+}
@@ -0,0 +1,208 @@
+package plan
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestResolveStepDependencies(t *testing.T) {
+	tests := []struct {
+		name     string
+		steps    func() []Executable
+		expected [][]int
+		err      string
+	}{
+		{
+			name: "sequential",
+			steps: func() []Executable {
+				return []Executable{&funcExecutable{name: "a"}, &funcExecutable{name: "b"}, &funcExecutable{name: "c"}}
+			},
+			expected: [][]int{nil, {0}, {1}},
+		},
+		{
+			name: "regular steps as barriers",
+			steps: func() []Executable {
+				a, b := &funcExecutable{name: "a"}, WithDependencies(&funcExecutable{name: "b"})
+				c := WithDependencies(&funcExecutable{name: "c"}, b)
+				d, e := &funcExecutable{name: "d"}, WithDependencies(&funcExecutable{name: "e"})
+				return []Executable{a, b, c, d, e}
+			},
+			expected: [][]int{nil, {0}, {0, 1}, {1, 2, 0}, {3}},
+		},
+		{
+			name: "leading dependent steps",
+			steps: func() []Executable {
+				a := WithDependencies(&funcExecutable{name: "a"})
+				return []Executable{a, WithDependencies(&funcExecutable{name: "b"}, a), &funcExecutable{name: "c"}}
+			},
+			expected: [][]int{nil, {0}, {0, 1}},
+		},
+		{
+			name: "succeeding step",
+			steps: func() []Executable {
+				b := &funcExecutable{name: "b"}
+				return []Executable{WithDependencies(&funcExecutable{name: "a"}, b), b}
+			},
+			err: `step [a] depends on [b], which is not a preceding step`,
+		},
+		{
+			name: "step of another plan",
+			steps: func() []Executable {
+				return []Executable{&funcExecutable{name: "a"}, WithDependencies(&funcExecutable{name: "b"}, &funcExecutable{name: "x"})}
+			},
+			err: `step [b] depends on [x], which is not a preceding step`,
+		},
+		{
+			name: "nil dependency",
+			steps: func() []Executable {
+				return []Executable{WithDependencies(&funcExecutable{name: "a"}, nil)}
+			},
+			err: `invalid dependency of step [a]`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			deps, e := resolveStepDependencies(test.steps())
+			if len(test.err) != 0 {
+				if e == nil || e.Error() != test.err {
+					t.Fatalf("expected error %q, but got %v", test.err, e)
+				}
+				return
+			}
+			if e != nil {
+				t.Fatalf("unexpected error: %v", e)
+			}
+			if fmt.Sprint(deps) != fmt.Sprint(test.expected) {
+				t.Errorf("expected %v, but got %v", test.expected, deps)
+			}
+		})
+	}
+}
+
+// stepRecorder records started and finished steps
+type stepRecorder struct {
+	mtx    sync.Mutex
+	events []string
+}
+
+func (r *stepRecorder) step(name string, fn func(ctx context.Context) error) *funcExecutable {
+	return &funcExecutable{name: name, fn: func(ctx context.Context) error {
+		r.record("start " + name)
+		defer r.record("finish " + name)
+		if fn == nil {
+			return nil
+		}
+		return fn(ctx)
+	}}
+}
+
+func (r *stepRecorder) record(event string) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.events = append(r.events, event)
+}
+
+// index returns index of given event, or -1 if not recorded
+func (r *stepRecorder) index(event string) int {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	for i := range r.events {
+		if r.events[i] == event {
+			return i
+		}
+	}
+	return -1
+}
+
+func TestExecuteConcurrently(t *testing.T) {
+	opts := DefaultExecOption
+	opts.Concurrency = 4
+
+	t.Run("concurrent stage", func(t *testing.T) {
+		rec := &stepRecorder{}
+		// b and c only finish if both of them are started
+		var started sync.WaitGroup
+		started.Add(2)
+		waitBoth := func(ctx context.Context) error {
+			started.Done()
+			ch := make(chan struct{})
+			go func() { started.Wait(); close(ch) }()
+			select {
+			case <-ch:
+				return nil
+			case <-time.After(5 * time.Second):
+				return errors.New("steps are not running concurrently")
+			}
+		}
+		a := rec.step("a", nil)
+		b, c := WithDependencies(rec.step("b", waitBoth)), WithDependencies(rec.step("c", waitBoth))
+		d := WithDependencies(rec.step("d", nil), b)
+		steps := []Executable{a, b, c, d, rec.step("e", nil)}
+		ctx, reg := withCompensationRegistry(context.Background())
+		idx, e := executeConcurrently(ctx, opts, steps, reg)
+		if e != nil || idx != -1 {
+			t.Fatalf("unexpected error of step %d: %v", idx, e)
+		}
+		for _, order := range [][2]string{{"finish a", "start b"}, {"finish a", "start c"}, {"finish b", "start d"},
+			{"finish c", "start e"}, {"finish d", "start e"}} {
+			if i, j := rec.index(order[0]), rec.index(order[1]); i < 0 || j < 0 || i > j {
+				t.Errorf("expected [%s] before [%s], but got %v", order[0], order[1], rec.events)
+			}
+		}
+	})
+
+	t.Run("cancel on first failure", func(t *testing.T) {
+		rec := &stepRecorder{}
+		oops := errors.New("oops")
+		var cancelled bool
+		blocked := WithDependencies(rec.step("blocked", func(ctx context.Context) error {
+			select {
+			case <-ctx.Done():
+				cancelled = true
+				return ctx.Err()
+			case <-time.After(5 * time.Second):
+				return nil
+			}
+		}))
+		failing := WithDependencies(rec.step("failing", func(ctx context.Context) error {
+			time.Sleep(10 * time.Millisecond)
+			return oops
+		}))
+		steps := []Executable{blocked, failing, rec.step("after", nil)}
+		ctx, reg := withCompensationRegistry(context.Background())
+		idx, e := executeConcurrently(ctx, opts, steps, reg)
+		switch {
+		case !errors.Is(e, oops) || idx != 1:
+			t.Errorf("expected failure of step 1, but got step %d: %v", idx, e)
+		case !cancelled:
+			t.Errorf("expected running step to be cancelled")
+		case rec.index("start after") >= 0:
+			t.Errorf("expected steps after failure not started, but got %v", rec.events)
+		}
+	})
+
+	t.Run("invalid dependencies", func(t *testing.T) {
+		rec := &stepRecorder{}
+		steps := []Executable{WithDependencies(rec.step("a", nil), &funcExecutable{name: "x"})}
+		ctx, reg := withCompensationRegistry(context.Background())
+		if idx, e := executeConcurrently(ctx, opts, steps, reg); e == nil || idx != -1 || len(rec.events) != 0 {
+			t.Errorf("expected error before any step started, but got step %d: %v, %v", idx, e, rec.events)
+		}
+	})
+}
+
+func TestPrefixWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := newPrefixWriter("[#1] ", &buf, &sync.Mutex{})
+	_, _ = w.Write([]byte("first line\nsecond "))
+	_, _ = w.Write([]byte("line\npartial"))
+	w.Flush()
+	if expected := "[#1] first line\n[#1] second line\n[#1] partial\n"; buf.String() != expected {
+		t.Errorf("expected %q, but got %q", expected, buf.String())
+	}
+}
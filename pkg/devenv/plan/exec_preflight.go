@@ -0,0 +1,272 @@
+package plan
+
+import (
+	"context"
+	"fmt"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/volume"
+	dockerclient "github.com/docker/docker/client"
+	"github.com/docker/go-connections/nat"
+	"github.com/stonedu1011/devenvctl/pkg/devenv/compose"
+	"net"
+	"strings"
+)
+
+// PreflightExecutable parse the rendered compose file and check resources it requires against what exists on the machine:
+//   - host ports published by services, used by other containers or by processes outside Docker
+//   - "container_name" of services, used by containers of other projects
+//   - subnets of networks, overlapping with existing Docker networks of other projects
+//   - names of volumes, used by volumes of other projects
+//
+// Resources of the profile itself are not considered as conflicts. All conflicts are reported at once.
+type PreflightExecutable struct {
+	ApiClient   *dockerclient.Client
+	ProfileName string
+	ComposePath string
+	// Lookup resolves variables in compose file, the same way as docker compose CLI would
+	Lookup func(name string) (string, bool)
+	// Services optional, services to be started. All services without compose profiles are checked if not set
+	Services []string
+}
+
+func (exec PreflightExecutable) Exec(ctx context.Context, opts ExecOption) error {
+	if opts.DryRun {
+		printPlanned(exec)
+		return nil
+	}
+	project, e := compose.LoadProject(ComposeProjectName(exec.ProfileName), exec.ComposePath, exec.Lookup)
+	if e != nil {
+		logger.WithContext(ctx).Warnf(`Skipping preflight checks, unable to parse docker compose [%s]: %v`, exec.ComposePath, e)
+		return nil
+	}
+	services, e := exec.composeServices(project)
+	if e != nil {
+		return e
+	}
+	containers, e := exec.ApiClient.ContainerList(ctx, container.ListOptions{All: true})
+	if e != nil {
+		return fmt.Errorf(`unable to list containers: %v`, e)
+	}
+
+	var conflicts []string
+	checks := []func() ([]string, error){
+		func() ([]string, error) { return exec.checkPorts(project, services, containers) },
+		func() ([]string, error) { return exec.checkContainerNames(project, services, containers) },
+		func() ([]string, error) { return exec.checkSubnets(ctx, project) },
+		func() ([]string, error) { return exec.checkVolumes(ctx, project) },
+	}
+	for _, check := range checks {
+		found, e := check()
+		if e != nil {
+			return e
+		}
+		conflicts = append(conflicts, found...)
+	}
+	if len(conflicts) != 0 {
+		return fmt.Errorf("resource conflicts found:\n    - %s", strings.Join(conflicts, "\n    - "))
+	}
+	if opts.Verbose {
+		logger.WithContext(ctx).Debugf(`No resource conflicts found`)
+	}
+	return nil
+}
+
+func (exec PreflightExecutable) String() string {
+	return fmt.Sprintf(`preflight: check ports, container names, networks and volumes of [%s]`, exec.ProfileName)
+}
+
+// composeServices returns services to check in dependency order.
+// Services not defined in compose file, e.g. profile services without containers, are ignored
+func (exec PreflightExecutable) composeServices(project *compose.Project) ([]string, error) {
+	if len(exec.Services) == 0 {
+		return project.ServiceOrder()
+	}
+	names := make([]string, 0, len(exec.Services))
+	for _, name := range exec.Services {
+		if _, ok := project.Services[name]; ok {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+	return project.ServiceOrder(names...)
+}
+
+func (exec PreflightExecutable) checkPorts(project *compose.Project, services []string, containers []types.Container) ([]string, error) {
+	var conflicts []string
+	for _, name := range services {
+		_, bindings, e := nat.ParsePortSpecs(toStrings(project.Services[name].Ports))
+		if e != nil {
+			return nil, fmt.Errorf(`invalid ports of service [%s]: %v`, name, e)
+		}
+		ports := make([]nat.Port, 0, len(bindings))
+		for port := range bindings {
+			ports = append(ports, port)
+		}
+		nat.Sort(ports, func(ip, jp nat.Port) bool { return ip.Int() < jp.Int() })
+		for _, port := range ports {
+			for _, binding := range bindings[port] {
+				// ranges of host ports are allocated by Docker from what's available
+				if len(binding.HostPort) == 0 || strings.Contains(binding.HostPort, "-") {
+					continue
+				}
+				if owner, ok := exec.portOwner(project, containers, binding.HostPort, port.Proto()); ok {
+					if len(owner) != 0 {
+						conflicts = append(conflicts, fmt.Sprintf(`host port %s/%s of service [%s] is used by %s`, binding.HostPort, port.Proto(), name, owner))
+					}
+					continue
+				}
+				if !isPortAvailable(binding.HostIP, binding.HostPort, port.Proto()) {
+					conflicts = append(conflicts, fmt.Sprintf(`host port %s/%s of service [%s] is used by a process outside Docker`, binding.HostPort, port.Proto(), name))
+				}
+			}
+		}
+	}
+	return conflicts, nil
+}
+
+// portOwner find the running container publishing given host port. Returns true if found, with empty owner if the container belongs to the project
+func (exec PreflightExecutable) portOwner(project *compose.Project, containers []types.Container, hostPort, proto string) (string, bool) {
+	for i := range containers {
+		if containers[i].State != "running" {
+			continue
+		}
+		for _, p := range containers[i].Ports {
+			if fmt.Sprintf(`%d`, p.PublicPort) != hostPort || p.Type != proto {
+				continue
+			}
+			if containers[i].Labels[LabelComposeProject] == project.Name {
+				return "", true
+			}
+			return containerOwner(containers[i]), true
+		}
+	}
+	return "", false
+}
+
+func (exec PreflightExecutable) checkContainerNames(project *compose.Project, services []string, containers []types.Container) ([]string, error) {
+	var conflicts []string
+	for _, name := range services {
+		cName := project.Services[name].ContainerName
+		if len(cName) == 0 {
+			continue
+		}
+		for i := range containers {
+			if containers[i].Labels[LabelComposeProject] == project.Name || !containsName(containers[i].Names, cName) {
+				continue
+			}
+			conflicts = append(conflicts, fmt.Sprintf(`container name [%s] of service [%s] is used by %s`, cName, name, containerOwner(containers[i])))
+		}
+	}
+	return conflicts, nil
+}
+
+func (exec PreflightExecutable) checkSubnets(ctx context.Context, project *compose.Project) ([]string, error) {
+	existing, e := exec.ApiClient.NetworkList(ctx, types.NetworkListOptions{})
+	if e != nil {
+		return nil, fmt.Errorf(`unable to list networks: %v`, e)
+	}
+	var conflicts []string
+	for _, key := range sortedKeys(project.Networks) {
+		n := project.Networks[key]
+		if n.External || n.IPAM == nil {
+			continue
+		}
+		for _, cfg := range n.IPAM.Config {
+			_, subnet, e := net.ParseCIDR(cfg.Subnet)
+			if e != nil {
+				continue
+			}
+			for _, other := range existing {
+				if other.Name == project.NetworkName(key) || other.Labels[LabelComposeProject] == project.Name {
+					continue
+				}
+				for _, otherCfg := range other.IPAM.Config {
+					if _, otherSubnet, e := net.ParseCIDR(otherCfg.Subnet); e == nil && overlaps(subnet, otherSubnet) {
+						conflicts = append(conflicts, fmt.Sprintf(`subnet %s of network [%s] overlaps with %s of %s`,
+							cfg.Subnet, key, otherCfg.Subnet, resourceOwner("network", other.Name, other.Labels)))
+					}
+				}
+			}
+		}
+	}
+	return conflicts, nil
+}
+
+func (exec PreflightExecutable) checkVolumes(ctx context.Context, project *compose.Project) ([]string, error) {
+	resp, e := exec.ApiClient.VolumeList(ctx, volume.ListOptions{})
+	if e != nil {
+		return nil, fmt.Errorf(`unable to list volumes: %v`, e)
+	}
+	existing := map[string]*volume.Volume{}
+	for _, v := range resp.Volumes {
+		existing[v.Name] = v
+	}
+	var conflicts []string
+	for _, key := range sortedKeys(project.Volumes) {
+		if project.Volumes[key].External {
+			continue
+		}
+		name := project.VolumeName(key)
+		v, ok := existing[name]
+		// volumes not created by docker compose are reused by docker compose with a warning
+		if !ok || len(v.Labels[LabelComposeProject]) == 0 || v.Labels[LabelComposeProject] == project.Name {
+			continue
+		}
+		conflicts = append(conflicts, fmt.Sprintf(`volume name [%s] of volume [%s] is used by %s`, name, key, resourceOwner("volume", v.Name, v.Labels)))
+	}
+	return conflicts, nil
+}
+
+// containerOwner describe which profile owns the container, or the container itself if it doesn't belong to any
+func containerOwner(c types.Container) string {
+	name := c.ID
+	if len(name) > 12 {
+		name = name[:12]
+	}
+	if len(c.Names) != 0 {
+		name = strings.TrimPrefix(c.Names[0], "/")
+	}
+	return resourceOwner("container", name, c.Labels)
+}
+
+func resourceOwner(kind, name string, labels map[string]string) string {
+	if project := labels[LabelComposeProject]; len(project) != 0 {
+		return fmt.Sprintf(`%s [%s] of profile [%s]`, kind, name, project)
+	}
+	return fmt.Sprintf(`%s [%s]`, kind, name)
+}
+
+func containsName(names []string, name string) bool {
+	for _, n := range names {
+		if strings.TrimPrefix(n, "/") == name {
+			return true
+		}
+	}
+	return false
+}
+
+func overlaps(a, b *net.IPNet) bool {
+	return a.Contains(b.IP) || b.Contains(a.IP)
+}
+
+// isPortAvailable try to listen on given host port
+func isPortAvailable(hostIP, hostPort, proto string) bool {
+	addr := net.JoinHostPort(hostIP, hostPort)
+	if proto == "udp" {
+		conn, e := net.ListenPacket("udp", addr)
+		if e != nil {
+			return false
+		}
+		_ = conn.Close()
+		return true
+	}
+	l, e := net.Listen("tcp", addr)
+	if e != nil {
+		return false
+	}
+	_ = l.Close()
+	return true
+}
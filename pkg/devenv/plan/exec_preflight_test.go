@@ -0,0 +1,127 @@
+package plan
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testPreflightCompose = `{
+	"services": {
+		"db": {"image": "postgres:16", "container_name": "shared-db", "ports": ["15432:5432"]},
+		"app": {"image": "app:latest", "depends_on": ["db"], "ports": ["18080:8080", "8081"]},
+		"tool": {"image": "tool:latest", "profiles": ["tools"], "ports": ["19000:9000"]}
+	},
+	"networks": {
+		"default": {"ipam": {"config": [{"subnet": "172.28.0.0/16"}]}}
+	},
+	"volumes": {
+		"data": {},
+		"cache": {"external": true}
+	}
+}`
+
+func newTestPreflight(t *testing.T, compose string, services ...string) (*fakeDocker, *PreflightExecutable) {
+	fake, client := newFakeDocker(t, map[string]interface{}{
+		"GET /containers/json": []map[string]interface{}{
+			{
+				"Id": "c-other-db", "Names": []string{"/other-db-1"}, "State": "running",
+				"Labels": map[string]string{LabelComposeProject: "other"},
+				"Ports":  []map[string]interface{}{{"IP": "0.0.0.0", "PrivatePort": 5432, "PublicPort": 15432, "Type": "tcp"}},
+			},
+			{
+				"Id": "c-tool", "Names": []string{"/other-tool-1"}, "State": "running",
+				"Labels": map[string]string{LabelComposeProject: "other"},
+				"Ports":  []map[string]interface{}{{"IP": "0.0.0.0", "PrivatePort": 9000, "PublicPort": 19000, "Type": "tcp"}},
+			},
+			{
+				"Id": "c-app", "Names": []string{"/test-app-1"}, "State": "running",
+				"Labels": map[string]string{LabelComposeProject: "test"},
+				"Ports":  []map[string]interface{}{{"IP": "0.0.0.0", "PrivatePort": 8080, "PublicPort": 18080, "Type": "tcp"}},
+			},
+			{"Id": "c-shared", "Names": []string{"/shared-db"}, "State": "exited"},
+		},
+		"GET /networks": []map[string]interface{}{
+			{"Name": "test_default", "IPAM": map[string]interface{}{"Config": []map[string]string{{"Subnet": "172.28.0.0/16"}}}},
+			{
+				"Name": "other_default", "Labels": map[string]string{LabelComposeProject: "other"},
+				"IPAM": map[string]interface{}{"Config": []map[string]string{{"Subnet": "172.28.1.0/24"}}},
+			},
+			{"Name": "bridge", "IPAM": map[string]interface{}{"Config": []map[string]string{{"Subnet": "172.17.0.0/16"}}}},
+		},
+		"GET /volumes": map[string]interface{}{
+			"Volumes": []map[string]interface{}{
+				{"Name": "test_data", "Labels": map[string]string{LabelComposeProject: "other"}},
+				{"Name": "test_cache", "Labels": map[string]string{LabelComposeProject: "other"}},
+			},
+		},
+	})
+	path := filepath.Join(t.TempDir(), "docker-compose.yml")
+	if e := os.WriteFile(path, []byte(compose), 0644); e != nil {
+		t.Fatalf("unable to write compose file: %v", e)
+	}
+	return fake, &PreflightExecutable{
+		ApiClient:   client,
+		ProfileName: "test",
+		ComposePath: path,
+		Lookup:      func(string) (string, bool) { return "", false },
+		Services:    services,
+	}
+}
+
+func TestPreflightExecutable(t *testing.T) {
+	const (
+		portConflict   = `host port 15432/tcp of service [db] is used by container [other-db-1] of profile [other]`
+		nameConflict   = `container name [shared-db] of service [db] is used by container [shared-db]`
+		subnetConflict = `subnet 172.28.0.0/16 of network [default] overlaps with 172.28.1.0/24 of network [other_default] of profile [other]`
+		volumeConflict = `volume name [test_data] of volume [data] is used by volume [test_data] of profile [other]`
+	)
+	tests := []struct {
+		name     string
+		services []string
+		expected []string
+	}{
+		{name: "services without compose profiles",
+			expected: []string{portConflict, nameConflict, subnetConflict, volumeConflict}},
+		{name: "dependencies of given services",
+			services: []string{"app"},
+			expected: []string{portConflict, nameConflict, subnetConflict, volumeConflict}},
+		{name: "services with compose profiles",
+			services: []string{"tool"},
+			expected: []string{
+				`host port 19000/tcp of service [tool] is used by container [other-tool-1] of profile [other]`,
+				subnetConflict, volumeConflict,
+			}},
+		{name: "services not in compose file",
+			services: []string{"tool", "external"},
+			expected: []string{
+				`host port 19000/tcp of service [tool] is used by container [other-tool-1] of profile [other]`,
+				subnetConflict, volumeConflict,
+			}},
+		{name: "no service in compose file",
+			services: []string{"external"},
+			expected: []string{subnetConflict, volumeConflict}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, exec := newTestPreflight(t, testPreflightCompose, test.services...)
+			e := exec.Exec(context.Background(), ExecOption{})
+			expected := "resource conflicts found:\n    - " + strings.Join(test.expected, "\n    - ")
+			if e == nil || e.Error() != expected {
+				t.Errorf("expected error:\n%s\nbut got:\n%v", expected, e)
+			}
+		})
+	}
+}
+
+func TestPreflightExecutableInvalidCompose(t *testing.T) {
+	fake, exec := newTestPreflight(t, `{"services": {"app": {"ports": ["8080"]}}}`)
+	if e := exec.Exec(context.Background(), ExecOption{}); e != nil {
+		t.Errorf("expected preflight skipped, but got error %v", e)
+	}
+	if reqs := fake.Requested("GET"); len(reqs) != 0 {
+		t.Errorf("expected no docker request, but got %v", reqs)
+	}
+}
@@ -3,13 +3,14 @@ package plan
 import (
 	"context"
 	"fmt"
+	"github.com/stonedu1011/devenvctl/pkg/devenv"
 )
 
 type PrintExecutable string
 
 func (exec PrintExecutable) Exec(ctx context.Context, opts ExecOption) error {
 	if opts.DryRun {
-		fmt.Printf("- %v\n", exec)
+		printPlanned(exec)
 		return nil
 	}
 	logger.WithContext(ctx).Infof(string(exec))
@@ -19,3 +20,8 @@ func (exec PrintExecutable) Exec(ctx context.Context, opts ExecOption) error {
 func (exec PrintExecutable) String() string {
 	return "print: " + string(exec)
 }
+
+// printPlanned print given executable as a planned step in dry-run mode. Secret values are masked
+func printPlanned(exec Executable) {
+	fmt.Printf("- %s\n", devenv.MaskSecrets(fmt.Sprint(exec)))
+}
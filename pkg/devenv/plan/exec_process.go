@@ -0,0 +1,77 @@
+package plan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/stonedu1011/devenvctl/pkg/devenv"
+	"io"
+	"os"
+	"os/exec"
+	"syscall"
+	"time"
+)
+
+// ProcessKillGracePeriod how long a cancelled or timed-out shell command may take to exit after SIGTERM, before it's killed
+const ProcessKillGracePeriod = 5 * time.Second
+
+// runShell run given commands one by one with "sh -c", and stops at the first command with non-zero exit code.
+// Each command runs in its own process group. If ctx is cancelled (e.g. timed out), the entire process group is terminated,
+// so that processes spawned by the command are not left behind.
+// Output goes to given writer, or stdout/stderr if nil. Secret values are masked in both output and logged commands.
+func runShell(ctx context.Context, opts ExecOption, wd string, env []string, out io.Writer, cmds ...string) (int, error) {
+	if len(wd) != 0 {
+		if _, e := os.Stat(wd); e != nil {
+			return 0, fmt.Errorf(`invalid working directory: %v`, e)
+		}
+	}
+	for _, line := range cmds {
+		if opts.Verbose {
+			logger.WithContext(ctx).Debugf(`Shell: %s`, devenv.MaskSecrets(line))
+		}
+		cmd := exec.Command("sh", "-c", line)
+		cmd.Dir = wd
+		cmd.Env = append(os.Environ(), env...)
+		cmd.Stdout, cmd.Stderr = devenv.NewMaskingWriter(os.Stdout), devenv.NewMaskingWriter(os.Stderr)
+		if out != nil {
+			cmd.Stdout = devenv.NewMaskingWriter(out)
+			cmd.Stderr = cmd.Stdout
+		}
+		setProcessGroup(cmd)
+		if e := cmd.Start(); e != nil {
+			return 0, fmt.Errorf(`unable to start shell [%s]: %v`, devenv.MaskSecrets(line), e)
+		}
+		done := make(chan struct{})
+		go terminateOnCancel(ctx, cmd, done)
+		e := cmd.Wait()
+		close(done)
+		if ctx.Err() != nil {
+			return 0, fmt.Errorf(`shell terminated: %v`, ctx.Err())
+		}
+		var exitErr *exec.ExitError
+		switch {
+		case errors.As(e, &exitErr):
+			return exitErr.ExitCode(), nil
+		case e != nil:
+			return 0, e
+		}
+	}
+	return 0, nil
+}
+
+// terminateOnCancel send SIGTERM to the process group of given command when ctx is cancelled,
+// and SIGKILL if it's still running after ProcessKillGracePeriod. Returns when done is closed.
+func terminateOnCancel(ctx context.Context, cmd *exec.Cmd, done chan struct{}) {
+	select {
+	case <-done:
+		return
+	case <-ctx.Done():
+	}
+	logger.WithContext(ctx).Warnf(`Terminating process group of [%s]: %v`, devenv.MaskSecrets(cmd.String()), ctx.Err())
+	_ = signalProcessGroup(cmd, syscall.SIGTERM)
+	select {
+	case <-done:
+	case <-time.After(ProcessKillGracePeriod):
+		_ = signalProcessGroup(cmd, syscall.SIGKILL)
+	}
+}
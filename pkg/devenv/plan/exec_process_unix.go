@@ -0,0 +1,20 @@
+//go:build !windows
+
+package plan
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+func signalProcessGroup(cmd *exec.Cmd, sig syscall.Signal) error {
+	if cmd.Process == nil {
+		return nil
+	}
+	// negative PID means the process group
+	return syscall.Kill(-cmd.Process.Pid, sig)
+}
@@ -0,0 +1,40 @@
+//go:build !windows
+
+package plan
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunShell(t *testing.T) {
+	var out bytes.Buffer
+	rc, e := runShell(context.Background(), DefaultExecOption, t.TempDir(), []string{"NAME=devenv"}, &out,
+		`echo "hello $NAME"`, `exit 3`, `echo unreachable`)
+	switch {
+	case e != nil:
+		t.Fatalf("unexpected error: %v", e)
+	case rc != 3:
+		t.Errorf("expected exit code 3, but got %d", rc)
+	case out.String() != "hello devenv\n":
+		t.Errorf("expected commands after non-zero exit code not executed, but got output %q", out.String())
+	}
+}
+
+func TestRunShellTerminateProcessGroup(t *testing.T) {
+	ctx, cancelFn := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancelFn()
+	// the background process holds the output open, so the shell only returns when the whole process group is terminated
+	var out bytes.Buffer
+	start := time.Now()
+	_, e := runShell(ctx, DefaultExecOption, "", nil, &out, `sleep 30 & wait`)
+	if e == nil || !strings.Contains(e.Error(), "shell terminated") {
+		t.Errorf("expected shell terminated, but got %v", e)
+	}
+	if elapsed := time.Since(start); elapsed > ProcessKillGracePeriod {
+		t.Errorf("expected process group to be terminated, but it took %v", elapsed)
+	}
+}
@@ -0,0 +1,18 @@
+//go:build windows
+
+package plan
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+func setProcessGroup(_ *exec.Cmd) {}
+
+// signalProcessGroup process groups are not supported, the process is killed regardless of the signal
+func signalProcessGroup(cmd *exec.Cmd, _ syscall.Signal) error {
+	if cmd.Process == nil {
+		return nil
+	}
+	return cmd.Process.Kill()
+}
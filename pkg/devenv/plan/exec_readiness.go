@@ -0,0 +1,280 @@
+package plan
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/docker/docker/api/types/container"
+	dockerclient "github.com/docker/docker/client"
+	"github.com/docker/docker/pkg/stdcopy"
+	"github.com/stonedu1011/devenvctl/pkg/devenv"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultReadinessTimeout  = 2 * time.Minute
+	defaultReadinessInterval = time.Second
+	readinessReportLogLines  = 10
+)
+
+var errContainerNotFound = errors.New("container not found")
+
+func NewServiceReadinessExecutable(client *dockerclient.Client, p *devenv.Profile, opts ...func(exec *ServiceReadinessExecutable)) *ServiceReadinessExecutable {
+	exec := &ServiceReadinessExecutable{
+		ApiClient: client,
+		Profile:   p,
+		Timeout:   DefaultReadinessTimeout,
+	}
+	for _, fn := range opts {
+		fn(exec)
+	}
+	return exec
+}
+
+// ServiceReadinessExecutable polls Docker API until all given services are ready, according to their devenv.Readiness.
+// Services without readiness settings are ready when their containers' healthcheck report "healthy",
+// or when they are running if no healthcheck is defined.
+// If any service is not ready within Timeout, the last log lines of their containers are reported.
+type ServiceReadinessExecutable struct {
+	ApiClient *dockerclient.Client
+	Profile   *devenv.Profile
+	// Services names of services to wait for, in dependency order
+	Services []string
+	// Timeout overall timeout. Readiness.Timeout of individual service can only shorten it
+	Timeout time.Duration
+}
+
+type readinessResult struct {
+	Service string
+	Elapsed time.Duration
+	Err     error
+}
+
+func (exec *ServiceReadinessExecutable) Exec(ctx context.Context, opts ExecOption) error {
+	if opts.DryRun {
+		printPlanned(exec)
+		return nil
+	}
+	if len(exec.Services) == 0 {
+		return nil
+	}
+	logger.WithContext(ctx).Infof(`Waiting for services to be ready (timeout %v) ...`, exec.Timeout)
+
+	waitCtx, cancelFn := context.WithTimeout(ctx, exec.Timeout)
+	defer cancelFn()
+	ch := make(chan readinessResult, len(exec.Services))
+	start := time.Now()
+	for _, name := range exec.Services {
+		go func(name string) {
+			e := exec.wait(waitCtx, name)
+			ch <- readinessResult{Service: name, Elapsed: time.Since(start), Err: e}
+		}(name)
+	}
+
+	results := map[string]readinessResult{}
+	for range exec.Services {
+		r := <-ch
+		results[r.Service] = r
+		if r.Err == nil && opts.Verbose {
+			logger.WithContext(ctx).Infof(`Service [%s] is ready after %v`, r.Service, r.Elapsed.Round(time.Millisecond))
+		}
+	}
+
+	failed := make([]string, 0, len(exec.Services))
+	for _, name := range exec.Services {
+		if results[name].Err != nil {
+			failed = append(failed, name)
+		}
+	}
+	if len(failed) == 0 {
+		logger.WithContext(ctx).Infof(`All services are ready`)
+		return nil
+	}
+	exec.report(ctx, failed, results)
+	return fmt.Errorf(`services [%s] are not ready within %v`, strings.Join(failed, ", "), exec.Timeout)
+}
+
+func (exec *ServiceReadinessExecutable) String() string {
+	return fmt.Sprintf(`wait for services to be ready (timeout %v): %s`, exec.Timeout, strings.Join(exec.Services, ", "))
+}
+
+// wait blocks until the service is ready, ctx is done or the service would never be ready (e.g. exited).
+func (exec *ServiceReadinessExecutable) wait(ctx context.Context, name string) error {
+	r := exec.readiness(name)
+	if r.Timeout > 0 {
+		var cancelFn context.CancelFunc
+		ctx, cancelFn = context.WithTimeout(ctx, r.Timeout)
+		defer cancelFn()
+	}
+	interval := r.Interval
+	if interval <= 0 {
+		interval = defaultReadinessInterval
+	}
+	var logRegex *regexp.Regexp
+	if r.Type == devenv.ReadinessLog {
+		var e error
+		if logRegex, e = regexp.Compile(r.Target); e != nil {
+			return fmt.Errorf(`invalid readiness log pattern "%s": %v`, r.Target, e)
+		}
+	}
+
+	var reason string
+	for {
+		ready, why, e := exec.probe(ctx, name, r, interval, logRegex)
+		switch {
+		case e != nil:
+			return e
+		case ready:
+			return nil
+		}
+		reason = why
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf(`timed out, %s`, reason)
+		case <-time.After(interval):
+		}
+	}
+}
+
+// probe check readiness once. Returns error only if the service would never become ready.
+// Services are waited for after they are started, so a missing container would never show up
+func (exec *ServiceReadinessExecutable) probe(ctx context.Context, name string, r devenv.Readiness, interval time.Duration, logRegex *regexp.Regexp) (bool, string, error) {
+	id, e := exec.findContainer(ctx, name)
+	switch {
+	case errors.Is(e, errContainerNotFound):
+		return false, "", fmt.Errorf(`%v, service is not started or not defined in docker compose file`, e)
+	case e != nil:
+		return false, fmt.Sprintf(`unable to find container: %v`, e), nil
+	}
+	info, e := exec.ApiClient.ContainerInspect(ctx, id)
+	if e != nil {
+		return false, fmt.Sprintf(`unable to inspect container: %v`, e), nil
+	}
+	switch {
+	case info.State == nil:
+		return false, "container state unknown", nil
+	case info.State.Status == "exited" || info.State.Status == "dead":
+		return false, "", fmt.Errorf(`container %s with code %d`, info.State.Status, info.State.ExitCode)
+	case !info.State.Running:
+		return false, fmt.Sprintf(`container is %s`, info.State.Status), nil
+	}
+
+	switch r.Type {
+	case devenv.ReadinessRunning:
+		return true, "", nil
+	case devenv.ReadinessTCP:
+		addr := r.Target
+		if !strings.Contains(addr, ":") {
+			addr = net.JoinHostPort("localhost", addr)
+		}
+		conn, e := (&net.Dialer{Timeout: interval}).DialContext(ctx, "tcp", addr)
+		if e != nil {
+			return false, fmt.Sprintf(`unable to connect %s: %v`, addr, e), nil
+		}
+		_ = conn.Close()
+		return true, "", nil
+	case devenv.ReadinessHTTP:
+		req, e := http.NewRequestWithContext(ctx, http.MethodGet, r.Target, nil)
+		if e != nil {
+			return false, "", fmt.Errorf(`invalid readiness URL "%s": %v`, r.Target, e)
+		}
+		resp, e := (&http.Client{Timeout: interval}).Do(req)
+		if e != nil {
+			return false, fmt.Sprintf(`GET %s: %v`, r.Target, e), nil
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+			return false, fmt.Sprintf(`GET %s: %s`, r.Target, resp.Status), nil
+		}
+		return true, "", nil
+	case devenv.ReadinessLog:
+		logs, e := containerLogs(ctx, exec.ApiClient, id, info.Config != nil && info.Config.Tty, container.LogsOptions{
+			ShowStdout: true,
+			ShowStderr: true,
+			Since:      info.State.StartedAt,
+		})
+		if e != nil {
+			return false, fmt.Sprintf(`unable to read logs: %v`, e), nil
+		}
+		if !logRegex.MatchString(logs) {
+			return false, fmt.Sprintf(`no log line matches "%s"`, r.Target), nil
+		}
+		return true, "", nil
+	default:
+		if info.State.Health == nil || info.State.Health.Status == "healthy" {
+			return true, "", nil
+		}
+		return false, fmt.Sprintf(`health status is "%s"`, info.State.Health.Status), nil
+	}
+}
+
+func (exec *ServiceReadinessExecutable) readiness(name string) devenv.Readiness {
+	if svc, ok := exec.Profile.Services[name]; ok && svc.Readiness != nil {
+		return *svc.Readiness
+	}
+	return devenv.Readiness{Type: devenv.ReadinessHealthcheck}
+}
+
+func (exec *ServiceReadinessExecutable) findContainer(ctx context.Context, name string) (string, error) {
+	containers, e := ListComposeContainers(ctx, exec.ApiClient, exec.Profile.Name)
+	if e != nil {
+		return "", e
+	}
+	for i := range containers {
+		if containers[i].Labels[LabelComposeService] == name {
+			return containers[i].ID, nil
+		}
+	}
+	return "", errContainerNotFound
+}
+
+// report print reasons and last log lines of services that are not ready
+func (exec *ServiceReadinessExecutable) report(ctx context.Context, failed []string, results map[string]readinessResult) {
+	// the waiting context might be expired already
+	reportCtx, cancelFn := context.WithTimeout(context.WithoutCancel(ctx), 10*time.Second)
+	defer cancelFn()
+	for _, name := range failed {
+		logger.WithContext(ctx).Errorf(`Service [%s] is not ready: %v`, name, results[name].Err)
+		id, e := exec.findContainer(reportCtx, name)
+		if e != nil {
+			continue
+		}
+		var tty bool
+		if info, e := exec.ApiClient.ContainerInspect(reportCtx, id); e == nil && info.Config != nil {
+			tty = info.Config.Tty
+		}
+		logs, e := containerLogs(reportCtx, exec.ApiClient, id, tty, container.LogsOptions{
+			ShowStdout: true,
+			ShowStderr: true,
+			Tail:       fmt.Sprintf(`%d`, readinessReportLogLines),
+		})
+		if e != nil || len(strings.TrimSpace(logs)) == 0 {
+			continue
+		}
+		fmt.Printf("    Last %d log lines of [%s]:\n", readinessReportLogLines, name)
+		for _, line := range strings.Split(strings.TrimRight(logs, "\n"), "\n") {
+			fmt.Printf("    | %s\n", devenv.MaskSecrets(line))
+		}
+	}
+}
+
+// containerLogs read container logs as plain text. Logs are multiplexed unless container has TTY attached.
+func containerLogs(ctx context.Context, client *dockerclient.Client, id string, tty bool, opts container.LogsOptions) (string, error) {
+	reader, e := client.ContainerLogs(ctx, id, opts)
+	if e != nil {
+		return "", e
+	}
+	defer func() { _ = reader.Close() }()
+	var buf bytes.Buffer
+	if tty {
+		_, e = buf.ReadFrom(reader)
+	} else {
+		_, e = stdcopy.StdCopy(&buf, &buf, reader)
+	}
+	return buf.String(), e
+}
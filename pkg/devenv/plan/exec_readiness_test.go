@@ -0,0 +1,107 @@
+package plan
+
+import (
+	"context"
+	"fmt"
+	"github.com/stonedu1011/devenvctl/pkg/devenv"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestServiceReadinessExecutable(t *testing.T) {
+	okSrv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) { rw.WriteHeader(http.StatusNoContent) }))
+	defer okSrv.Close()
+	unavailableSrv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) { rw.WriteHeader(http.StatusServiceUnavailable) }))
+	defer unavailableSrv.Close()
+	listener, e := net.Listen("tcp", "127.0.0.1:0")
+	if e != nil {
+		t.Fatalf("unable to listen: %v", e)
+	}
+	defer func() { _ = listener.Close() }()
+
+	const interval = 10 * time.Millisecond
+	running := map[string]interface{}{"Status": "running", "Running": true, "StartedAt": time.Now().UTC().Format(time.RFC3339Nano)}
+	tests := []struct {
+		name      string
+		readiness *devenv.Readiness
+		state     map[string]interface{}
+		logs      []byte
+		missing   bool
+		ready     bool
+	}{
+		{name: "healthy", state: map[string]interface{}{"Status": "running", "Running": true, "Health": map[string]interface{}{"Status": "healthy"}},
+			ready: true},
+		{name: "without healthcheck", state: running, ready: true},
+		{name: "unhealthy", state: map[string]interface{}{"Status": "running", "Running": true, "Health": map[string]interface{}{"Status": "starting"}}},
+		{name: "running", readiness: &devenv.Readiness{Type: devenv.ReadinessRunning}, state: running, ready: true},
+		{name: "http", readiness: &devenv.Readiness{Type: devenv.ReadinessHTTP, Target: okSrv.URL}, state: running, ready: true},
+		{name: "http unavailable", readiness: &devenv.Readiness{Type: devenv.ReadinessHTTP, Target: unavailableSrv.URL}, state: running},
+		{name: "tcp", readiness: &devenv.Readiness{Type: devenv.ReadinessTCP, Target: listener.Addr().String()}, state: running, ready: true},
+		{name: "log", readiness: &devenv.Readiness{Type: devenv.ReadinessLog, Target: `ready to accept \w+`}, state: running,
+			logs: multiplexedLogs("starting\n", "ready to accept connections\n"), ready: true},
+		{name: "log not matched", readiness: &devenv.Readiness{Type: devenv.ReadinessLog, Target: `ready to accept`}, state: running,
+			logs: multiplexedLogs("starting\n")},
+		{name: "exited", state: map[string]interface{}{"Status": "exited", "ExitCode": 1}},
+		{name: "missing container", missing: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			responses := map[string]interface{}{
+				"GET /containers/json": []map[string]interface{}{
+					{"Id": "c-svc", "Labels": map[string]string{LabelComposeService: "svc"}},
+				},
+				"GET /containers/c-svc/json": map[string]interface{}{"Id": "c-svc", "State": test.state},
+			}
+			if test.missing {
+				responses["GET /containers/json"] = []map[string]interface{}{}
+			}
+			if test.logs != nil {
+				responses["GET /containers/c-svc/logs"] = test.logs
+			}
+			_, client := newFakeDocker(t, responses)
+			readiness := test.readiness
+			if readiness == nil {
+				readiness = &devenv.Readiness{Type: devenv.ReadinessHealthcheck}
+			}
+			readiness.Interval = interval
+			p := &devenv.Profile{
+				ProfileMetadata: devenv.ProfileMetadata{Name: "test"},
+				Services:        map[string]devenv.Service{"svc": {Name: "svc", Readiness: readiness}},
+			}
+			exec := NewServiceReadinessExecutable(client, p, func(exec *ServiceReadinessExecutable) {
+				exec.Services = []string{"svc"}
+				exec.Timeout = 200 * time.Millisecond
+			})
+			e := exec.Exec(context.Background(), DefaultExecOption)
+			switch expected := fmt.Sprintf(`services [svc] are not ready within %v`, exec.Timeout); {
+			case test.ready && e != nil:
+				t.Errorf("unexpected error: %v", e)
+			case !test.ready && (e == nil || e.Error() != expected):
+				t.Errorf("expected error %q, but got %v", expected, e)
+			}
+		})
+	}
+}
+
+func TestServiceReadinessFailFast(t *testing.T) {
+	_, client := newFakeDocker(t, map[string]interface{}{
+		"GET /containers/json": []map[string]interface{}{},
+	})
+	p := &devenv.Profile{
+		ProfileMetadata: devenv.ProfileMetadata{Name: "test"},
+		Services:        map[string]devenv.Service{"svc": {Name: "svc"}},
+	}
+	exec := NewServiceReadinessExecutable(client, p, func(exec *ServiceReadinessExecutable) {
+		exec.Services = []string{"svc"}
+	})
+	start := time.Now()
+	if e := exec.Exec(context.Background(), DefaultExecOption); e == nil {
+		t.Fatalf("expected error of missing container")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected missing container to fail at once, but waited %v", elapsed)
+	}
+}
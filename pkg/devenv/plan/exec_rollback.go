@@ -0,0 +1,95 @@
+package plan
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// CompensableExecutable is an Executable with compensating actions that undo its effect.
+// When ExecOption.Rollback is enabled and any step fails, compensations of started steps are executed in reverse order.
+// Compensations are registered when the step starts, so they are also executed if the step itself fails.
+// Executables that only know their effect at runtime can use RegisterCompensation instead.
+type CompensableExecutable interface {
+	Executable
+	Compensations() []Executable
+}
+
+// WithCompensation wrap given executable as CompensableExecutable
+func WithCompensation(exec Executable, compensations ...Executable) CompensableExecutable {
+	return &compensableExecutable{Executable: exec, compensations: compensations}
+}
+
+type compensableExecutable struct {
+	Executable
+	compensations []Executable
+}
+
+func (exec *compensableExecutable) Compensations() []Executable {
+	return exec.compensations
+}
+
+func (exec *compensableExecutable) String() string {
+	return fmt.Sprintf(`%v`, exec.Executable)
+}
+
+// RegisterCompensation register compensating actions of the effect an executable just made.
+// No-op if the executable is not executed as part of an ExecutionPlan
+func RegisterCompensation(ctx context.Context, compensations ...Executable) {
+	if reg, ok := ctx.Value(compensationsKey{}).(*compensationRegistry); ok {
+		reg.add(compensations...)
+	}
+}
+
+type compensationsKey struct{}
+
+type compensationRegistry struct {
+	mtx   sync.Mutex
+	execs [][]Executable
+}
+
+func withCompensationRegistry(ctx context.Context) (context.Context, *compensationRegistry) {
+	reg := &compensationRegistry{}
+	return context.WithValue(ctx, compensationsKey{}, reg), reg
+}
+
+func (reg *compensationRegistry) add(execs ...Executable) {
+	if len(execs) == 0 {
+		return
+	}
+	reg.mtx.Lock()
+	defer reg.mtx.Unlock()
+	reg.execs = append(reg.execs, execs)
+}
+
+// registerStep register static compensations of given step, if any
+func (reg *compensationRegistry) registerStep(step Executable) {
+	if v, ok := step.(CompensableExecutable); ok {
+		reg.add(v.Compensations()...)
+	}
+}
+
+// rollback execute registered compensations in reverse order. Failed compensations are logged and skipped.
+// Returns false if there is nothing to roll back
+func (reg *compensationRegistry) rollback(ctx context.Context, opts ExecOption) bool {
+	reg.mtx.Lock()
+	defer reg.mtx.Unlock()
+	if len(reg.execs) == 0 {
+		return false
+	}
+	// the original context might be cancelled already
+	ctx = context.WithoutCancel(ctx)
+	logger.WithContext(ctx).Warnf(`Rolling back ...`)
+	for i := len(reg.execs) - 1; i >= 0; i-- {
+		for _, exec := range reg.execs[i] {
+			if opts.Verbose {
+				logger.WithContext(ctx).Infof(`Rollback: %v`, exec)
+			}
+			if e := exec.Exec(ctx, opts); e != nil {
+				logger.WithContext(ctx).Warnf(`Rollback step [%v] failed: %v`, exec, e)
+			}
+		}
+	}
+	reg.execs = nil
+	return true
+}
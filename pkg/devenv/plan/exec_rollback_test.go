@@ -0,0 +1,75 @@
+package plan
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestRollback(t *testing.T) {
+	oops := errors.New("oops")
+	// compensations of steps run in reverse order, compensations of same step run in declared order
+	tests := []struct {
+		name        string
+		rollback    bool
+		concurrency int
+		expected    []string
+	}{
+		{name: "sequential", rollback: true, concurrency: 1,
+			expected: []string{"undo failing", "undo runtime", "undo b1", "undo b2", "undo a"}},
+		{name: "concurrent", rollback: true, concurrency: 4,
+			expected: []string{"undo failing", "undo runtime", "undo b1", "undo b2", "undo a"}},
+		{name: "disabled", concurrency: 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var mtx sync.Mutex
+			var undone []string
+			var cancelled []bool
+			undo := func(name string) Executable {
+				return &funcExecutable{name: "undo " + name, fn: func(ctx context.Context) error {
+					mtx.Lock()
+					defer mtx.Unlock()
+					undone = append(undone, "undo "+name)
+					cancelled = append(cancelled, ctx.Err() != nil)
+					if name == "b1" {
+						return errors.New("failed compensations are skipped")
+					}
+					return nil
+				}}
+			}
+			ctx, cancelFn := context.WithCancel(context.Background())
+			defer cancelFn()
+			steps := []Executable{
+				WithCompensation(&funcExecutable{name: "a"}, undo("a")),
+				WithCompensation(&funcExecutable{name: "b"}, undo("b1"), undo("b2")),
+				&funcExecutable{name: "runtime", fn: func(ctx context.Context) error {
+					RegisterCompensation(ctx, undo("runtime"))
+					return nil
+				}},
+				// the failing step cancels the plan's context, compensations should still run
+				WithCompensation(&funcExecutable{name: "failing", fn: func(ctx context.Context) error {
+					cancelFn()
+					return oops
+				}}, undo("failing")),
+				WithCompensation(&funcExecutable{name: "not started"}, undo("not started")),
+			}
+			e := NewExecutionPlan(nil, steps...).Execute(ctx, func(opt *ExecOption) {
+				opt.StateStore = nil
+				opt.Rollback = test.rollback
+				opt.Concurrency = test.concurrency
+			})
+			if !errors.Is(e, oops) {
+				t.Errorf("expected error %v, but got %v", oops, e)
+			}
+			if !slices.Equal(undone, test.expected) {
+				t.Errorf("expected compensations %v, but got %v", test.expected, undone)
+			}
+			if slices.Contains(cancelled, true) {
+				t.Errorf("expected compensations to run with context not cancelled")
+			}
+		})
+	}
+}
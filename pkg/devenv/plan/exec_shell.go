@@ -3,9 +3,9 @@ package plan
 import (
 	"context"
 	"fmt"
-	"github.com/cisco-open/go-lanai/cmd/lanai-cli/cmdutils"
 	"github.com/stonedu1011/devenvctl/pkg/devenv"
 	"os"
+	"path/filepath"
 	"strings"
 )
 
@@ -30,15 +30,10 @@ func (exec ShellExecutable) Exec(ctx context.Context, opts ExecOption) error {
 		return nil
 	}
 	if opts.DryRun {
-		fmt.Printf("- %v\n", exec)
+		printPlanned(exec)
 		return nil
 	}
-	rc, e := cmdutils.RunShellCommands(ctx,
-		cmdutils.ShellShowCmd(opts.Verbose),
-		cmdutils.ShellDir(exec.WD),
-		cmdutils.ShellEnv(exec.Env...),
-		cmdutils.ShellCmd(exec.Cmds...),
-	)
+	rc, e := runShell(ctx, opts, exec.WD, exec.Env, StepOutput(ctx), exec.Cmds...)
 	switch {
 	case e != nil:
 		return e
@@ -78,21 +73,42 @@ func (exec MkdirExecutable) Exec(ctx context.Context, opts ExecOption) error {
 		return nil
 	}
 	if opts.DryRun {
-		fmt.Printf("- %v\n", exec)
+		printPlanned(exec)
 		return nil
 	}
 	for _, p := range exec.Paths {
 		if opts.Verbose {
 			logger.WithContext(ctx).Debugf(`creating direcotry: %s`, p)
 		}
+		created := firstMissingDir(p)
 		e := os.MkdirAll(p, 0755)
 		if e != nil {
 			return fmt.Errorf(`unable to create directory [%s]: %v`, p, e)
 		}
+		if len(created) != 0 {
+			RegisterCompensation(ctx, &RemoveDirExecutable{
+				Paths: []string{created},
+				Desc:  "remove created directory",
+			})
+		}
 	}
 	return nil
 }
 
+// firstMissingDir returns the top-most directory that MkdirAll would create for given path, or empty string if path exists
+func firstMissingDir(path string) string {
+	var missing string
+	for dir := filepath.Clean(path); ; dir = filepath.Dir(dir) {
+		if _, e := os.Stat(dir); e == nil {
+			return missing
+		}
+		missing = dir
+		if parent := filepath.Dir(dir); parent == dir {
+			return missing
+		}
+	}
+}
+
 func (exec MkdirExecutable) String() string {
 	if len(exec.Desc) == 0 {
 		exec.Desc = "mkdir"
@@ -107,6 +123,35 @@ func (exec MkdirExecutable) String() string {
 	}
 }
 
+// RemoveDirExecutable remove directories and all their content. Typically used as compensation of MkdirExecutable
+type RemoveDirExecutable struct {
+	Paths []string
+	Desc  string
+}
+
+func (exec RemoveDirExecutable) Exec(ctx context.Context, opts ExecOption) error {
+	if opts.DryRun {
+		printPlanned(exec)
+		return nil
+	}
+	for _, p := range exec.Paths {
+		if opts.Verbose {
+			logger.WithContext(ctx).Debugf(`removing direcotry: %s`, p)
+		}
+		if e := os.RemoveAll(p); e != nil {
+			return fmt.Errorf(`unable to remove directory [%s]: %v`, p, e)
+		}
+	}
+	return nil
+}
+
+func (exec RemoveDirExecutable) String() string {
+	if len(exec.Desc) == 0 {
+		exec.Desc = "rm -rf"
+	}
+	return fmt.Sprintf("%s: %s", exec.Desc, strings.Join(exec.Paths, ", "))
+}
+
 // ComposeShellExecutable ShellExecutable variant with special dry-run strategy for docker compose CLI
 type ComposeShellExecutable struct {
 	Args []string
@@ -122,16 +167,11 @@ func (exec ComposeShellExecutable) Exec(ctx context.Context, opts ExecOption) er
 	cmd := "docker compose " + strings.Join(exec.Args, " ")
 	if opts.DryRun {
 		cmd = cmd + " --dry-run"
-		fmt.Printf("- %v\n", exec)
+		printPlanned(exec)
 		opts.Verbose = false
 	}
 
-	rc, e := cmdutils.RunShellCommands(ctx,
-		cmdutils.ShellShowCmd(opts.Verbose),
-		cmdutils.ShellDir(exec.WD),
-		cmdutils.ShellEnv(exec.Env...),
-		cmdutils.ShellCmd(cmd),
-	)
+	rc, e := runShell(ctx, opts, exec.WD, exec.Env, StepOutput(ctx), cmd)
 	switch {
 	case e != nil:
 		return e
@@ -0,0 +1,85 @@
+package plan
+
+import (
+	"context"
+	"fmt"
+	dockerclient "github.com/docker/docker/client"
+	"github.com/stonedu1011/devenvctl/pkg/devenv"
+	"github.com/stonedu1011/devenvctl/pkg/devenv/snapshot"
+	"time"
+)
+
+// SnapshotCreateExecutable archive profile's local data directory and persisted volumes into snapshot store.
+// The profile is expected to be stopped before this step.
+type SnapshotCreateExecutable struct {
+	ApiClient *dockerclient.Client
+	Store     *snapshot.Store
+	Profile   *devenv.Profile
+	Name      string
+}
+
+func (exec *SnapshotCreateExecutable) Exec(ctx context.Context, opts ExecOption) error {
+	if opts.DryRun {
+		printPlanned(exec)
+		return nil
+	}
+	vols, e := ListPersistentVolumes(ctx, exec.ApiClient, exec.Profile.Name)
+	if e != nil {
+		return fmt.Errorf(`unable to list persisted volumes of profile [%s]: %v`, exec.Profile.Name, e)
+	}
+	m := snapshot.Manifest{
+		Profile:   exec.Profile.Name,
+		Name:      exec.Name,
+		CreatedAt: time.Now(),
+		DataDir:   exec.Profile.LocalDataDir,
+		Volumes:   make([]snapshot.VolumeManifest, len(vols)),
+	}
+	for i := range vols {
+		m.Volumes[i] = snapshot.VolumeManifest{
+			Name:   vols[i].Name,
+			Driver: vols[i].Driver,
+			Labels: vols[i].Labels,
+		}
+		if opts.Verbose {
+			logger.WithContext(ctx).Infof(`Archiving volume [%s] ...`, vols[i].Name)
+		}
+	}
+	logger.WithContext(ctx).Infof(`Creating snapshot [%s] of profile [%s] ...`, exec.Name, exec.Profile.Name)
+	if e := exec.Store.Create(ctx, exec.ApiClient, &m); e != nil {
+		return e
+	}
+	logger.WithContext(ctx).Infof(`Snapshot saved to [%s]`, exec.Store.Path(exec.Profile.Name, exec.Name))
+	return nil
+}
+
+func (exec *SnapshotCreateExecutable) String() string {
+	return fmt.Sprintf(`create snapshot [%s] of profile [%s]: %s`,
+		exec.Name, exec.Profile.Name, exec.Store.Path(exec.Profile.Name, exec.Name))
+}
+
+// SnapshotRestoreExecutable replace profile's local data directory and persisted volumes with content of a snapshot.
+// The profile is expected to be stopped before this step.
+type SnapshotRestoreExecutable struct {
+	ApiClient *dockerclient.Client
+	Store     *snapshot.Store
+	Profile   *devenv.Profile
+	Name      string
+}
+
+func (exec *SnapshotRestoreExecutable) Exec(ctx context.Context, opts ExecOption) error {
+	if opts.DryRun {
+		printPlanned(exec)
+		return nil
+	}
+	logger.WithContext(ctx).Infof(`Restoring snapshot [%s] of profile [%s] ...`, exec.Name, exec.Profile.Name)
+	if e := exec.Store.Restore(ctx, exec.ApiClient, exec.Profile.Name, exec.Name, exec.Profile.LocalDataDir); e != nil {
+		return e
+	}
+	logger.WithContext(ctx).Infof(`Snapshot [%s] restored`, exec.Name)
+	return nil
+}
+
+func (exec *SnapshotRestoreExecutable) String() string {
+	return fmt.Sprintf(`restore snapshot [%s] of profile [%s]: %s`,
+		exec.Name, exec.Profile.Name, exec.Store.Path(exec.Profile.Name, exec.Name))
+}
@@ -0,0 +1,86 @@
+package plan
+
+import (
+	"context"
+	"github.com/stonedu1011/devenvctl/pkg/devenv"
+	"github.com/stonedu1011/devenvctl/pkg/devenv/state"
+	"time"
+)
+
+// NewStateRecordExecutables create a pair of executables that mark the beginning and the end of steps
+// whose outcome should be recorded in ExecOption.StateStore.
+// If any step in between fails, the execution plan records the failure.
+// Both executables are no-op in dry-run mode or when state store is not available.
+func NewStateRecordExecutables(record *state.Record) (begin Executable, end Executable) {
+	return &stateBeginExecutable{Record: record}, &stateEndExecutable{Record: record}
+}
+
+type stateBeginExecutable struct {
+	Record *state.Record
+}
+
+func (exec *stateBeginExecutable) Exec(ctx context.Context, opts ExecOption) error {
+	if opts.DryRun || opts.StateStore == nil {
+		return nil
+	}
+	exec.Record.StartedAt = time.Now()
+	exec.Record.Outcome = state.OutcomeInProgress
+	saveState(ctx, opts.StateStore, exec.Record)
+	return nil
+}
+
+func (exec *stateBeginExecutable) String() string {
+	return "record state: " + exec.Record.Action + " " + exec.Record.Profile
+}
+
+type stateEndExecutable struct {
+	Record *state.Record
+}
+
+func (exec *stateEndExecutable) Exec(ctx context.Context, opts ExecOption) error {
+	if opts.DryRun || opts.StateStore == nil {
+		return nil
+	}
+	exec.Record.FinishedAt = time.Now()
+	exec.Record.Outcome = state.OutcomeSucceeded
+	if isFullStart(exec.Record) {
+		exec.Record.LastFullStart = exec.Record.FinishedAt
+	}
+	saveState(ctx, opts.StateStore, exec.Record)
+	return nil
+}
+
+func (exec *stateEndExecutable) String() string {
+	return "record state: " + exec.Record.Action + " " + exec.Record.Profile
+}
+
+// recordFailure find the state record that is in progress when steps[failedIdx] failed, and save it as failed
+func recordFailure(ctx context.Context, opts ExecOption, steps []Executable, failedIdx int, err error) {
+	if opts.DryRun || opts.StateStore == nil {
+		return
+	}
+	for i := failedIdx - 1; i >= 0; i-- {
+		switch v := steps[i].(type) {
+		case *stateEndExecutable:
+			return
+		case *stateBeginExecutable:
+			v.Record.FinishedAt = time.Now()
+			v.Record.Outcome = state.OutcomeFailed
+			v.Record.Error = devenv.MaskSecrets(err.Error())
+			saveState(ctx, opts.StateStore, v.Record)
+			return
+		}
+	}
+}
+
+// isFullStart returns true if the record is of an action that starts all services of the profile
+func isFullStart(record *state.Record) bool {
+	return (record.Action == string(ActionStart) || record.Action == string(ActionRestart)) && len(record.Services) == 0
+}
+
+// saveState save record to store. Failing to save state should not fail the plan.
+func saveState(ctx context.Context, store state.Store, record *state.Record) {
+	if e := store.Save(record); e != nil {
+		logger.WithContext(ctx).Warnf(`Unable to record state of profile [%s]: %v`, record.Profile, e)
+	}
+}
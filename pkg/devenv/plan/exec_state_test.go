@@ -0,0 +1,51 @@
+package plan
+
+import (
+	"context"
+	"errors"
+	"github.com/stonedu1011/devenvctl/pkg/devenv/state"
+	"testing"
+)
+
+func TestStateRecordExecutables(t *testing.T) {
+	tests := []struct {
+		name      string
+		services  []string
+		err       error
+		expected  state.Outcome
+		fullStart bool
+	}{
+		{name: "full start", expected: state.OutcomeSucceeded, fullStart: true},
+		{name: "selected services", services: []string{"db"}, expected: state.OutcomeSucceeded},
+		{name: "failed", err: errors.New("oops"), expected: state.OutcomeFailed},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := state.NewFileStore(t.TempDir())
+			begin, end := NewStateRecordExecutables(&state.Record{Profile: "test", Action: string(ActionStart), Services: test.services})
+			step := &funcExecutable{name: "step", fn: func(ctx context.Context) error {
+				if r, e := store.Load("test"); e != nil || r.Outcome != state.OutcomeInProgress {
+					t.Errorf("expected state in progress, but got %v, %v", r, e)
+				}
+				return test.err
+			}}
+			e := NewExecutionPlan(nil, begin, step, end).Execute(context.Background(), func(opt *ExecOption) {
+				opt.StateStore = store
+			})
+			if !errors.Is(e, test.err) {
+				t.Errorf("expected error %v, but got %v", test.err, e)
+			}
+			r, e := store.Load("test")
+			switch {
+			case e != nil:
+				t.Fatalf("unexpected error: %v", e)
+			case r.Outcome != test.expected || r.StartedAt.IsZero() || r.FinishedAt.IsZero():
+				t.Errorf("expected outcome %s, but got %+v", test.expected, r)
+			case test.err != nil && r.Error != test.err.Error():
+				t.Errorf("expected error recorded, but got %q", r.Error)
+			case r.LastFullStart.IsZero() == test.fullStart:
+				t.Errorf("unexpected last full start: %v", r.LastFullStart)
+			}
+		})
+	}
+}
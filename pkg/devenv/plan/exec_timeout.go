@@ -2,6 +2,7 @@ package plan
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 )
@@ -17,9 +18,81 @@ func (exec TimeoutExecutableWrapper) Exec(ctx context.Context, opts ExecOption)
 	}
 	timoutCtx, cancelFn := context.WithTimeout(ctx, exec.Timeout)
 	defer cancelFn()
-	return exec.Delegate.Exec(timoutCtx, opts)
+	e := exec.Delegate.Exec(timoutCtx, opts)
+	if e != nil && ctx.Err() == nil && errors.Is(timoutCtx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf(`[%v] timed out after %v: %v`, exec.Delegate, exec.Timeout, e)
+	}
+	return e
 }
 
 func (exec TimeoutExecutableWrapper) String() string {
 	return fmt.Sprintf(`%v`, exec.Delegate)
 }
+
+const (
+	// DefaultRetryDelay delay before the first retry, if RetryExecutableWrapper.Delay is not set
+	DefaultRetryDelay = time.Second
+	// MaxRetryDelay upper bound of delay between retries
+	MaxRetryDelay = 30 * time.Second
+)
+
+// RetryExecutableWrapper re-run the delegate up to Retries times if it fails.
+// Delay between attempts starts with Delay and is doubled after each attempt, up to MaxRetryDelay
+type RetryExecutableWrapper struct {
+	Retries  int
+	Delay    time.Duration
+	Delegate Executable
+}
+
+func (exec RetryExecutableWrapper) Exec(ctx context.Context, opts ExecOption) error {
+	if opts.DryRun {
+		printPlanned(exec)
+		return nil
+	}
+	delay := exec.Delay
+	if delay <= 0 {
+		delay = DefaultRetryDelay
+	}
+	var e error
+	for attempt := 0; ; attempt++ {
+		if e = exec.Delegate.Exec(ctx, opts); e == nil || attempt >= exec.Retries {
+			break
+		}
+		logger.WithContext(ctx).Warnf(`[%v] failed (attempt %d/%d), retrying in %v: %v`, exec.Delegate, attempt+1, exec.Retries+1, delay, e)
+		select {
+		case <-ctx.Done():
+			return e
+		case <-time.After(delay):
+		}
+		delay = min(delay*2, MaxRetryDelay)
+	}
+	return e
+}
+
+func (exec RetryExecutableWrapper) String() string {
+	delay := exec.Delay
+	if delay <= 0 {
+		delay = DefaultRetryDelay
+	}
+	return fmt.Sprintf(`%v (retries: %d, backoff: %v)`, exec.Delegate, exec.Retries, delay)
+}
+
+// SkippedExecutableWrapper is planned in place of an executable whose conditions were not met at plan time.
+// The delegate is never executed, the reason is reported instead
+type SkippedExecutableWrapper struct {
+	Reason   string
+	Delegate Executable
+}
+
+func (exec SkippedExecutableWrapper) Exec(ctx context.Context, opts ExecOption) error {
+	if opts.DryRun {
+		printPlanned(exec)
+		return nil
+	}
+	logger.WithContext(ctx).Infof(`Skipped [%v]: %s`, exec.Delegate, exec.Reason)
+	return nil
+}
+
+func (exec SkippedExecutableWrapper) String() string {
+	return fmt.Sprintf(`%v (skipped: %s)`, exec.Delegate, exec.Reason)
+}
@@ -0,0 +1,70 @@
+package plan
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTimeoutExecutableWrapper(t *testing.T) {
+	blocking := &funcExecutable{name: "blocking", fn: func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}}
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		cancel  bool
+		err     string
+	}{
+		{name: "timed out", timeout: 10 * time.Millisecond, err: "[blocking] timed out after 10ms: context deadline exceeded"},
+		{name: "cancelled", timeout: time.Minute, cancel: true, err: "context canceled"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancelFn := context.WithCancel(context.Background())
+			defer cancelFn()
+			if test.cancel {
+				time.AfterFunc(10*time.Millisecond, cancelFn)
+			}
+			e := TimeoutExecutableWrapper{Timeout: test.timeout, Delegate: blocking}.Exec(ctx, DefaultExecOption)
+			if e == nil || e.Error() != test.err {
+				t.Errorf("expected error %q, but got %v", test.err, e)
+			}
+		})
+	}
+}
+
+func TestRetryExecutableWrapper(t *testing.T) {
+	var attempts int
+	flaky := &funcExecutable{name: "flaky", fn: func(ctx context.Context) error {
+		if attempts++; attempts < 3 {
+			return errors.New("not yet")
+		}
+		return nil
+	}}
+	if e := (RetryExecutableWrapper{Retries: 2, Delay: time.Millisecond, Delegate: flaky}).Exec(context.Background(), DefaultExecOption); e != nil {
+		t.Errorf("unexpected error: %v", e)
+	}
+	attempts = 0
+	e := RetryExecutableWrapper{Retries: 1, Delay: time.Millisecond, Delegate: flaky}.Exec(context.Background(), DefaultExecOption)
+	if e == nil || !strings.Contains(e.Error(), "not yet") || attempts != 2 {
+		t.Errorf("expected error of last attempt after 2 attempts, but got %v after %d", e, attempts)
+	}
+}
+
+func TestSkippedExecutableWrapper(t *testing.T) {
+	var executed bool
+	exec := SkippedExecutableWrapper{Reason: "[SEED] is not set", Delegate: &funcExecutable{name: "seed", fn: func(ctx context.Context) error {
+		executed = true
+		return nil
+	}}}
+	if e := exec.Exec(context.Background(), DefaultExecOption); e != nil || executed {
+		t.Errorf("expected skipped executable not executed, but got %v, executed: %v", e, executed)
+	}
+	if desc := exec.String(); desc != "seed (skipped: [SEED] is not set)" {
+		t.Errorf("unexpected description: %s", desc)
+	}
+}
@@ -1,6 +1,8 @@
 package plan
 
 import (
+	"context"
+	"crypto/sha256"
 	"fmt"
 	dockerclient "github.com/docker/docker/client"
 	"github.com/stonedu1011/devenvctl/pkg/devenv"
@@ -10,52 +12,163 @@ import (
 	"time"
 )
 
+// DefaultContainerHookTimeout how long to wait for post-start hook containers if timeout is not configured.
+// Other hooks have no timeout by default
+const DefaultContainerHookTimeout = 30 * time.Second
+
 func NewScriptHookExecutables(hook devenv.Hook, wd string, vars []string, searchDirs ...string) ([]Executable, error) {
+	cmd, e := FindHookScript(hook, searchDirs...)
+	if e != nil {
+		return nil, e
+	}
+
+	exec := &ShellExecutable{
+		Cmds: []string{hookCommand(hook, cmd)},
+		WD:   hookWorkDir(hook, wd),
+		Env:  vars,
+		Desc: fmt.Sprintf(`%v shell`, hook.Phase),
+	}
+	return []Executable{exec}, nil
+}
+
+// FindHookScript returns path of the script file of given script hook. In each of searchDirs,
+// "<phase>/<script>" and "<phase>-<script>" are searched
+func FindHookScript(hook devenv.Hook, searchDirs ...string) (string, error) {
 	// note: we assume the value of hook is a script filename in resource directory
 	str, ok := hook.Value.(string)
 	if !ok {
-		return nil, fmt.Errorf(`expected script hook to have string value, but got %v`, hook.Value)
+		return "", fmt.Errorf(`expected script hook to have string value, but got %v`, hook.Value)
 	}
-	searchPaths := make([]string, 0, len(searchDirs) * 2)
+	searchPaths := make([]string, 0, len(searchDirs)*2)
 	for _, dir := range searchDirs {
 		searchPaths = append(searchPaths,
 			filepath.Join(dir, string(hook.Phase), str),
 			filepath.Join(dir, string(hook.Phase)+"-"+str),
 		)
 	}
-
-	var cmd string
 	for _, path := range searchPaths {
 		if stat, e := os.Stat(path); e == nil && !stat.IsDir() {
-			cmd = path
-			break
+			return path, nil
 		}
 	}
-	if len(cmd) == 0 {
-		return nil, fmt.Errorf(`hook script [%s] not found in [%s]`, str, strings.Join(searchDirs, ", "))
-	}
+	return "", fmt.Errorf(`hook script [%s] not found in [%s]`, str, strings.Join(searchDirs, ", "))
+}
 
-	exec := &ShellExecutable{
-		Cmds: []string{cmd},
-		WD:   wd,
-		Env:  vars,
-		Desc: fmt.Sprintf(`%v shell`, hook.Phase),
+// NewInlineHookExecutables create executables that write the command block of given hook into a script file in scriptDir,
+// and run it with the hook's interpreter ("sh" by default). The script file is written when executed, not when planned
+func NewInlineHookExecutables(hook devenv.Hook, wd string, vars []string, scriptDir string) ([]Executable, error) {
+	block, ok := hook.Value.(string)
+	if !ok {
+		return nil, fmt.Errorf(`expected inline hook to have string value, but got %v`, hook.Value)
+	}
+	if len(hook.Interpreter) == 0 {
+		hook.Interpreter = "sh"
+	}
+	// same command block in same phase results in same file
+	hash := sha256.Sum256([]byte(hook.Interpreter + "\n" + block))
+	path := filepath.Join(scriptDir, fmt.Sprintf(`%s-%x`, hook.Phase, hash[:6]))
+	exec := &InlineHookExecutable{
+		ShellExecutable: ShellExecutable{
+			Cmds: []string{hookCommand(hook, path)},
+			WD:   hookWorkDir(hook, wd),
+			Env:  vars,
+			Desc: fmt.Sprintf(`%v shell [%s]`, hook.Phase, hook.Name),
+		},
+		Name:   hook.Name,
+		Path:   path,
+		Script: block,
 	}
 	return []Executable{exec}, nil
 }
 
-// NewContainerHookExecutables create an executable that monitor existing containers and wait for it to stop
-// Note: Currently, we don't support manually start containers. So the hook containers should be started.
-//       Therefore, only post-start containers are possible.
+// InlineHookExecutable write the command block of an inline hook into a script file, then run the shell commands
+type InlineHookExecutable struct {
+	ShellExecutable
+	// Name of the hook
+	Name string
+	// Path of the script file
+	Path string
+	// Script the command block
+	Script string
+}
+
+func (exec InlineHookExecutable) Exec(ctx context.Context, opts ExecOption) error {
+	if opts.DryRun {
+		printPlanned(exec)
+		return nil
+	}
+	if e := os.MkdirAll(filepath.Dir(exec.Path), 0755); e != nil {
+		return fmt.Errorf(`unable to create directory [%s]: %v`, filepath.Dir(exec.Path), e)
+	}
+	if e := os.WriteFile(exec.Path, []byte(exec.Script), 0644); e != nil {
+		return fmt.Errorf(`unable to write inline hook [%s]: %v`, exec.Name, e)
+	}
+	return exec.ShellExecutable.Exec(ctx, opts)
+}
+
+// hookCommand returns shell command that runs given script file with the hook's interpreter and arguments
+func hookCommand(hook devenv.Hook, path string) string {
+	words := make([]string, 0, len(hook.Args)+2)
+	if len(hook.Interpreter) != 0 {
+		// interpreter may include its own flags, e.g. "bash -e"
+		words = append(words, hook.Interpreter)
+	}
+	words = append(words, shellQuote(path))
+	for _, arg := range hook.Args {
+		words = append(words, shellQuote(arg))
+	}
+	return strings.Join(words, " ")
+}
+
+func hookWorkDir(hook devenv.Hook, wd string) string {
+	switch {
+	case len(hook.WorkDir) == 0:
+		return wd
+	case filepath.IsAbs(hook.WorkDir):
+		return hook.WorkDir
+	default:
+		return filepath.Join(wd, hook.WorkDir)
+	}
+}
+
+// shellQuote quote given string with single quotes, so it's passed to shell as-is
+func shellQuote(s string) string {
+	return `'` + strings.ReplaceAll(s, `'`, `'\''`) + `'`
+}
+
+// ContinueOnErrorExecutableWrapper reports failure of the delegate as a warning instead of failing the plan
+type ContinueOnErrorExecutableWrapper struct {
+	Delegate Executable
+}
+
+// Exec ignores failure of the delegate, unless the plan is cancelled or timed out
+func (exec ContinueOnErrorExecutableWrapper) Exec(ctx context.Context, opts ExecOption) error {
+	if e := exec.Delegate.Exec(ctx, opts); e != nil {
+		if ctx.Err() != nil {
+			return e
+		}
+		logger.WithContext(ctx).Warnf(`Ignored failure of [%v]: %v`, exec.Delegate, e)
+	}
+	return nil
+}
+
+func (exec ContinueOnErrorExecutableWrapper) String() string {
+	return fmt.Sprintf(`%v (continue on error)`, exec.Delegate)
+}
+
+// NewContainerHookExecutables create an executable that monitor existing containers and wait for each to stop within its own timeout,
+// given by timeoutFn. DefaultContainerHookTimeout is used if the timeout of a hook is not positive.
+// Note: The hook containers should be already started together with services. Therefore, only post-start containers are possible.
+//       Container hooks in other phases are run on demand, see servicesPlanner
 func NewContainerHookExecutables(dockerClient *dockerclient.Client, phase devenv.HookPhase,
-	_ ComposePlanMetadata, cResolver ContainerResolver, hooks ...devenv.Hook) ([]Executable, error) {
+	_ ComposePlanMetadata, cResolver ContainerResolver, timeoutFn func(hook devenv.Hook) time.Duration, hooks ...devenv.Hook) ([]Executable, error) {
 
 	if phase != devenv.PhasePostStart {
 		return nil, fmt.Errorf(`container hooks are only supported in post-start phase`)
 	}
 
 	containers := make([]string, 0, len(hooks))
-	execs := make([]Executable, 0, 2)
+	timeouts := make(map[string]time.Duration, len(hooks))
 	for i := range hooks {
 		if hooks[i].Type != devenv.TypeContainer {
 			continue
@@ -65,13 +178,15 @@ func NewContainerHookExecutables(dockerClient *dockerclient.Client, phase devenv
 			return nil, fmt.Errorf(`expected hook value to be string, but got %v`, hooks[i].Value)
 		}
 		containers = append(containers, name)
+		if timeouts[name] = timeoutFn(hooks[i]); timeouts[name] <= 0 {
+			timeouts[name] = DefaultContainerHookTimeout
+		}
 	}
-	// TODO support dynamic timeout
 	exec := NewContainerMonitorExecutable(dockerClient, func(exec *ContainerMonitorExecutable) {
 		exec.Names = containers
+		exec.Timeouts = timeouts
 		exec.Desc = fmt.Sprintf(`%v containers`, phase)
 		exec.Resolver = cResolver
 	})
-	execs = append(execs, exec.WithTimeout(30 * time.Second))
-	return execs, nil
+	return []Executable{exec}, nil
 }
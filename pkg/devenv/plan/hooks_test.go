@@ -0,0 +1,76 @@
+package plan
+
+import (
+	"context"
+	"errors"
+	"github.com/stonedu1011/devenvctl/pkg/devenv"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHookCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		hook     devenv.Hook
+		expected string
+	}{
+		{name: "script", expected: `'/res/init.sh'`},
+		{name: "interpreter with flags", hook: devenv.Hook{Interpreter: "bash -e"}, expected: `bash -e '/res/init.sh'`},
+		{name: "quoted args", hook: devenv.Hook{Args: []string{"a b", "it's", "$HOME"}},
+			expected: `'/res/init.sh' 'a b' 'it'\''s' '$HOME'`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if cmd := hookCommand(test.hook, "/res/init.sh"); cmd != test.expected {
+				t.Errorf("expected %s, but got %s", test.expected, cmd)
+			}
+		})
+	}
+}
+
+func TestInlineHookExecutable(t *testing.T) {
+	wd, scriptDir := t.TempDir(), filepath.Join(t.TempDir(), inlineHooksDir)
+	if e := os.Mkdir(filepath.Join(wd, "sub"), 0755); e != nil {
+		t.Fatalf("unable to create directory: %v", e)
+	}
+	hook := devenv.Hook{
+		Name:    "greet",
+		Phase:   devenv.PhasePreStart,
+		Type:    devenv.TypeInline,
+		Value:   "echo \"$1 $GREETING\" > out.txt",
+		Args:    []string{"hello"},
+		WorkDir: "sub",
+	}
+	execs, e := NewInlineHookExecutables(hook, wd, []string{"GREETING=world"}, scriptDir)
+	if e != nil || len(execs) != 1 {
+		t.Fatalf("expected single executable, but got %v, %v", execs, e)
+	}
+	if _, e := os.Stat(scriptDir); e == nil {
+		t.Errorf("expected script not written when planned")
+	}
+	if e := execs[0].Exec(context.Background(), DefaultExecOption); e != nil {
+		t.Fatalf("unexpected error: %v", e)
+	}
+	if data, e := os.ReadFile(filepath.Join(wd, "sub", "out.txt")); e != nil || string(data) != "hello world\n" {
+		t.Errorf("expected output in working directory of the hook, but got %q, %v", data, e)
+	}
+
+	// same command block results in same script file
+	again, _ := NewInlineHookExecutables(hook, wd, nil, scriptDir)
+	if again[0].(*InlineHookExecutable).Path != execs[0].(*InlineHookExecutable).Path {
+		t.Errorf("expected same script file of same command block")
+	}
+}
+
+func TestContinueOnErrorExecutableWrapper(t *testing.T) {
+	failing := &funcExecutable{name: "failing", fn: func(ctx context.Context) error { return errors.New("oops") }}
+	if e := (ContinueOnErrorExecutableWrapper{Delegate: failing}).Exec(context.Background(), DefaultExecOption); e != nil {
+		t.Errorf("expected failure ignored, but got %v", e)
+	}
+	ctx, cancelFn := context.WithCancel(context.Background())
+	cancelFn()
+	if e := (ContinueOnErrorExecutableWrapper{Delegate: failing}).Exec(ctx, DefaultExecOption); e == nil {
+		t.Errorf("expected failure of cancelled plan not ignored")
+	}
+}
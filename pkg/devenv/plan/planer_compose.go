@@ -2,20 +2,34 @@ package plan
 
 import (
 	"context"
+	"crypto/sha256"
+	"errors"
 	"fmt"
 	lanaiutils "github.com/cisco-open/go-lanai/pkg/utils"
 	"github.com/docker/docker/api/types"
 	dockerclient "github.com/docker/docker/client"
 	"github.com/stonedu1011/devenvctl/pkg/devenv"
+	"github.com/stonedu1011/devenvctl/pkg/devenv/compose"
+	"github.com/stonedu1011/devenvctl/pkg/devenv/state"
 	"github.com/stonedu1011/devenvctl/pkg/utils"
 	"github.com/stonedu1011/devenvctl/pkg/utils/tmplutils"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
+	"strings"
+	"text/template"
 	"time"
 )
 
-const defaultComposeFile = `docker-compose.yml`
+const (
+	defaultComposeFile = `docker-compose.yml`
+	// inlineHooksDir directory within working directory where command blocks of inline hooks are written to
+	inlineHooksDir = `hooks`
+	// composePartialsPattern templates next to the docker compose template that can be used via `{{include "_name.tmpl" .}}`
+	composePartialsPattern = `_*.tmpl`
+)
 
 type ComposePlanMetadata struct {
 	Profile       *devenv.Profile
@@ -33,30 +47,62 @@ func (m ComposePlanMetadata) Project() *devenv.Profile {
 	return m.Profile
 }
 
-func NewDockerComposePlanner(p *devenv.Profile, wd string) *DockerComposePlanner {
-	plan := DockerComposePlanner{
-		Profile:    p,
-		WorkingDir: utils.AbsPath(wd, p.FS),
+func NewDockerComposePlanner(p *devenv.Profile, wd string, opts ...PlannerOptions) *DockerComposePlanner {
+	return &DockerComposePlanner{
+		PlannerConfig: newPlannerConfig(p, wd, opts...),
 	}
-	return &plan
 }
 
+// DockerComposePlanner plans lifecycle of profile's services using docker compose CLI
 type DockerComposePlanner struct {
-	// WorkingDir the working directory. Usually is the temporary dir configured by rootcmd.GlobalArgs
-	WorkingDir   string
-	Profile      *devenv.Profile
+	PlannerConfig
 	metadata     ComposePlanMetadata
 	dockerClient *dockerclient.Client
+	action       Action
+	// project the parsed rendered compose file, see composeProject
+	project *compose.Project
+	// lastFullStart when all services of the profile were last started successfully, zero if never
+	lastFullStart time.Time
 }
 
 func (pl *DockerComposePlanner) Prepare() (err error) {
-	logger.Infof(`Using Docker Compose`)
 	defer func() {
 		if err == nil {
 			logger.Infof(`Working directory is ready: %s`, pl.WorkingDir)
 		}
 	}()
 
+	// prepare a docker client (this client is not for docker compose)
+	var e error
+	if pl.dockerClient, e = NewDockerClient(); e != nil {
+		return e
+	}
+
+	// docker version
+	ctx, cancelFn := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelFn()
+	version, e := pl.dockerClient.ServerVersion(ctx)
+	if e != nil {
+		return e
+	}
+	if e := pl.render(true); e != nil {
+		return e
+	}
+	pl.metadata.DockerVersion = version
+	return nil
+}
+
+// Render copy resources and render docker compose file into working directory, without contacting Docker.
+// Secrets are not resolved, devenv.SecretMask is used as their values.
+func (pl *DockerComposePlanner) Render() (*ComposePlanMetadata, error) {
+	if e := pl.render(false); e != nil {
+		return nil, e
+	}
+	return &pl.metadata, nil
+}
+
+// render generate metadata, copy resources and render docker compose file into working directory
+func (pl *DockerComposePlanner) render(resolveSecrets bool) error {
 	// generate metadata
 	pl.metadata = ComposePlanMetadata{
 		Profile:      pl.Profile,
@@ -68,36 +114,38 @@ func (pl *DockerComposePlanner) Prepare() (err error) {
 	srcResPath := pl.Profile.ResourceDir
 	pl.metadata.ResourceDir = filepath.Join(pl.WorkingDir, filepath.Base(srcResPath))
 	logger.Debugf(`Copying resource files: %s`, srcResPath)
-	if e := utils.CopyDir(pl.Profile.FS, srcResPath, pl.metadata.ResourceDir); e != nil {
+	if e := utils.CopyDir(pl.Profile.ResourceFS, srcResPath, pl.metadata.ResourceDir); e != nil {
 		return e
 	}
 
 	// Variables
 	pl.metadata.Variables = devenv.NewVariablesWithProfile(pl.Profile)
-	pl.metadata.Variables.Add(devenv.Variable{Name: devenv.VarLocalDataPath, Value: pl.Profile.LocalDataDir})
-	pl.metadata.Variables.Add(devenv.Variable{Name: devenv.VarProjectResource, Value: filepath.Base(srcResPath)})
-	pl.metadata.Vars = pl.metadata.Variables.KVMap()
-
-	// prepare a docker client (this client is not for docker compose)
-	var e error
-	pl.dockerClient, e = dockerclient.NewClientWithOpts(dockerclient.WithAPIVersionNegotiation())
-	if e != nil {
-		return fmt.Errorf("docker client not available: %v", e)
-	}
-
-	// docker version
-	ctx, cancelFn := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancelFn()
-	if pl.metadata.DockerVersion, e = pl.dockerClient.ServerVersion(ctx); e != nil {
-		return e
+	pl.metadata.Variables.Add(devenv.Variable{Name: devenv.VarLocalDataPath, Value: pl.Profile.LocalDataDir, Source: devenv.VarSourceBuiltin})
+	pl.metadata.Variables.Add(devenv.Variable{Name: devenv.VarProjectResource, Value: filepath.Base(srcResPath), Source: devenv.VarSourceBuiltin})
+	if resolveSecrets {
+		secrets, e := devenv.ResolveSecrets(pl.Profile)
+		if e != nil {
+			return e
+		}
+		pl.metadata.Variables.Add(secrets...)
+	} else {
+		pl.metadata.Variables.Add(devenv.SecretPlaceholders(pl.Profile)...)
 	}
+	pl.metadata.Vars = pl.metadata.Variables.KVMap()
 
-	// load compose template
+	// load compose template and partials
 	tmplPath := filepath.Clean(pl.Profile.ComposePath)
 	logger.Debugf(`Loading [%s]`, tmplPath)
-	tmpl, e := tmplutils.NewTemplate().ParseFS(pl.Profile.FS, tmplPath)
+	tmpl, e := tmplutils.NewTemplate().Funcs(pl.templateFuncs()).ParseFS(pl.Profile.ComposeFS, tmplPath)
 	if e != nil {
-		return fmt.Errorf("unable to process docker compose template [%s]: %v", utils.AbsPath(tmplPath, pl.Profile.FS), e)
+		return fmt.Errorf("unable to process docker compose template [%s]: %v", utils.AbsPath(tmplPath, pl.Profile.ComposeFS), e)
+	}
+	partials, _ := fs.Glob(pl.Profile.ComposeFS, filepath.Join(filepath.Dir(tmplPath), composePartialsPattern))
+	if len(partials) != 0 {
+		logger.Debugf(`Loading partials %v`, partials)
+		if _, e := tmpl.ParseFS(pl.Profile.ComposeFS, partials...); e != nil {
+			return fmt.Errorf("unable to process docker compose partials %v: %v", partials, e)
+		}
 	}
 
 	// create a docker-compose.yml
@@ -109,11 +157,20 @@ func (pl *DockerComposePlanner) Prepare() (err error) {
 	case !fi.IsDir():
 		return fmt.Errorf(`unable to access directory [%s]: not a directory`, pl.WorkingDir)
 	}
-	composeF, e := os.OpenFile(pl.metadata.ComposePath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	// secrets might be rendered in clear text, e.g. via "{{ .Vars.SECRET }}", the file should be only readable by current user
+	var mode os.FileMode = 0644
+	if len(pl.Profile.Secrets) != 0 {
+		mode = 0600
+	}
+	composeF, e := os.OpenFile(pl.metadata.ComposePath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode)
 	if e != nil {
 		return fmt.Errorf(`unable to generate docker compose [%s]: %v`, pl.metadata.ComposePath, e)
 	}
 	defer func() { _ = composeF.Close() }()
+	// mode of existing file is not changed by OpenFile
+	if e := composeF.Chmod(mode); e != nil {
+		return fmt.Errorf(`unable to generate docker compose [%s]: %v`, pl.metadata.ComposePath, e)
+	}
 
 	// generate docker-compose.yml
 	if e := tmpl.ExecuteTemplate(composeF, filepath.Base(tmplPath), pl.metadata); e != nil {
@@ -122,19 +179,37 @@ func (pl *DockerComposePlanner) Prepare() (err error) {
 	return nil
 }
 
+// templateFuncs profile specific functions available to docker compose template, in addition to tmplutils.NewTemplate
+func (pl *DockerComposePlanner) templateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"hasService": func(name string) bool {
+			_, ok := pl.Profile.Services[name]
+			return ok
+		},
+	}
+}
+
 func (pl *DockerComposePlanner) Plan(action Action) (ExecutionPlan, error) {
+	logger.Infof(`Using Docker Compose`)
+	return pl.plan(action, pl)
+}
+
+// plan prepares working directory and plans given action. Services are started and stopped by given servicesPlanner
+func (pl *DockerComposePlanner) plan(action Action, sp servicesPlanner) (ExecutionPlan, error) {
 	if e := pl.Prepare(); e != nil {
 		return nil, e
 	}
+	pl.action = action
+	pl.lastFullStart = pl.loadLastFullStart()
 	var execs []Executable
 	var e error
 	switch action {
 	case ActionStart:
-		execs, e = pl.startPlan()
+		execs, e = pl.startPlan(sp)
 	case ActionStop:
-		execs, e = pl.stopPlan()
+		execs, e = pl.stopPlan(sp)
 	case ActionRestart:
-		execs, e = pl.restartPlan()
+		execs, e = pl.restartPlan(sp)
 	default:
 		e = ErrPlanNotAvailable
 	}
@@ -142,43 +217,81 @@ func (pl *DockerComposePlanner) Plan(action Action) (ExecutionPlan, error) {
 		return nil, e
 	}
 
+	// record state of the profile, excluding cleanup
+	record, e := pl.stateRecord(action)
+	if e != nil {
+		return nil, e
+	}
+	begin, end := NewStateRecordExecutables(record)
+	execs = append(append([]Executable{begin}, execs...), end)
+
 	execs = append(execs, pl.cleanupPlan()...)
 	return NewClosableExecutionPlan(pl.metadata, func() error {
 		return pl.dockerClient.Close()
 	}, execs...), nil
 }
 
-func (pl *DockerComposePlanner) startPlan() ([]Executable, error) {
+func (pl *DockerComposePlanner) startPlan(sp servicesPlanner) ([]Executable, error) {
+	scope, e := pl.serviceScope(true)
+	if e != nil {
+		return nil, e
+	}
 	plan := make([]Executable, 0, 5)
+	// step 0 check resource conflicts with other profiles and containers
+	if !pl.NoPreflight {
+		plan = append(plan, &PreflightExecutable{
+			ApiClient:   pl.dockerClient,
+			ProfileName: pl.Profile.Name,
+			ComposePath: pl.metadata.ComposePath,
+			Lookup:      pl.lookupVar,
+			Services:    scope.Values(),
+		})
+	}
+
 	// step 1 create data folders if not exist
-	dv, e := pl.dataVolumesPlan()
+	dv, e := pl.dataVolumesPlan(scope)
 	if e != nil {
 		return nil, e
 	}
 	plan = append(plan, dv...)
 
 	// step 2 pre-start hooks
-	pre, e := pl.hooksPlan(devenv.PhasePreStart, lanaiutils.NewGenericSet(devenv.TypeScript))
+	pre, e := pl.hooksPlan(sp, devenv.PhasePreStart, scope)
 	if e != nil {
 		return nil, e
 	}
 	plan = append(plan, pre...)
 
-	// step 3 docker compose start
-	//fmt.Sprintf(`docker compose -f "%s" -p "%s" build`, pl.metadata.ComposePath, pl.Profile.Name),
-	plan = append(plan, &ComposeShellExecutable{
-		Args: []string{
-			fmt.Sprintf(`-f "%s"`, pl.metadata.ComposePath),
-			fmt.Sprintf(`-p "%s"`, pl.Profile.Name),
-			"up", "-d", "--force-recreate", "--remove-orphans",
-		},
-		WD:   pl.WorkingDir,
-		Env:  NewShellVars(pl.metadata.Variables),
-		Desc: "start services",
-	})
+	// step 3 start services, together with monitored container hooks that are not part of the profile
+	up, e := sp.upPlan(pl.withHookContainers(devenv.PhasePostStart, scope))
+	if e != nil {
+		return nil, e
+	}
+	// on rollback, only the selected services are brought down. Dependencies might be running before this plan
+	downScope, e := pl.serviceScope(false)
+	if e != nil {
+		return nil, e
+	}
+	down, e := sp.downPlan(downScope)
+	if e != nil {
+		return nil, e
+	}
+	if len(up) != 0 {
+		up[0] = WithCompensation(up[0], down...)
+	}
+	plan = append(plan, up...)
 
-	// step 4 post-start hooks
-	post, e := pl.hooksPlan(devenv.PhasePostStart, lanaiutils.NewGenericSet(devenv.TypeScript, devenv.TypeContainer))
+	// step 4 wait for services to be ready
+	if !pl.NoWait {
+		wait, e := pl.readinessPlan(scope)
+		if e != nil {
+			return nil, e
+		}
+		plan = append(plan, wait...)
+	}
+
+	// step 5 post-start hooks
+	post, e := pl.hooksPlan(sp, devenv.PhasePostStart, scope)
 	if e != nil {
 		return nil, e
 	}
@@ -186,48 +299,57 @@ func (pl *DockerComposePlanner) startPlan() ([]Executable, error) {
 	return plan, nil
 }
 
-func (pl *DockerComposePlanner) stopPlan() ([]Executable, error) {
+func (pl *DockerComposePlanner) stopPlan(sp servicesPlanner) ([]Executable, error) {
+	scope, e := pl.serviceScope(false)
+	if e != nil {
+		return nil, e
+	}
 	plan := make([]Executable, 0, 5)
 	// step 1 pre-stop hooks
-	pre, e := pl.hooksPlan(devenv.PhasePreStop, lanaiutils.NewGenericSet(devenv.TypeScript))
+	pre, e := pl.hooksPlan(sp, devenv.PhasePreStop, scope)
 	if e != nil {
 		return nil, e
 	}
 	plan = append(plan, pre...)
 
-	// step 2 docker compose down
-	plan = append(plan, &ComposeShellExecutable{
-		Args: []string{
-			fmt.Sprintf(`-f "%s"`, pl.metadata.ComposePath),
-			fmt.Sprintf(`-p "%s"`, pl.Profile.Name),
-			"down", "--remove-orphans",
-		},
-		WD:   pl.WorkingDir,
-		Env:  NewShellVars(pl.metadata.Variables),
-		Desc: "stop services",
-	})
+	// step 2 stop services
+	down, e := sp.downPlan(scope)
+	if e != nil {
+		return nil, e
+	}
+	plan = append(plan, down...)
 
 	// step 3 post-stop hooks
-	post, e := pl.hooksPlan(devenv.PhasePostStop, lanaiutils.NewGenericSet(devenv.TypeScript))
+	post, e := pl.hooksPlan(sp, devenv.PhasePostStop, scope)
 	if e != nil {
 		return nil, e
 	}
 	plan = append(plan, post...)
 
+	// step 4 remove networks re-created by post-stop container hooks
+	isContainerHook := func(h devenv.Hook) bool { return h.Type == devenv.TypeContainer }
+	if scope == nil && slices.ContainsFunc(pl.Profile.Hooks.Phase(devenv.PhasePostStop), isContainerHook) {
+		cleanup, e := sp.downPlan(nil)
+		if e != nil {
+			return nil, e
+		}
+		plan = append(plan, cleanup...)
+	}
+
 	return plan, nil
 }
 
-func (pl *DockerComposePlanner) restartPlan() ([]Executable, error) {
+func (pl *DockerComposePlanner) restartPlan(sp servicesPlanner) ([]Executable, error) {
 	plan := make([]Executable, 0, 10)
 	// stop
-	stop, e := pl.stopPlan()
+	stop, e := pl.stopPlan(sp)
 	if e != nil {
 		return nil, e
 	}
 	plan = append(plan, stop...)
 
 	// start
-	start, e := pl.startPlan()
+	start, e := pl.startPlan(sp)
 	if e != nil {
 		return nil, e
 	}
@@ -235,50 +357,446 @@ func (pl *DockerComposePlanner) restartPlan() ([]Executable, error) {
 	return plan, nil
 }
 
-func (pl *DockerComposePlanner) hooksPlan(phase devenv.HookPhase, types lanaiutils.GenericSet[devenv.HookType]) ([]Executable, error) {
-	hooks, _ := pl.Profile.Hooks[phase]
+// upPlan implements servicesPlanner with "docker compose up"
+func (pl *DockerComposePlanner) upPlan(scope lanaiutils.StringSet) ([]Executable, error) {
+	//fmt.Sprintf(`docker compose -f "%s" -p "%s" build`, pl.metadata.ComposePath, pl.Profile.Name),
+	args := []string{
+		fmt.Sprintf(`-f "%s"`, pl.metadata.ComposePath),
+		fmt.Sprintf(`-p "%s"`, pl.Profile.Name),
+		"up", "-d", "--force-recreate",
+	}
+	if scope == nil {
+		args = append(args, "--remove-orphans")
+	} else {
+		names, e := pl.scopedServices(scope)
+		if e != nil {
+			return nil, e
+		}
+		// services in scope may not be defined in profile, e.g. compose dependencies or hook containers
+		others := make([]string, 0, len(scope))
+		for name := range scope {
+			if _, ok := pl.Profile.Services[name]; !ok {
+				others = append(others, name)
+			}
+		}
+		sort.Strings(others)
+		args = append(append(args, names...), others...)
+	}
+	return []Executable{
+		&ComposeShellExecutable{
+			Args: args,
+			WD:   pl.WorkingDir,
+			Env:  NewShellVars(pl.metadata.Variables),
+			Desc: "start services",
+		},
+	}, nil
+}
+
+// downPlan implements servicesPlanner with "docker compose down", or "stop" and "rm" for selected services
+func (pl *DockerComposePlanner) downPlan(scope lanaiutils.StringSet) ([]Executable, error) {
+	if scope == nil {
+		return []Executable{
+			&ComposeShellExecutable{
+				Args: []string{
+					fmt.Sprintf(`-f "%s"`, pl.metadata.ComposePath),
+					fmt.Sprintf(`-p "%s"`, pl.Profile.Name),
+					"down", "--remove-orphans",
+				},
+				WD:   pl.WorkingDir,
+				Env:  NewShellVars(pl.metadata.Variables),
+				Desc: "stop services",
+			},
+		}, nil
+	}
+	names, e := pl.scopedServices(scope)
+	if e != nil {
+		return nil, e
+	}
+	return []Executable{
+		&ComposeShellExecutable{
+			Args: append([]string{
+				fmt.Sprintf(`-f "%s"`, pl.metadata.ComposePath),
+				fmt.Sprintf(`-p "%s"`, pl.Profile.Name),
+				"stop",
+			}, names...),
+			WD:   pl.WorkingDir,
+			Env:  NewShellVars(pl.metadata.Variables),
+			Desc: "stop services",
+		}, &ComposeShellExecutable{
+			Args: append([]string{
+				fmt.Sprintf(`-f "%s"`, pl.metadata.ComposePath),
+				fmt.Sprintf(`-p "%s"`, pl.Profile.Name),
+				"rm", "-f", "-v",
+			}, names...),
+			WD:   pl.WorkingDir,
+			Env:  NewShellVars(pl.metadata.Variables),
+			Desc: "remove services",
+		},
+	}, nil
+}
+
+// runPlan implements servicesPlanner with "docker compose run"
+func (pl *DockerComposePlanner) runPlan(service string) ([]Executable, error) {
+	return []Executable{
+		&ComposeShellExecutable{
+			Args: []string{
+				fmt.Sprintf(`-f "%s"`, pl.metadata.ComposePath),
+				fmt.Sprintf(`-p "%s"`, pl.Profile.Name),
+				"run", "--rm", "--no-deps", "-T", service,
+			},
+			WD:   pl.WorkingDir,
+			Env:  NewShellVars(pl.metadata.Variables),
+			Desc: "run container",
+		},
+	}, nil
+}
+
+// hooksPlan create executables of hooks in given phase. See scopedHooks for hooks included when scope is not nil.
+// Container hooks in post-start phase are started together with services and monitored until they finish.
+// Container hooks in other phases are run on demand via servicesPlanner.
+func (pl *DockerComposePlanner) hooksPlan(sp servicesPlanner, phase devenv.HookPhase, scope lanaiutils.StringSet) ([]Executable, error) {
+	hooks := pl.scopedHooks(phase, scope)
 	execs := make([]Executable, 0, len(hooks))
 	vars := NewShellVars(pl.metadata.Variables)
-	var hasContainerHooks bool
+	var monitored []devenv.Hook
+	lastOfService := map[string]Executable{}
 	for i := range hooks {
-		if !types.Has(hooks[i].Type) {
-			return nil, fmt.Errorf(`hooks at phase [%v] only support %v types`, phase, types.Values())
-		}
-
-		switch hooks[i].Type {
-		case devenv.TypeScript:
-			subExecs, e := NewScriptHookExecutables(hooks[i], pl.metadata.WorkingDir, vars, pl.metadata.ResourceDir)
-			if e != nil {
-				return nil, e
+		var subExecs []Executable
+		var e error
+		switch {
+		case hooks[i].Type == devenv.TypeScript:
+			subExecs, e = NewScriptHookExecutables(hooks[i], pl.metadata.WorkingDir, pl.hookVars(hooks[i], vars), pl.metadata.ResourceDir)
+		case hooks[i].Type == devenv.TypeInline:
+			scriptDir := filepath.Join(pl.WorkingDir, inlineHooksDir)
+			subExecs, e = NewInlineHookExecutables(hooks[i], pl.metadata.WorkingDir, pl.hookVars(hooks[i], vars), scriptDir)
+		case hooks[i].Type == devenv.TypeContainer && phase == devenv.PhasePostStart:
+			monitored = append(monitored, hooks[i])
+			continue
+		case hooks[i].Type == devenv.TypeContainer:
+			name, ok := hooks[i].Value.(string)
+			if !ok {
+				return nil, fmt.Errorf(`expected hook value to be string, but got %v`, hooks[i].Value)
 			}
-			execs = append(execs, subExecs...)
-		case devenv.TypeContainer:
-			hasContainerHooks = true
+			subExecs, e = sp.runPlan(name)
 		default:
 			return nil, fmt.Errorf(`unsupported hook type [%v]`, hooks[i].Type)
 		}
+		if e != nil {
+			return nil, e
+		}
+		if timeout := pl.hookTimeout(hooks[i]); timeout > 0 {
+			subExecs = withTimeout(subExecs, timeout)
+		}
+		if hooks[i].Retries > 0 {
+			subExecs = withRetries(subExecs, hooks[i].Retries, hooks[i].RetryDelay)
+		}
+		if hooks[i].ContinueOnError {
+			subExecs = withContinueOnError(subExecs)
+		}
+		if reason := pl.hookSkipReason(hooks[i]); len(reason) != 0 {
+			subExecs = withSkipped(subExecs, reason)
+		}
+		if len(hooks[i].Service) != 0 {
+			subExecs = pl.withHookDependencies(phase, hooks[i].Service, subExecs, lastOfService)
+		}
+		execs = append(execs, subExecs...)
 	}
-	// All container hooks are grouped into single executable
-	if hasContainerHooks {
-		subExecs, e := NewContainerHookExecutables(pl.dockerClient, phase, pl.metadata, ComposeContainerResolver(pl.Profile.Name), hooks...)
+	// All monitored container hooks are grouped into single executable, and we wait for them before other hooks
+	if len(monitored) != 0 {
+		subExecs, e := NewContainerHookExecutables(pl.dockerClient, phase, pl.metadata, ComposeContainerResolver(pl.Profile.Name), pl.hookTimeout, monitored...)
 		if e != nil {
 			return nil, e
 		}
-		// Note: in post-start, we wait for container at the end. in pre-stop, we wait for container at beginning
-		if phase == devenv.PhasePostStop {
-			execs = append(execs, subExecs...)
-		} else {
-			execs = append(subExecs, execs...)
+		if !slices.ContainsFunc(monitored, func(h devenv.Hook) bool { return !h.ContinueOnError }) {
+			subExecs = withContinueOnError(subExecs)
 		}
-
+		execs = append(subExecs, execs...)
 	}
 	return execs, nil
 }
 
-func (pl *DockerComposePlanner) dataVolumesPlan() ([]Executable, error) {
+// scopedHooks returns hooks in given phase. If scope is not nil, hooks owned by services out of scope are excluded.
+// Hooks not owned by any service, i.e. profile-level hooks and all hooks of v1 profiles, are always included
+func (pl *DockerComposePlanner) scopedHooks(phase devenv.HookPhase, scope lanaiutils.StringSet) []devenv.Hook {
+	hooks := pl.Profile.Hooks.Phase(phase)
+	if scope == nil {
+		return hooks
+	}
+	scoped := make([]devenv.Hook, 0, len(hooks))
+	for i := range hooks {
+		if len(hooks[i].Service) == 0 || scope.Has(hooks[i].Service) {
+			scoped = append(scoped, hooks[i])
+		}
+	}
+	return scoped
+}
+
+// withHookContainers returns a copy of given scope including compose services of container hooks in given phase.
+// Returns nil if scope is nil, because all compose services are affected
+func (pl *DockerComposePlanner) withHookContainers(phase devenv.HookPhase, scope lanaiutils.StringSet) lanaiutils.StringSet {
+	if scope == nil {
+		return nil
+	}
+	ret := lanaiutils.NewStringSet(scope.Values()...)
+	for _, hook := range pl.scopedHooks(phase, scope) {
+		if name, ok := hook.Value.(string); ok && hook.Type == devenv.TypeContainer {
+			ret.Add(name)
+		}
+	}
+	return ret
+}
+
+// withHookDependencies make executables of a service hook depend on hooks of related services in same phase,
+// so that hooks of unrelated services may run concurrently.
+// Related services are the services it depends on in "*-start" phases, and services depending on it in "*-stop" phases.
+// Profile-level hooks are not affected, they always run after all preceding steps.
+func (pl *DockerComposePlanner) withHookDependencies(phase devenv.HookPhase, service string, execs []Executable, lastOfService map[string]Executable) []Executable {
+	deps := make([]Executable, 0, 5)
+	for _, name := range pl.relatedServices(phase, service) {
+		if last, ok := lastOfService[name]; ok {
+			deps = append(deps, last)
+		}
+	}
+	ret := make([]Executable, len(execs))
+	for i := range execs {
+		ret[i] = WithDependencies(execs[i], deps...)
+		deps = []Executable{ret[i]}
+	}
+	if len(ret) != 0 {
+		lastOfService[service] = ret[len(ret)-1]
+	}
+	return ret
+}
+
+// relatedServices returns given service and services it depends on (directly or indirectly) in "*-start" phases,
+// or services depending on it in "*-stop" phases
+func (pl *DockerComposePlanner) relatedServices(phase devenv.HookPhase, service string) []string {
+	if phase == devenv.PhasePreStart || phase == devenv.PhasePostStart {
+		deps, _ := devenv.ResolveServiceDependencies(pl.Profile.Services, service)
+		return deps
+	}
+	related := make([]string, 0, len(pl.Profile.Services))
+	for name := range pl.Profile.Services {
+		if deps, e := devenv.ResolveServiceDependencies(pl.Profile.Services, name); e == nil && slices.Contains(deps, service) {
+			related = append(related, name)
+		}
+	}
+	sort.Strings(related)
+	return related
+}
+
+// composeProject parse the rendered compose file. Variables are resolved the same way as docker compose CLI would
+func (pl *DockerComposePlanner) composeProject() (*compose.Project, error) {
+	if pl.project != nil {
+		return pl.project, nil
+	}
+	project, e := compose.LoadProject(pl.Profile.Name, pl.metadata.ComposePath, pl.lookupVar)
+	if e != nil {
+		return nil, e
+	}
+	pl.project = project
+	return pl.project, nil
+}
+
+// lookupVar resolve variables in rendered compose file the same way as docker compose CLI would:
+// variables of the plan first, then environment variables
+func (pl *DockerComposePlanner) lookupVar(name string) (string, bool) {
+	if v, ok := pl.metadata.Vars[name]; ok {
+		return v, true
+	}
+	return os.LookupEnv(name)
+}
+
+// hookTimeout returns timeout of given hook: PlannerConfig.HookTimeout if set, otherwise the hook's own timeout,
+// or the profile's default. Returns 0 if no timeout is applicable
+func (pl *DockerComposePlanner) hookTimeout(hook devenv.Hook) time.Duration {
+	switch {
+	case pl.HookTimeout > 0:
+		return pl.HookTimeout
+	case hook.Timeout > 0:
+		return hook.Timeout
+	default:
+		return pl.Profile.HookTimeout
+	}
+}
+
+// withTimeout wrap each executable with TimeoutExecutableWrapper
+func withTimeout(execs []Executable, timeout time.Duration) []Executable {
+	ret := make([]Executable, len(execs))
+	for i := range execs {
+		ret[i] = &TimeoutExecutableWrapper{Timeout: timeout, Delegate: execs[i]}
+	}
+	return ret
+}
+
+// withRetries wrap each executable with RetryExecutableWrapper
+func withRetries(execs []Executable, retries int, delay time.Duration) []Executable {
+	ret := make([]Executable, len(execs))
+	for i := range execs {
+		ret[i] = &RetryExecutableWrapper{Retries: retries, Delay: delay, Delegate: execs[i]}
+	}
+	return ret
+}
+
+// withSkipped replace each executable with SkippedExecutableWrapper
+func withSkipped(execs []Executable, reason string) []Executable {
+	ret := make([]Executable, len(execs))
+	for i := range execs {
+		ret[i] = &SkippedExecutableWrapper{Reason: reason, Delegate: execs[i]}
+	}
+	return ret
+}
+
+// hookSkipReason evaluate conditions of given hook. Returns the reason if the hook should be skipped, or empty string otherwise.
+// Conditions are evaluated when the plan is created, i.e. before any step is executed
+func (pl *DockerComposePlanner) hookSkipReason(hook devenv.Hook) string {
+	cond := hook.Condition
+	if len(cond.OnlyOn) != 0 && !slices.Contains(cond.OnlyOn, string(pl.action)) {
+		return fmt.Sprintf(`only on %v, current action is %s`, cond.OnlyOn, pl.action)
+	}
+	if cond.FirstStart && !pl.lastFullStart.IsZero() {
+		return fmt.Sprintf(`not first start, profile was started at %s`, pl.lastFullStart.Local().Format(time.RFC3339))
+	}
+	if len(cond.IfMissing) != 0 {
+		path := cond.IfMissing
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(pl.Profile.LocalDataDir, path)
+		}
+		if _, e := os.Stat(path); e == nil {
+			return fmt.Sprintf(`[%s] exists`, path)
+		}
+	}
+	if len(cond.IfEnv) != 0 {
+		name, expected, hasValue := strings.Cut(cond.IfEnv, "=")
+		value, ok := pl.metadata.Vars[name]
+		if !ok {
+			value = os.Getenv(name)
+		}
+		switch {
+		case hasValue && value != expected:
+			return fmt.Sprintf(`[%s] is not "%s"`, name, expected)
+		case !hasValue && len(value) == 0:
+			return fmt.Sprintf(`[%s] is not set`, name)
+		}
+	}
+	return ""
+}
+
+// withContinueOnError wrap each executable with ContinueOnErrorExecutableWrapper
+func withContinueOnError(execs []Executable) []Executable {
+	ret := make([]Executable, len(execs))
+	for i := range execs {
+		ret[i] = &ContinueOnErrorExecutableWrapper{Delegate: execs[i]}
+	}
+	return ret
+}
+
+// hookVars append environment of the service owning the hook, if any, followed by the hook's own environment
+func (pl *DockerComposePlanner) hookVars(hook devenv.Hook, vars []string) []string {
+	ret := make([]string, len(vars), len(vars)+len(hook.Env))
+	copy(ret, vars)
+	for _, env := range []map[string]string{pl.Profile.Services[hook.Service].Environment, hook.Env} {
+		keys := make([]string, 0, len(env))
+		for k := range env {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			ret = append(ret, devenv.Variable{Name: k, Value: env[k]}.String())
+		}
+	}
+	return ret
+}
+
+func (pl *DockerComposePlanner) stateRecord(action Action) (*state.Record, error) {
+	data, e := os.ReadFile(pl.metadata.ComposePath)
+	if e != nil {
+		return nil, fmt.Errorf(`unable to read docker compose [%s]: %v`, pl.metadata.ComposePath, e)
+	}
+	return &state.Record{
+		Profile:        pl.Profile.Name,
+		Action:         string(action),
+		Services:       pl.Services,
+		DefinitionPath: pl.Profile.DisplayPath,
+		WorkingDir:     pl.WorkingDir,
+		ComposePath:    pl.metadata.ComposePath,
+		ComposeHash:    fmt.Sprintf(`sha256:%x`, sha256.Sum256(data)),
+		Variables:      pl.metadata.Variables.MaskedKVMap(),
+		LastFullStart:  pl.lastFullStart,
+	}, nil
+}
+
+// loadLastFullStart returns when all services of the profile were last started successfully, according to recorded state.
+// Returns zero time if never, or if state is not available
+func (pl *DockerComposePlanner) loadLastFullStart() time.Time {
+	if pl.StateStore == nil {
+		return time.Time{}
+	}
+	record, e := pl.StateStore.Load(pl.Profile.Name)
+	switch {
+	case errors.Is(e, fs.ErrNotExist):
+		return time.Time{}
+	case e != nil:
+		logger.Warnf(`Unable to read state of profile [%s]: %v`, pl.Profile.Name, e)
+		return time.Time{}
+	case record.LastFullStart.IsZero() && isFullStart(record) && record.Outcome == state.OutcomeSucceeded:
+		// recorded before LastFullStart was introduced
+		return record.FinishedAt
+	default:
+		return record.LastFullStart
+	}
+}
+
+// serviceScope returns nil if planner is not scoped to selected services.
+// Otherwise, returns selected services, and optionally their dependencies according to "depends_on" of the rendered compose file,
+// which may include compose services not defined in profile.
+// If the compose file cannot be parsed, dependencies defined in profile are used instead
+func (pl *DockerComposePlanner) serviceScope(withDeps bool) (lanaiutils.StringSet, error) {
+	if len(pl.Services) == 0 {
+		return nil, nil
+	}
+	for _, name := range pl.Services {
+		if _, ok := pl.Profile.Services[name]; !ok {
+			return nil, fmt.Errorf(`unknown service [%s] in profile [%s]`, name, pl.Profile.Name)
+		}
+	}
+	if !withDeps {
+		return lanaiutils.NewStringSet(pl.Services...), nil
+	}
+	project, e := pl.composeProject()
+	if e != nil {
+		logger.Warnf(`Unable to resolve dependencies from docker compose [%s], using dependencies defined in profile: %v`, pl.metadata.ComposePath, e)
+		names, e := devenv.ResolveServiceDependencies(pl.Profile.Services, pl.Services...)
+		if e != nil {
+			return nil, e
+		}
+		return lanaiutils.NewStringSet(names...), nil
+	}
+	// selected services may not be defined in compose file, e.g. services rendered conditionally
+	scope := lanaiutils.NewStringSet(pl.Services...)
+	names := make([]string, 0, len(pl.Services))
+	for _, name := range pl.Services {
+		if _, ok := project.Services[name]; ok {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return scope, nil
+	}
+	order, e := project.ServiceOrder(names...)
+	if e != nil {
+		return nil, fmt.Errorf(`invalid compose file [%s]: %v`, project.ConfigPath, e)
+	}
+	return scope.Add(order...), nil
+}
+
+func (pl *DockerComposePlanner) dataVolumesPlan(scope lanaiutils.StringSet) ([]Executable, error) {
 	root := pl.Profile.LocalDataDir
 	paths := make([]string, 0, len(pl.Profile.Services)*2)
 	for _, s := range pl.Profile.Services {
+		if scope != nil && !scope.Has(s.Name) {
+			continue
+		}
 		for _, mount := range s.Mounts {
 			paths = append(paths, filepath.Join(root, mount))
 		}
@@ -292,10 +810,54 @@ func (pl *DockerComposePlanner) dataVolumesPlan() ([]Executable, error) {
 	}, nil
 }
 
+// scopedServices returns services in scope, or all services of the profile if scope is nil, sorted by dependencies.
+// See devenv.ResolveServiceOrder
+func (pl *DockerComposePlanner) scopedServices(scope lanaiutils.StringSet) ([]string, error) {
+	order, e := devenv.ResolveServiceOrder(pl.Profile.Services)
+	if e != nil {
+		return nil, e
+	}
+	names := make([]string, 0, len(order))
+	for _, name := range order {
+		if scope == nil || scope.Has(name) {
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// readinessPlan wait for all services in scope, or all services of the profile if scope is nil
+func (pl *DockerComposePlanner) readinessPlan(scope lanaiutils.StringSet) ([]Executable, error) {
+	names, e := pl.scopedServices(scope)
+	if e != nil {
+		return nil, e
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+	return []Executable{
+		NewServiceReadinessExecutable(pl.dockerClient, pl.Profile, func(exec *ServiceReadinessExecutable) {
+			exec.Services = names
+			if pl.WaitTimeout > 0 {
+				exec.Timeout = pl.WaitTimeout
+			}
+		}),
+	}, nil
+}
+
+// cleanupPlan prune docker resources according to profile's prune policy. See devenv.PrunePolicy
 func (pl *DockerComposePlanner) cleanupPlan() []Executable {
+	var project string
+	switch {
+	case pl.NoPrune || pl.Profile.Prune == devenv.PruneNone:
+		return nil
+	case pl.Profile.Prune == devenv.PruneGlobal:
+	default:
+		project = ComposeProjectName(pl.Profile.Name)
+	}
 	return []Executable{
-		&PruneContainersExecutable{ApiClient: pl.dockerClient},
-		&PruneVolumesExecutable{ApiClient: pl.dockerClient},
-		&PruneImagesExecutable{ApiClient: pl.dockerClient},
+		&PruneContainersExecutable{ApiClient: pl.dockerClient, Project: project},
+		&PruneVolumesExecutable{ApiClient: pl.dockerClient, Project: project},
+		&PruneImagesExecutable{ApiClient: pl.dockerClient, Project: project},
 	}
 }
@@ -0,0 +1,392 @@
+package plan
+
+import (
+	"fmt"
+	lanaiutils "github.com/cisco-open/go-lanai/pkg/utils"
+	"github.com/stonedu1011/devenvctl/pkg/devenv"
+	"github.com/stonedu1011/devenvctl/pkg/devenv/state"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testScopeProfile = `{
+	"version": 2,
+	"hooks": {"pre_start": ["init.sh"], "post_start": [{"container": "seed"}]},
+	"services": {
+		"app": {"image": "app:latest", "hooks": {"post_start": [{"container": "migrate"}]}},
+		"db": {"image": "postgres:16", "hooks": {"pre_start": [{"name": "prepare", "run": "echo"}]}},
+		"cache": {"image": "redis:7", "depends_on": ["db"], "hooks": {"post_start": [{"name": "warm", "run": "echo"}]}}
+	}
+}`
+
+const testScopeCompose = `{
+	"services": {
+		"app": {"image": "app:latest", "depends_on": ["db"]},
+		"db": {"image": "postgres:16", "depends_on": ["proxy"]},
+		"proxy": {"image": "envoy:latest"},
+		"cache": {"image": "redis:7"},
+		"seed": {"image": "seed:latest"},
+		"migrate": {"image": "migrate:latest"}
+	}
+}`
+
+// newTestComposePlanner create a planner of given profile definition scoped to given services,
+// with given content as rendered compose file
+func newTestComposePlanner(t *testing.T, profile, compose string, services ...string) *DockerComposePlanner {
+	p := loadTestProfile(t, map[string]string{"devenv-test.yml": profile})
+	pl := NewDockerComposePlanner(p, t.TempDir(), func(cfg *PlannerConfig) {
+		cfg.Services = services
+	})
+	pl.metadata.Variables = devenv.NewVariablesWithProfile(p)
+	pl.metadata.ComposePath = filepath.Join(pl.WorkingDir, defaultComposeFile)
+	if e := os.WriteFile(pl.metadata.ComposePath, []byte(compose), 0644); e != nil {
+		t.Fatalf("unable to write compose file: %v", e)
+	}
+	return pl
+}
+
+func TestServiceScope(t *testing.T) {
+	tests := []struct {
+		name     string
+		compose  string
+		services []string
+		withDeps bool
+		expected []string
+		err      string
+	}{
+		{name: "not scoped", compose: testScopeCompose},
+		{name: "without dependencies", compose: testScopeCompose, services: []string{"app"},
+			expected: []string{"app"}},
+		{name: "compose dependencies", compose: testScopeCompose, services: []string{"app"}, withDeps: true,
+			expected: []string{"app", "db", "proxy"}},
+		{name: "not in compose file", compose: `{"services": {"db": {"image": "postgres:16"}}}`,
+			services: []string{"app", "cache"}, withDeps: true, expected: []string{"app", "cache"}},
+		{name: "invalid compose file", compose: `{"services": {"app": {}}}`, services: []string{"cache"}, withDeps: true,
+			expected: []string{"cache", "db"}},
+		{name: "unknown service", compose: testScopeCompose, services: []string{"proxy"},
+			err: `unknown service [proxy] in profile [test]`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pl := newTestComposePlanner(t, testScopeProfile, test.compose, test.services...)
+			scope, e := pl.serviceScope(test.withDeps)
+			if len(test.err) != 0 {
+				if e == nil || e.Error() != test.err {
+					t.Fatalf("expected error %q, but got %v", test.err, e)
+				}
+				return
+			}
+			if e != nil {
+				t.Fatalf("unexpected error: %v", e)
+			}
+			if test.expected == nil {
+				if scope != nil {
+					t.Errorf("expected nil scope, but got %v", scope)
+				}
+				return
+			}
+			names := scope.Values()
+			sort.Strings(names)
+			if !slices.Equal(names, test.expected) {
+				t.Errorf("expected %v, but got %v", test.expected, names)
+			}
+		})
+	}
+}
+
+func TestScopedHooks(t *testing.T) {
+	tests := []struct {
+		name       string
+		services   []string
+		phase      devenv.HookPhase
+		expected   []string
+		containers []string
+	}{
+		{name: "not scoped", phase: devenv.PhasePostStart,
+			expected: []string{"migrate", "warm", "seed"}},
+		{name: "profile-level hooks kept", services: []string{"cache"}, phase: devenv.PhasePreStart,
+			expected: []string{"init.sh"}, containers: []string{"cache"}},
+		{name: "service hooks in scope", services: []string{"app"}, phase: devenv.PhasePostStart,
+			expected: []string{"migrate", "seed"}, containers: []string{"app", "migrate", "seed"}},
+		{name: "no service hooks in scope", services: []string{"db"}, phase: devenv.PhasePostStart,
+			expected: []string{"seed"}, containers: []string{"db", "seed"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pl := newTestComposePlanner(t, testScopeProfile, testScopeCompose, test.services...)
+			scope, e := pl.serviceScope(false)
+			if e != nil {
+				t.Fatalf("unexpected error: %v", e)
+			}
+			hooks := pl.scopedHooks(test.phase, scope)
+			names := make([]string, len(hooks))
+			for i := range hooks {
+				names[i] = hooks[i].Name
+			}
+			if !slices.Equal(names, test.expected) {
+				t.Errorf("expected hooks %v, but got %v", test.expected, names)
+			}
+			upScope := pl.withHookContainers(test.phase, scope)
+			if upScope == nil {
+				if scope != nil {
+					t.Errorf("expected scope with hook containers, but got nil")
+				}
+				return
+			}
+			containers := upScope.Values()
+			sort.Strings(containers)
+			if !slices.Equal(containers, test.containers) {
+				t.Errorf("expected services to start %v, but got %v", test.containers, containers)
+			}
+		})
+	}
+}
+
+// fakeServicesPlanner plans services with PrintExecutable describing the planned action
+type fakeServicesPlanner struct{}
+
+func (fakeServicesPlanner) upPlan(scope lanaiutils.StringSet) ([]Executable, error) {
+	return []Executable{PrintExecutable(fmt.Sprintf("up %d", len(scope)))}, nil
+}
+
+func (fakeServicesPlanner) downPlan(scope lanaiutils.StringSet) ([]Executable, error) {
+	return []Executable{PrintExecutable(fmt.Sprintf("down %d", len(scope)))}, nil
+}
+
+func (fakeServicesPlanner) runPlan(service string) ([]Executable, error) {
+	return []Executable{PrintExecutable("run " + service)}, nil
+}
+
+const testContainerHooksProfile = `{
+	"version": 2,
+	"hooks": {
+		"pre_start": [{"container": "init"}, "init.sh"],
+		"post_start": [{"name": "notify", "run": "echo"}, {"container": "seed", "timeout": "5s"}],
+		"pre_stop": [{"container": "dump"}],
+		"post_stop": [{"container": "cleanup"}]
+	},
+	"services": {"db": {"image": "postgres:16"}}
+}`
+
+func TestContainerHooksPlan(t *testing.T) {
+	tests := []struct {
+		name     string
+		phase    devenv.HookPhase
+		expected []string
+	}{
+		{name: "pre-start", phase: devenv.PhasePreStart, expected: []string{"print: run init", "pre-start shell: "}},
+		{name: "post-start", phase: devenv.PhasePostStart,
+			expected: []string{"post-start containers: seed (timeout 5s)", "post-start shell [notify]: "}},
+		{name: "pre-stop", phase: devenv.PhasePreStop, expected: []string{"print: run dump"}},
+		{name: "post-stop", phase: devenv.PhasePostStop, expected: []string{"print: run cleanup"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pl := newTestComposePlanner(t, testContainerHooksProfile, `{"services": {}}`)
+			pl.metadata.ResourceDir = t.TempDir()
+			_ = os.MkdirAll(filepath.Join(pl.metadata.ResourceDir, "pre-start"), 0755)
+			_ = os.WriteFile(filepath.Join(pl.metadata.ResourceDir, "pre-start", "init.sh"), []byte("echo"), 0755)
+			execs, e := pl.hooksPlan(fakeServicesPlanner{}, test.phase, nil)
+			if e != nil {
+				t.Fatalf("unexpected error: %v", e)
+			}
+			if len(execs) != len(test.expected) {
+				t.Fatalf("expected %v, but got %v", test.expected, execs)
+			}
+			for i := range execs {
+				if desc := fmt.Sprint(execs[i]); !strings.HasPrefix(desc, test.expected[i]) {
+					t.Errorf("expected %q, but got %q", test.expected[i], desc)
+				}
+			}
+		})
+	}
+}
+
+func TestStopPlanWithContainerHooks(t *testing.T) {
+	tests := []struct {
+		name     string
+		services []string
+		expected []string
+	}{
+		{name: "all services", expected: []string{"print: run dump", "print: down 0", "print: run cleanup", "print: down 0"}},
+		{name: "selected services", services: []string{"db"}, expected: []string{"print: run dump", "print: down 1", "print: run cleanup"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pl := newTestComposePlanner(t, testContainerHooksProfile, `{"services": {}}`, test.services...)
+			execs, e := pl.stopPlan(fakeServicesPlanner{})
+			if e != nil {
+				t.Fatalf("unexpected error: %v", e)
+			}
+			descs := make([]string, len(execs))
+			for i := range execs {
+				descs[i] = fmt.Sprint(execs[i])
+			}
+			if !slices.Equal(descs, test.expected) {
+				t.Errorf("expected %v, but got %v", test.expected, descs)
+			}
+		})
+	}
+}
+
+func TestHookTimeout(t *testing.T) {
+	tests := []struct {
+		name     string
+		override time.Duration
+		profile  time.Duration
+		hook     time.Duration
+		expected time.Duration
+	}{
+		{name: "none"},
+		{name: "profile default", profile: time.Minute, expected: time.Minute},
+		{name: "hook", profile: time.Minute, hook: 10 * time.Second, expected: 10 * time.Second},
+		{name: "override", override: 5 * time.Minute, profile: time.Minute, hook: 10 * time.Second, expected: 5 * time.Minute},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := &devenv.Profile{ProfileMetadata: devenv.ProfileMetadata{Name: "test"}, HookTimeout: test.profile}
+			pl := NewDockerComposePlanner(p, t.TempDir(), func(cfg *PlannerConfig) {
+				cfg.HookTimeout = test.override
+			})
+			if timeout := pl.hookTimeout(devenv.Hook{Timeout: test.hook}); timeout != test.expected {
+				t.Errorf("expected %v, but got %v", test.expected, timeout)
+			}
+		})
+	}
+}
+
+func TestHookSkipReason(t *testing.T) {
+	lastFullStart := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name          string
+		condition     devenv.HookCondition
+		lastFullStart time.Time
+		expected      string
+	}{
+		{name: "unconditional"},
+		{name: "on current action", condition: devenv.HookCondition{OnlyOn: []string{"stop", "restart"}}},
+		{name: "on other action", condition: devenv.HookCondition{OnlyOn: []string{"stop"}},
+			expected: "only on [stop], current action is restart"},
+		{name: "first start", condition: devenv.HookCondition{FirstStart: true}},
+		{name: "not first start", condition: devenv.HookCondition{FirstStart: true}, lastFullStart: lastFullStart,
+			expected: "not first start, profile was started at " + lastFullStart.Local().Format(time.RFC3339)},
+		{name: "missing path", condition: devenv.HookCondition{IfMissing: "db/missing"}},
+		{name: "existing path", condition: devenv.HookCondition{IfMissing: "db/seeded"}, expected: "db/seeded] exists"},
+		{name: "variable set", condition: devenv.HookCondition{IfEnv: "SEED"}},
+		{name: "variable not set", condition: devenv.HookCondition{IfEnv: "DEVENV_TEST_NOT_SET"}, expected: "[DEVENV_TEST_NOT_SET] is not set"},
+		{name: "variable equals", condition: devenv.HookCondition{IfEnv: "SEED=true"}},
+		{name: "variable not equal", condition: devenv.HookCondition{IfEnv: "SEED=false"}, expected: `[SEED] is not "false"`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pl := newTestComposePlanner(t, testScopeProfile, testScopeCompose)
+			pl.action = ActionRestart
+			pl.lastFullStart = test.lastFullStart
+			pl.metadata.Vars = map[string]string{"SEED": "true"}
+			_ = os.MkdirAll(filepath.Join(pl.Profile.LocalDataDir, "db", "seeded"), 0755)
+			reason := pl.hookSkipReason(devenv.Hook{Condition: test.condition})
+			switch {
+			case len(test.expected) == 0 && len(reason) != 0:
+				t.Errorf("expected hook not skipped, but got %q", reason)
+			case !strings.HasSuffix(reason, test.expected):
+				t.Errorf("expected %q, but got %q", test.expected, reason)
+			}
+		})
+	}
+}
+
+func TestLoadLastFullStart(t *testing.T) {
+	startedAt := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		record   *state.Record
+		expected time.Time
+	}{
+		{name: "never started"},
+		{name: "recorded", expected: startedAt, record: &state.Record{
+			Profile: "test", Action: "stop", Outcome: state.OutcomeSucceeded, LastFullStart: startedAt,
+		}},
+		{name: "recorded before last full start", expected: startedAt.Add(time.Minute), record: &state.Record{
+			Profile: "test", Action: "start", Outcome: state.OutcomeSucceeded, FinishedAt: startedAt.Add(time.Minute),
+		}},
+		{name: "failed start", record: &state.Record{
+			Profile: "test", Action: "start", Outcome: state.OutcomeFailed, FinishedAt: startedAt,
+		}},
+		{name: "selected services", record: &state.Record{
+			Profile: "test", Action: "start", Services: []string{"db"}, Outcome: state.OutcomeSucceeded, FinishedAt: startedAt,
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := state.NewFileStore(t.TempDir())
+			if test.record != nil {
+				if e := store.Save(test.record); e != nil {
+					t.Fatalf("unable to save state: %v", e)
+				}
+			}
+			pl := newTestComposePlanner(t, testScopeProfile, testScopeCompose)
+			pl.StateStore = store
+			if last := pl.loadLastFullStart(); !last.Equal(test.expected) {
+				t.Errorf("expected %v, but got %v", test.expected, last)
+			}
+		})
+	}
+}
+
+func TestRender(t *testing.T) {
+	p := loadTestProfile(t, map[string]string{
+		"devenv-test.yml": `{
+			"version": 2,
+			"secrets": {"TOKEN": {"env": "DEVENV_TEST_NOT_SET"}},
+			"services": {"app": {"image": "app:latest"}}
+		}`,
+		"docker-compose-test.yml": `services:
+  app:
+    image: app:latest
+    environment:
+      PROJECT: {{ .Vars.PROJECT_NAME }}
+      TOKEN: {{ .Vars.TOKEN }}
+{{- if hasService "cache" }}
+  cache:
+    image: redis:7
+{{- end }}
+`,
+	})
+	// resources in file system other than embed.FS are copied from OS paths
+	p.ResourceDir = filepath.Join(t.TempDir(), "res-test")
+	p.ResourceFS = os.DirFS(p.ResourceDir)
+	if e := os.MkdirAll(p.ResourceDir, 0755); e != nil {
+		t.Fatalf("unable to create resource directory: %v", e)
+	}
+	if e := os.WriteFile(filepath.Join(p.ResourceDir, "init.sh"), []byte("echo init"), 0755); e != nil {
+		t.Fatalf("unable to write resource file: %v", e)
+	}
+	pl := NewDockerComposePlanner(p, t.TempDir())
+	metadata, e := pl.Render()
+	if e != nil {
+		t.Fatalf("unexpected error: %v", e)
+	}
+
+	data, e := os.ReadFile(metadata.ComposePath)
+	if e != nil {
+		t.Fatalf("unable to read rendered compose file: %v", e)
+	}
+	expected := "services:\n  app:\n    image: app:latest\n    environment:\n      PROJECT: test\n      TOKEN: " + devenv.SecretMask + "\n"
+	if string(data) != expected {
+		t.Errorf("expected rendered compose file:\n%s\nbut got:\n%s", expected, data)
+	}
+	if fi, e := os.Stat(metadata.ComposePath); e != nil || fi.Mode().Perm() != 0600 {
+		t.Errorf("expected compose file with secrets only readable by current user, but got %v", fi.Mode())
+	}
+	if _, e := os.Stat(filepath.Join(pl.WorkingDir, "res-test", "init.sh")); e != nil {
+		t.Errorf("expected resources copied to working directory, but got %v", e)
+	}
+	if dotEnv := metadata.Variables.DotEnv(); !containsAll(dotEnv, "PROJECT_NAME=test\n", "TOKEN="+devenv.SecretMask+"\n") {
+		t.Errorf("expected variables with masked secrets, but got:\n%s", dotEnv)
+	}
+}
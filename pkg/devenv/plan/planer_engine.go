@@ -0,0 +1,113 @@
+package plan
+
+import (
+	"fmt"
+	lanaiutils "github.com/cisco-open/go-lanai/pkg/utils"
+	"github.com/stonedu1011/devenvctl/pkg/devenv"
+	"github.com/stonedu1011/devenvctl/pkg/devenv/compose"
+	"strings"
+)
+
+func NewDockerEnginePlanner(p *devenv.Profile, wd string, opts ...PlannerOptions) *DockerEnginePlanner {
+	return &DockerEnginePlanner{
+		DockerComposePlanner: *NewDockerComposePlanner(p, wd, opts...),
+	}
+}
+
+// DockerEnginePlanner plans lifecycle of profile's services by driving networks, volumes and containers
+// via Docker Engine API directly, according to the rendered docker compose file.
+// Working directory, hooks, readiness and cleanup are the same as DockerComposePlanner.
+type DockerEnginePlanner struct {
+	DockerComposePlanner
+}
+
+func (pl *DockerEnginePlanner) Plan(action Action) (ExecutionPlan, error) {
+	logger.Infof(`Using Docker Engine API`)
+	return pl.plan(action, pl)
+}
+
+// upPlan implements servicesPlanner
+func (pl *DockerEnginePlanner) upPlan(scope lanaiutils.StringSet) ([]Executable, error) {
+	project, e := pl.loadProject()
+	if e != nil {
+		return nil, e
+	}
+	// services in compose file may not be defined in profile, e.g. hook containers
+	var names []string
+	if scope != nil {
+		for name := range scope {
+			if _, ok := project.Services[name]; ok {
+				names = append(names, name)
+			}
+		}
+		if len(names) == 0 {
+			return nil, nil
+		}
+	}
+	order, e := project.ServiceOrder(names...)
+	if e != nil {
+		return nil, fmt.Errorf(`invalid compose file [%s]: %v`, project.ConfigPath, e)
+	}
+	return []Executable{
+		&EngineResourcesExecutable{ApiClient: pl.dockerClient, Project: project},
+		&EngineImagesExecutable{ApiClient: pl.dockerClient, Project: project, Services: order},
+		&EngineUpExecutable{ApiClient: pl.dockerClient, Project: project, Services: order, RemoveOrphans: scope == nil},
+	}, nil
+}
+
+// downPlan implements servicesPlanner
+func (pl *DockerEnginePlanner) downPlan(scope lanaiutils.StringSet) ([]Executable, error) {
+	project, e := pl.loadProject()
+	if e != nil {
+		return nil, e
+	}
+	if scope == nil {
+		return []Executable{
+			&EngineDownExecutable{ApiClient: pl.dockerClient, Project: project},
+		}, nil
+	}
+	order, e := project.ServiceOrder()
+	if e != nil {
+		return nil, fmt.Errorf(`invalid compose file [%s]: %v`, project.ConfigPath, e)
+	}
+	names := make([]string, 0, len(scope))
+	for _, name := range order {
+		if scope.Has(name) {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+	return []Executable{
+		&EngineDownExecutable{ApiClient: pl.dockerClient, Project: project, Services: names, RemoveVolumes: true},
+	}, nil
+}
+
+// runPlan implements servicesPlanner
+func (pl *DockerEnginePlanner) runPlan(service string) ([]Executable, error) {
+	project, e := pl.loadProject()
+	if e != nil {
+		return nil, e
+	}
+	if _, ok := project.Services[service]; !ok {
+		return nil, fmt.Errorf(`container hook [%s] is not defined in compose file [%s]`, service, project.ConfigPath)
+	}
+	return []Executable{
+		&EngineRunExecutable{ApiClient: pl.dockerClient, Project: project, Service: service},
+	}, nil
+}
+
+// loadProject parse the rendered compose file, see DockerComposePlanner.composeProject.
+// Compose files with unsupported attributes are refused, because containers would differ from what docker compose creates
+func (pl *DockerEnginePlanner) loadProject() (*compose.Project, error) {
+	project, e := pl.composeProject()
+	if e != nil {
+		return nil, e
+	}
+	if len(project.Unsupported) != 0 {
+		return nil, fmt.Errorf(`compose file [%s] uses attributes not supported by engine [%s]: %s. Use engine [%s] instead`,
+			project.ConfigPath, EngineDocker, strings.Join(project.Unsupported, ", "), EngineCompose)
+	}
+	return project, nil
+}
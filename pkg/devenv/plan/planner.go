@@ -0,0 +1,79 @@
+package plan
+
+import (
+	"fmt"
+	lanaiutils "github.com/cisco-open/go-lanai/pkg/utils"
+	"github.com/stonedu1011/devenvctl/pkg/devenv"
+	"github.com/stonedu1011/devenvctl/pkg/devenv/state"
+	"github.com/stonedu1011/devenvctl/pkg/utils"
+	"time"
+)
+
+const (
+	// EngineCompose runs profiles with docker compose CLI. This is the default
+	EngineCompose = `compose`
+	// EngineDocker runs profiles with Docker Engine API directly. docker compose CLI is not required
+	EngineDocker = `docker`
+)
+
+type PlannerOptions func(cfg *PlannerConfig)
+
+// PlannerConfig common configuration of ExecutionPlanner implementations
+type PlannerConfig struct {
+	// WorkingDir the working directory. Usually is the temporary dir configured by rootcmd.GlobalArgs
+	WorkingDir string
+	Profile    *devenv.Profile
+	// Services optional. When specified, only given services are started/stopped, and only their hooks are executed.
+	// When starting, services they depend on are also included.
+	Services []string
+	// NoPrune skip docker pruning at the end of the plan, regardless of profile's prune policy. See devenv.PrunePolicy
+	NoPrune bool
+	// NoWait skip waiting for services to be ready before post-start hooks
+	NoWait bool
+	// WaitTimeout overall timeout of waiting for services to be ready. DefaultReadinessTimeout is used if not set
+	WaitTimeout time.Duration
+	// HookTimeout optional, overrides timeouts of all hooks, including those defined per hook and profile's default
+	HookTimeout time.Duration
+	// NoPreflight skip checking resource conflicts (ports, container names, networks, volumes) before starting services
+	NoPreflight bool
+	// StateStore optional, where recorded state of the profile is read from when planning, e.g. for "first_start" hooks.
+	// Should be same as ExecOption.StateStore. state.DefaultStore is used by default
+	StateStore state.Store
+}
+
+func newPlannerConfig(p *devenv.Profile, wd string, opts ...PlannerOptions) PlannerConfig {
+	cfg := PlannerConfig{
+		Profile:    p,
+		WorkingDir: utils.AbsPath(wd, p.FS),
+		StateStore: state.DefaultStore(),
+	}
+	for _, fn := range opts {
+		fn(&cfg)
+	}
+	return cfg
+}
+
+// NewPlanner create ExecutionPlanner of given engine. If engine is empty, the engine configured in profile is used.
+// EngineCompose is the default if neither is set.
+func NewPlanner(p *devenv.Profile, wd string, engine string, opts ...PlannerOptions) (ExecutionPlanner, error) {
+	if len(engine) == 0 {
+		engine = p.Engine
+	}
+	switch engine {
+	case "", EngineCompose:
+		return NewDockerComposePlanner(p, wd, opts...), nil
+	case EngineDocker:
+		return NewDockerEnginePlanner(p, wd, opts...), nil
+	default:
+		return nil, fmt.Errorf(`unsupported engine [%s], should be one of [%s, %s]`, engine, EngineCompose, EngineDocker)
+	}
+}
+
+// servicesPlanner plans the steps that create and start, or stop and remove service containers.
+// scope is nil if all services are affected
+type servicesPlanner interface {
+	upPlan(scope lanaiutils.StringSet) ([]Executable, error)
+	downPlan(scope lanaiutils.StringSet) ([]Executable, error)
+	// runPlan run a one-off container of given compose service and wait for it to finish. Used by container hooks
+	runPlan(service string) ([]Executable, error)
+}
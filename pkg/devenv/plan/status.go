@@ -0,0 +1,151 @@
+package plan
+
+import (
+	"context"
+	"fmt"
+	"github.com/docker/docker/api/types"
+	dockerclient "github.com/docker/docker/client"
+	"github.com/stonedu1011/devenvctl/pkg/devenv"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	StateMissing = `missing`
+)
+
+type ServiceStatus struct {
+	Name        string
+	DisplayName string
+	// Container name of the container. Empty if the service is not found at runtime
+	Container string
+	// State container state, e.g. "running", "exited". StateMissing if the service is not found at runtime
+	State string
+	// Health container health status, e.g. "healthy", "unhealthy", "starting". Empty if container has no healthcheck
+	Health    string
+	StartedAt time.Time
+	Ports     []string
+	Image     string
+}
+
+func (s ServiceStatus) Running() bool {
+	return s.State == "running"
+}
+
+func (s ServiceStatus) Missing() bool {
+	return s.State == StateMissing
+}
+
+func (s ServiceStatus) Uptime() string {
+	if !s.Running() || s.StartedAt.IsZero() {
+		return "-"
+	}
+	return time.Since(s.StartedAt).Round(time.Second).String()
+}
+
+type ProfileStatus struct {
+	Profile *devenv.Profile
+	// Services status of services defined in profile, ordered by service dependencies
+	Services []ServiceStatus
+	// Others status of containers in same compose project but not defined as service in profile. e.g. hook containers
+	Others []ServiceStatus
+}
+
+func (s ProfileStatus) RunningCount() (count int) {
+	for i := range s.Services {
+		if s.Services[i].Running() {
+			count++
+		}
+	}
+	return
+}
+
+// ResolveProfileStatus find containers of given profile by docker compose project label,
+// and inspect them for state, health and uptime.
+func ResolveProfileStatus(ctx context.Context, client *dockerclient.Client, p *devenv.Profile) (*ProfileStatus, error) {
+	containers, e := ListComposeContainers(ctx, client, p.Name)
+	if e != nil {
+		return nil, fmt.Errorf(`unable to list containers of profile [%s]: %v`, p.Name, e)
+	}
+	order, e := devenv.ResolveServiceOrder(p.Services)
+	if e != nil {
+		return nil, e
+	}
+
+	byService := map[string]*types.Container{}
+	for i := range containers {
+		byService[containers[i].Labels[LabelComposeService]] = &containers[i]
+	}
+	status := ProfileStatus{
+		Profile:  p,
+		Services: make([]ServiceStatus, 0, len(order)),
+	}
+	for _, name := range order {
+		svc := p.Services[name]
+		s := ServiceStatus{
+			Name:        name,
+			DisplayName: svc.DisplayName,
+			State:       StateMissing,
+			Image:       svc.Image,
+		}
+		if c, ok := byService[name]; ok {
+			if e := inspectServiceStatus(ctx, client, c, &s); e != nil {
+				return nil, e
+			}
+			delete(byService, name)
+		}
+		status.Services = append(status.Services, s)
+	}
+	for name, c := range byService {
+		s := ServiceStatus{Name: name, DisplayName: name}
+		if e := inspectServiceStatus(ctx, client, c, &s); e != nil {
+			return nil, e
+		}
+		status.Others = append(status.Others, s)
+	}
+	sort.SliceStable(status.Others, func(i, j int) bool { return status.Others[i].Name < status.Others[j].Name })
+	return &status, nil
+}
+
+func inspectServiceStatus(ctx context.Context, client *dockerclient.Client, c *types.Container, s *ServiceStatus) error {
+	s.Container = c.ID
+	if len(c.Names) != 0 {
+		s.Container = strings.TrimPrefix(c.Names[0], "/")
+	}
+	s.State = c.State
+	s.Image = c.Image
+	s.Ports = formatPorts(c.Ports)
+	info, e := client.ContainerInspect(ctx, c.ID)
+	if e != nil {
+		return fmt.Errorf(`unable to inspect container [%s]: %v`, s.Container, e)
+	}
+	if info.State == nil {
+		return nil
+	}
+	if info.State.Health != nil {
+		s.Health = info.State.Health.Status
+	}
+	if t, e := time.Parse(time.RFC3339Nano, info.State.StartedAt); e == nil {
+		s.StartedAt = t
+	}
+	return nil
+}
+
+func formatPorts(ports []types.Port) []string {
+	ret := make([]string, 0, len(ports))
+	for _, p := range ports {
+		if p.PublicPort == 0 {
+			continue
+		}
+		ip := p.IP
+		if len(ip) == 0 {
+			ip = "0.0.0.0"
+		}
+		ret = append(ret, fmt.Sprintf(`%s:%d->%d/%s`, ip, p.PublicPort, p.PrivatePort, p.Type))
+	}
+	sort.Strings(ret)
+	// docker reports same port for IPv4 and IPv6 separately
+	return slices.Compact(ret)
+}
@@ -0,0 +1,115 @@
+package plan
+
+import (
+	"context"
+	"github.com/docker/docker/api/types"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testStatusProfile = `{
+	"version": 2,
+	"services": {
+		"app": {"display_name": "Application", "image": "app:latest", "depends_on": ["db"]},
+		"db": {"display_name": "Database", "image": "postgres:16"}
+	}
+}`
+
+func TestResolveProfileStatus(t *testing.T) {
+	startedAt := time.Now().Add(-time.Hour).UTC()
+	fake, client := newFakeDocker(t, map[string]interface{}{
+		"GET /containers/json": []map[string]interface{}{
+			{
+				"Id": "c-db", "Names": []string{"/test-db-1"}, "Image": "postgres:16", "State": "running",
+				"Labels": map[string]string{LabelComposeService: "db"},
+				"Ports": []map[string]interface{}{
+					{"IP": "0.0.0.0", "PrivatePort": 5432, "PublicPort": 15432, "Type": "tcp"},
+					{"IP": "0.0.0.0", "PrivatePort": 5432, "PublicPort": 15432, "Type": "tcp"},
+					{"PrivatePort": 8080, "Type": "tcp"},
+				},
+			},
+			{
+				"Id": "c-seed", "Names": []string{"/test-seed-1"}, "Image": "seed", "State": "exited",
+				"Labels": map[string]string{LabelComposeService: "seed"},
+			},
+		},
+		"GET /containers/c-db/json": map[string]interface{}{
+			"Id":    "c-db",
+			"State": map[string]interface{}{"StartedAt": startedAt.Format(time.RFC3339Nano), "Health": map[string]interface{}{"Status": "healthy"}},
+		},
+		"GET /containers/c-seed/json": map[string]interface{}{
+			"Id":    "c-seed",
+			"State": map[string]interface{}{"StartedAt": "0001-01-01T00:00:00Z"},
+		},
+	})
+	p := loadTestProfile(t, map[string]string{"devenv-test.yml": testStatusProfile})
+
+	status, e := ResolveProfileStatus(context.Background(), client, p)
+	if e != nil {
+		t.Fatalf("unexpected error: %v", e)
+	}
+
+	reqs := fake.Requested("GET /containers/json")
+	if len(reqs) != 1 {
+		t.Fatalf("expected containers to be listed once, but got %v", reqs)
+	}
+	if query, _ := url.QueryUnescape(reqs[0]); !strings.Contains(query, "all=1") || !strings.Contains(query, LabelComposeProject+"=test") {
+		t.Errorf("expected all containers of compose project to be listed, but got %s", query)
+	}
+
+	if len(status.Services) != 2 || status.Services[0].Name != "db" || status.Services[1].Name != "app" {
+		t.Fatalf("expected services in dependency order, but got %v", status.Services)
+	}
+	db, app := status.Services[0], status.Services[1]
+	switch {
+	case !db.Running() || db.Container != "test-db-1" || db.Health != "healthy" || db.Image != "postgres:16":
+		t.Errorf("unexpected status of running service: %+v", db)
+	case !slices.Equal(db.Ports, []string{"0.0.0.0:15432->5432/tcp"}):
+		t.Errorf("expected published ports without duplicates, but got %v", db.Ports)
+	case !db.StartedAt.Equal(startedAt) || db.Uptime() == "-":
+		t.Errorf("expected start time %v, but got %v", startedAt, db.StartedAt)
+	}
+	switch {
+	case !app.Missing() || app.Running() || len(app.Container) != 0:
+		t.Errorf("expected missing service, but got %+v", app)
+	case app.DisplayName != "Application" || app.Image != "app:latest" || app.Uptime() != "-":
+		t.Errorf("expected missing service to be described by profile, but got %+v", app)
+	}
+	if len(status.Others) != 1 || status.Others[0].Name != "seed" || status.Others[0].State != "exited" {
+		t.Errorf("expected container not defined in profile in others, but got %v", status.Others)
+	}
+	if count := status.RunningCount(); count != 1 {
+		t.Errorf("expected 1 running service, but got %d", count)
+	}
+}
+
+func TestFormatPorts(t *testing.T) {
+	tests := []struct {
+		name     string
+		ports    []types.Port
+		expected []string
+	}{
+		{name: "none", expected: []string{}},
+		{name: "not published", ports: []types.Port{{PrivatePort: 8080, Type: "tcp"}}, expected: []string{}},
+		{name: "any address",
+			ports:    []types.Port{{PrivatePort: 8500, PublicPort: 8500, Type: "tcp"}},
+			expected: []string{"0.0.0.0:8500->8500/tcp"}},
+		{name: "sorted and compacted",
+			ports: []types.Port{
+				{IP: "127.0.0.1", PrivatePort: 53, PublicPort: 1053, Type: "udp"},
+				{IP: "0.0.0.0", PrivatePort: 8200, PublicPort: 8200, Type: "tcp"},
+				{IP: "0.0.0.0", PrivatePort: 8200, PublicPort: 8200, Type: "tcp"},
+			},
+			expected: []string{"0.0.0.0:8200->8200/tcp", "127.0.0.1:1053->53/udp"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if ports := formatPorts(test.ports); !slices.Equal(ports, test.expected) {
+				t.Errorf("expected %v, but got %v", test.expected, ports)
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package plan
+
+import (
+	"fmt"
+	"github.com/stonedu1011/devenvctl/pkg/devenv"
+	"github.com/stonedu1011/devenvctl/pkg/devenv/compose"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ValidateProfile render docker compose file of given profile into wd without contacting Docker, and check that:
+//   - every service of the profile is defined in the compose file
+//   - every variable referenced in the compose file is defined, unless it has a default value
+//   - every script hook can be found in the resource directory
+//   - every container hook is a service in the compose file
+//   - every mount of services is used by a bind volume in the compose file
+//
+// Problems of the profile are reported at positions of profile's devenv.SourceMap if available.
+// Problems of the compose file are reported at positions of the rendered file.
+// If the rendered file is valid YAML but not a valid compose file, services and hooks are still checked against its "services" section.
+func ValidateProfile(p *devenv.Profile, wd string) devenv.Problems {
+	pl := NewDockerComposePlanner(p, wd)
+	metadata, e := pl.Render()
+	if e != nil {
+		return devenv.Problems{{Position: devenv.Position{Path: p.DisplayPath}, Message: e.Error()}}
+	}
+	v := profileValidator{profile: p, metadata: metadata}
+	v.checkVariables()
+	project, e := compose.LoadProject(ComposeProjectName(p.Name), metadata.ComposePath, pl.lookupVar)
+	if e != nil {
+		v.report(devenv.Position{Path: metadata.ComposePath}, e.Error())
+	}
+	if services, ok := v.composeServices(project); ok {
+		v.checkServices(services)
+		v.checkHooks(services)
+	}
+	if project != nil {
+		v.checkMounts(project)
+	}
+	v.problems.Sort()
+	return v.problems
+}
+
+type profileValidator struct {
+	profile  *devenv.Profile
+	metadata *ComposePlanMetadata
+	// doc the rendered compose file as YAML node tree, nil if it cannot be parsed
+	doc      *yaml.Node
+	problems devenv.Problems
+}
+
+func (v *profileValidator) report(pos devenv.Position, format string, args ...interface{}) {
+	if len(pos.Path) == 0 {
+		pos.Path = v.profile.DisplayPath
+	}
+	v.problems = append(v.problems, devenv.Problem{Position: pos, Message: fmt.Sprintf(format, args...)})
+}
+
+// checkVariables find variables in keys and values of the rendered compose file that are not defined by the profile.
+// Comments are ignored, the same as docker compose
+func (v *profileValidator) checkVariables() {
+	path := v.metadata.ComposePath
+	data, e := os.ReadFile(path)
+	if e != nil {
+		v.report(devenv.Position{Path: path}, `unable to read rendered docker compose file: %v`, e)
+		return
+	}
+	var doc yaml.Node
+	if e := yaml.Unmarshal(data, &doc); e != nil {
+		v.report(devenv.Position{Path: path}, `invalid docker compose file: %v`, e)
+		return
+	}
+	lookup := func(name string) (string, bool) {
+		value, ok := v.metadata.Vars[name]
+		return value, ok
+	}
+	var walk func(n *yaml.Node)
+	walk = func(n *yaml.Node) {
+		if n.Kind != yaml.ScalarNode {
+			for _, child := range n.Content {
+				walk(child)
+			}
+			return
+		}
+		reported := map[string]struct{}{}
+		for _, name := range compose.UndefinedVariables(n.Value, lookup) {
+			if _, ok := reported[name]; ok {
+				continue
+			}
+			reported[name] = struct{}{}
+			v.report(devenv.Position{Path: path, Line: n.Line, Column: n.Column}, `variable [%s] is not defined`, name)
+		}
+	}
+	walk(&doc)
+	v.doc = &doc
+}
+
+// composeServices returns names of services in the compose file. If the compose file cannot be loaded,
+// names are taken from the "services" mapping of the YAML node tree. Returns false if neither is available
+func (v *profileValidator) composeServices(project *compose.Project) (map[string]struct{}, bool) {
+	services := map[string]struct{}{}
+	if project != nil {
+		for name := range project.Services {
+			services[name] = struct{}{}
+		}
+		return services, true
+	}
+	if v.doc == nil || len(v.doc.Content) == 0 || v.doc.Content[0].Kind != yaml.MappingNode {
+		return nil, false
+	}
+	root := v.doc.Content[0]
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value != "services" {
+			continue
+		}
+		if root.Content[i+1].Kind != yaml.MappingNode {
+			return nil, false
+		}
+		for j := 0; j+1 < len(root.Content[i+1].Content); j += 2 {
+			services[root.Content[i+1].Content[j].Value] = struct{}{}
+		}
+	}
+	return services, true
+}
+
+func (v *profileValidator) checkServices(services map[string]struct{}) {
+	for _, name := range sortedKeys(v.profile.Services) {
+		if _, ok := services[name]; !ok {
+			v.report(v.profile.Sources.Service(name), `service [%s] is not defined in docker compose file`, name)
+		}
+	}
+}
+
+func (v *profileValidator) checkHooks(services map[string]struct{}) {
+	for _, phase := range []devenv.HookPhase{devenv.PhasePreStart, devenv.PhasePostStart, devenv.PhasePreStop, devenv.PhasePostStop} {
+		for _, hook := range v.profile.Hooks.Phase(phase) {
+			switch hook.Type {
+			case devenv.TypeScript:
+				if _, e := FindHookScript(hook, v.metadata.ResourceDir); e != nil {
+					v.report(v.profile.Sources.Hook(hook), `%s hook [%s]: script not found, expected "%s/%v" or "%s-%v" in resource directory [%s]`,
+						phase, hook.Name, phase, hook.Value, phase, hook.Value, filepath.Base(v.profile.ResourceDir))
+				}
+			case devenv.TypeContainer:
+				if _, ok := services[fmt.Sprint(hook.Value)]; !ok {
+					v.report(v.profile.Sources.Hook(hook), `%s hook [%s]: container [%v] is not a service in docker compose file`,
+						phase, hook.Name, hook.Value)
+				}
+			}
+		}
+	}
+}
+
+func (v *profileValidator) checkMounts(project *compose.Project) {
+	var sources []string
+	for _, svc := range project.Services {
+		for _, vol := range svc.Volumes {
+			if vol.Type == compose.VolumeTypeBind {
+				sources = append(sources, vol.Source)
+			}
+		}
+	}
+	for _, name := range sortedKeys(v.profile.Services) {
+		for _, mount := range v.profile.Services[name].Mounts {
+			path := filepath.Join(v.profile.LocalDataDir, mount)
+			var used bool
+			for _, src := range sources {
+				if isWithin(src, path) || isWithin(path, src) {
+					used = true
+					break
+				}
+			}
+			if !used {
+				v.report(v.profile.Sources.Mount(name, mount), `mount [%s] of service [%s] is not used by any bind volume in docker compose file: %s`,
+					mount, name, path)
+			}
+		}
+	}
+}
+
+// isWithin returns true if path is same as dir or inside dir
+func isWithin(path, dir string) bool {
+	rel, e := filepath.Rel(dir, path)
+	return e == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
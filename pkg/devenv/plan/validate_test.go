@@ -0,0 +1,105 @@
+package plan
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+)
+
+const testValidateProfile = `{
+	"version": 2,
+	"hooks": {"pre_start": ["init.sh", "missing.sh"], "post_start": [{"container": "seed"}, {"container": "unknown"}]},
+	"services": {
+		"app": {"image": "app:latest", "mounts": ["data", "logs"]},
+		"db": {"image": "postgres:16"}
+	}
+}`
+
+func TestValidateProfile(t *testing.T) {
+	tests := []struct {
+		name     string
+		compose  string
+		expected []string
+	}{
+		{
+			name: "valid compose file",
+			compose: `{
+	"services": {
+		"app": {
+			"image": "app:latest",
+			"environment": {"TOKEN": "${TOKEN}", "DEBUG": "${DEBUG:-false}"},
+			"volumes": ["${CONTAINER_DATA_PATH}/data:/data"]
+		},
+		"seed": {"image": "seed:latest"}
+	}
+}`,
+			expected: []string{
+				`mount [logs] of service [app] is not used by any bind volume in docker compose file: <data>/logs`,
+				`post-start hook [unknown]: container [unknown] is not a service in docker compose file`,
+				`pre-start hook [missing.sh]: script not found, expected "pre-start/missing.sh" or "pre-start-missing.sh" in resource directory [res-test]`,
+				`service [db] is not defined in docker compose file`,
+				`variable [TOKEN] is not defined`,
+			},
+		},
+		{
+			name: "invalid compose file",
+			compose: `{
+	"services": {
+		"app": {"environment": {"TOKEN": "${TOKEN}"}},
+		"seed": {"image": "seed:latest"}
+	}
+}`,
+			expected: []string{
+				`invalid compose file [<wd>/docker-compose.yml]: service [app] has neither "image" nor "build"`,
+				`post-start hook [unknown]: container [unknown] is not a service in docker compose file`,
+				`pre-start hook [missing.sh]: script not found, expected "pre-start/missing.sh" or "pre-start-missing.sh" in resource directory [res-test]`,
+				`service [db] is not defined in docker compose file`,
+				`variable [TOKEN] is not defined`,
+			},
+		},
+		{
+			name:    "invalid yaml",
+			compose: `{"services": [`,
+			expected: []string{
+				`invalid docker compose file: yaml: line 1: did not find expected node content`,
+				`unable to parse compose file [<wd>/docker-compose.yml]: `,
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := loadTestProfile(t, map[string]string{
+				"devenv-test.yml":         testValidateProfile,
+				"docker-compose-test.yml": test.compose,
+			})
+			// resources in file system other than embed.FS are copied from OS paths
+			p.ResourceDir = filepath.Join(t.TempDir(), "res-test")
+			p.ResourceFS = os.DirFS(p.ResourceDir)
+			if e := os.MkdirAll(filepath.Join(p.ResourceDir, "pre-start"), 0755); e != nil {
+				t.Fatalf("unable to create resource directory: %v", e)
+			}
+			if e := os.WriteFile(filepath.Join(p.ResourceDir, "pre-start", "init.sh"), []byte("echo init"), 0755); e != nil {
+				t.Fatalf("unable to write resource file: %v", e)
+			}
+			wd := t.TempDir()
+
+			problems := ValidateProfile(p, wd)
+			messages := make([]string, len(problems))
+			for i := range problems {
+				messages[i] = problems[i].Message
+			}
+			sort.Strings(messages)
+			expected := make([]string, len(test.expected))
+			for i := range test.expected {
+				expected[i] = strings.NewReplacer("<data>", p.LocalDataDir, "<wd>", wd).Replace(test.expected[i])
+			}
+			// messages of YAML and JSON parsers are matched by prefix
+			if !slices.EqualFunc(messages, expected, strings.HasPrefix) {
+				t.Errorf("expected problems:\n%v\nbut got:\n%v", expected, messages)
+			}
+		})
+	}
+}
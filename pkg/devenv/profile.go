@@ -1,11 +1,16 @@
 package devenv
 
 import (
+	"encoding/json"
 	"fmt"
+	"github.com/cisco-open/go-lanai/cmd/lanai-cli/cmdutils"
+	lanaiutils "github.com/cisco-open/go-lanai/pkg/utils"
 	"github.com/stonedu1011/devenvctl/pkg/utils"
 	"io/fs"
 	"path/filepath"
 	"regexp"
+	"strings"
+	"time"
 )
 
 type Profiles map[string]*ProfileMetadata
@@ -19,13 +24,53 @@ type ProfileMetadata struct {
 	ResourceDir  string `json:"-"`
 	ComposePath  string `json:"-"`
 	LocalDataDir string `json:"-"`
+	// ComposeFS and ResourceFS are the file systems of ComposePath and ResourceDir.
+	// They may differ from FS when they are inherited from parent profile
+	ComposeFS  fs.FS `json:"-"`
+	ResourceFS fs.FS `json:"-"`
 }
 
 type Profile struct {
 	ProfileMetadata
+	ProfileInheritance
 	DisplayName string
-	Services    map[string]Service
-	Hooks       Hooks
+	// Engine optional, how the profile should be run, e.g. "compose" or "docker". See plan.NewPlanner
+	Engine string
+	// Prune optional, what to prune after the profile is started or stopped. PruneProfile if not set
+	Prune PrunePolicy
+	// HookTimeout optional, default timeout of hooks that don't specify their own
+	HookTimeout time.Duration
+	// Variables optional, default values of user variables
+	Variables map[string]string
+	// UserVariables effective user variables, resolved by LoadProfile. See ResolveUserVariables
+	UserVariables []Variable
+	// Secrets optional, variables that are resolved right before use and masked when printed. See ResolveSecrets
+	Secrets  []Secret
+	Services map[string]Service
+	Hooks    Hooks
+	// Sources positions of services, mounts and hooks in definition files
+	Sources *SourceMap
+}
+
+const (
+	// PruneNone disables Docker pruning
+	PruneNone PrunePolicy = "none"
+	// PruneProfile prunes stopped containers, unused volumes and dangling images that belong to the profile. This is the default
+	PruneProfile PrunePolicy = "profile"
+	// PruneGlobal prunes stopped containers, unused volumes and dangling images of the entire machine,
+	// except volumes labeled with "devenv.persist"
+	PruneGlobal PrunePolicy = "global"
+)
+
+type PrunePolicy string
+
+func (p PrunePolicy) Validate() error {
+	switch p {
+	case "", PruneNone, PruneProfile, PruneGlobal:
+		return nil
+	default:
+		return fmt.Errorf(`unsupported prune policy [%s], should be one of [%s, %s, %s]`, p, PruneNone, PruneProfile, PruneGlobal)
+	}
 }
 
 func MergeProfiles(src, dest Profiles) Profiles {
@@ -85,11 +130,210 @@ func FindProfiles(fsys fs.FS, dirPath string, nameRegexps ...*regexp.Regexp) (Pr
 	return profiles, nil
 }
 
-func LoadProfile(meta *ProfileMetadata) (*Profile, error) {
-	pv1, err := LoadProfileV1(meta)
-	if err == nil {
-		return pv1.ToProfile(), nil
+const (
+	FormatV1 = `1`
+	FormatV2 = `2`
+)
+
+// profileVersion is used to probe the format version of a profile definition file.
+// Definition files without "version" are treated as v1
+type profileVersion struct {
+	Version json.Number `json:"version"`
+}
+
+type LoadOptions func(opt *LoadOption)
+type LoadOption struct {
+	// Profiles available profiles, used to resolve "extends"
+	Profiles Profiles
+	// Variables overrides of user variables with the highest precedence, e.g. from "--set key=value"
+	Variables map[string]string
+	// Strict if true, unknown keys in definition files are reported as Problems instead of warnings
+	Strict bool
+}
+
+// WithProfiles is a LoadOptions that provides available profiles for resolving "extends"
+func WithProfiles(profiles Profiles) LoadOptions {
+	return func(opt *LoadOption) {
+		opt.Profiles = profiles
+	}
+}
+
+// WithVariables is a LoadOptions that overrides user variables. See ResolveUserVariables
+func WithVariables(overrides map[string]string) LoadOptions {
+	return func(opt *LoadOption) {
+		opt.Variables = overrides
+	}
+}
+
+// WithStrict is a LoadOptions that strictly checks definition files. See LoadOption.Strict
+func WithStrict() LoadOptions {
+	return func(opt *LoadOption) {
+		opt.Strict = true
+	}
+}
+
+// LoadProfile load profile from definition file, resolve its parent profiles if it "extends" any.
+// Definition files are checked against ProfileSchema, all problems of a file are reported at once as Problems.
+// Unknown keys are logged as warnings, unless loaded WithStrict.
+func LoadProfile(meta *ProfileMetadata, opts ...LoadOptions) (*Profile, error) {
+	opt := LoadOption{}
+	for _, fn := range opts {
+		fn(&opt)
+	}
+	p, e := loadProfileWithParents(meta, &opt, nil)
+	if e != nil {
+		return nil, e
+	}
+	if e := p.resolveServices(); e != nil {
+		return nil, fmt.Errorf(`invalid profile "%s": %v`, meta.DisplayPath, e)
+	}
+	if e := p.resolveVariables(opt.Variables); e != nil {
+		return nil, fmt.Errorf(`invalid variables of profile "%s": %v`, meta.DisplayPath, e)
+	}
+	return p, nil
+}
+
+func loadProfileWithParents(meta *ProfileMetadata, opt *LoadOption, children []*ProfileMetadata) (*Profile, error) {
+	for i := range children {
+		if children[i].Name != meta.Name {
+			continue
+		}
+		chain := make([]string, 0, len(children)-i+1)
+		for _, c := range append(children[i:], meta) {
+			chain = append(chain, fmt.Sprintf(`[%s] "%s"`, c.Name, c.DisplayPath))
+		}
+		return nil, fmt.Errorf(`circular profile inheritance: %s`, strings.Join(chain, " extends "))
+	}
+
+	p, e := loadProfileDefinition(meta, opt.Strict)
+	if e != nil {
+		return nil, e
+	}
+	if len(p.Extends) == 0 {
+		return p, nil
+	}
+
+	parentMeta, ok := opt.Profiles[p.Extends]
+	if !ok {
+		return nil, fmt.Errorf(`profile [%s] "%s" extends unknown profile [%s]`, meta.Name, meta.DisplayPath, p.Extends)
+	}
+	parent, e := loadProfileWithParents(parentMeta, opt, append(children, meta))
+	if e != nil {
+		return nil, e
+	}
+	merged, e := MergeProfile(parent, p)
+	if e != nil {
+		return nil, fmt.Errorf(`profile [%s] "%s" cannot extend profile [%s] "%s": %v`,
+			meta.Name, meta.DisplayPath, parentMeta.Name, parentMeta.DisplayPath, e)
+	}
+	merged.Sources = mergeSourceMaps(parent.Sources, p.Sources)
+	return merged, nil
+}
+
+func loadProfileDefinition(meta *ProfileMetadata, strict bool) (*Profile, error) {
+	ver, e := probeProfileVersion(meta)
+	if e != nil {
+		return nil, e
+	}
+	sources, e := checkDefinition(meta, ver, strict)
+	if e != nil {
+		return nil, e
+	}
+	var p *Profile
+	switch ver {
+	case "", FormatV1:
+		pv1, e := LoadProfileV1(meta)
+		if e != nil {
+			return nil, e
+		}
+		if p, e = pv1.ToProfile(); e != nil {
+			return nil, e
+		}
+	case FormatV2:
+		pv2, e := LoadProfileV2(meta)
+		if e != nil {
+			return nil, e
+		}
+		if p, e = pv2.ToProfile(); e != nil {
+			return nil, e
+		}
+	default:
+		return nil, fmt.Errorf(`unsupported version [%s] of profile definition file "%s"`, ver, meta.DisplayPath)
+	}
+	p.Sources = sources
+	return p, nil
+}
+
+// resolveServices validate service dependencies and merge service hooks into profile hooks:
+// profile-level hooks run first in "pre-*" phases and last in "post-*" phases.
+// Service hooks of "*-start" phases are ordered by service dependencies, and reversed in "*-stop" phases.
+func (p *Profile) resolveServices() error {
+	for k, s := range p.Services {
+		s.owner = p
+		p.Services[k] = s
+	}
+	order, e := ResolveServiceOrder(p.Services)
+	if e != nil {
+		return e
+	}
+	if p.Hooks == nil {
+		p.Hooks = Hooks{}
+	}
+	for _, phase := range []HookPhase{PhasePreStart, PhasePostStart, PhasePreStop, PhasePostStop} {
+		hooks := p.Hooks.Phase(phase)
+		svcHooks := make([]Hook, 0, len(order))
+		for i := range order {
+			name := order[i]
+			if phase == PhasePreStop || phase == PhasePostStop {
+				name = order[len(order)-1-i]
+			}
+			svcHooks = append(svcHooks, p.Services[name].Hooks.Phase(phase)...)
+		}
+		if phase == PhasePreStart || phase == PhasePreStop {
+			p.Hooks[phase] = append(hooks, svcHooks...)
+		} else {
+			p.Hooks[phase] = append(svcHooks, hooks...)
+		}
+	}
+	return nil
+}
+
+// resolveVariables resolve user variables and make sure neither they nor secrets shadow builtin variables
+func (p *Profile) resolveVariables(overrides map[string]string) error {
+	vars, e := ResolveUserVariables(p, overrides)
+	if e != nil {
+		return e
+	}
+	reserved := lanaiutils.NewStringSet(VarProjectResource, VarLocalDataPath)
+	for _, v := range append(ResolveServiceVars(p), append(ResolveBuildArgs(p), ResolveGlobalVars(p)...)...) {
+		reserved.Add(v.Name)
+	}
+	for _, v := range vars {
+		if reserved.Has(v.Name) {
+			return fmt.Errorf(`variable [%s] from %s conflicts with builtin variable`, v.Name, v.Source)
+		}
+	}
+	for _, v := range vars {
+		reserved.Add(v.Name)
+	}
+	for _, s := range p.Secrets {
+		if reserved.Has(s.Name) {
+			return fmt.Errorf(`secret [%s] conflicts with another variable`, s.Name)
+		}
+	}
+	p.UserVariables = vars
+	return nil
+}
+
+func probeProfileVersion(meta *ProfileMetadata) (string, error) {
+	f, e := meta.FS.Open(meta.Path)
+	if e != nil {
+		return "", fmt.Errorf(`unable to open profile definition file "%s": %v`, meta.DisplayPath, e)
+	}
+	defer func() { _ = f.Close() }()
+	var ver profileVersion
+	if e := cmdutils.BindYaml(f, &ver); e != nil {
+		return "", fmt.Errorf(`unable to parse version of profile definition file "%s": %v`, meta.DisplayPath, e)
 	}
-	// more version format goes here
-	return nil, err
+	return ver.Version.String(), nil
 }
@@ -0,0 +1,200 @@
+package devenv
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"sync"
+)
+
+const (
+	SchemaDraft = `http://json-schema.org/draft-07/schema#`
+	// SchemaTitle title of ProfileSchema
+	SchemaTitle = `devenvctl profile definition`
+	// tagDesc struct tag of definition formats, used as descriptions in ProfileSchema
+	tagDesc = `desc`
+)
+
+// profileFormats struct of each supported format version of definition files. The first one is used when "version" is absent
+var profileFormats = []struct {
+	Version string
+	Type    reflect.Type
+}{
+	{Version: FormatV1, Type: reflect.TypeOf(ProfileV1{})},
+	{Version: FormatV2, Type: reflect.TypeOf(ProfileV2{})},
+}
+
+// schemaEnums allowed values of string types
+var schemaEnums = map[reflect.Type][]interface{}{
+	reflect.TypeOf(PrunePolicy("")):   {PruneNone, PruneProfile, PruneGlobal},
+	reflect.TypeOf(ReadinessType("")): {ReadinessHealthcheck, ReadinessRunning, ReadinessTCP, ReadinessHTTP, ReadinessLog},
+}
+
+// Schema is the subset of JSON Schema (draft-07) that describes profile definition files. See ProfileSchema
+type Schema struct {
+	Schema               string             `json:"$schema,omitempty"`
+	Ref                  string             `json:"$ref,omitempty"`
+	Title                string             `json:"title,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+	OneOf                []*Schema          `json:"oneOf,omitempty"`
+	If                   *Schema            `json:"if,omitempty"`
+	Then                 *Schema            `json:"then,omitempty"`
+	Else                 *Schema            `json:"else,omitempty"`
+	Definitions          map[string]*Schema `json:"definitions,omitempty"`
+	// deny if true, the schema is encoded as `false`, which allows nothing. e.g. "additionalProperties": false
+	deny bool
+}
+
+func (s Schema) MarshalJSON() ([]byte, error) {
+	if s.deny {
+		return []byte(`false`), nil
+	}
+	type schema Schema
+	return json.Marshal(schema(s))
+}
+
+var profileSchemaOnce = sync.OnceValue(newProfileSchema)
+
+// ProfileSchema returns JSON Schema of definition files, generated from structs of all supported format versions, e.g. ProfileV1.
+// Format is chosen by "version". The same schema is used to check definition files when profiles are loaded.
+// Note: the returned schema is shared and should not be modified.
+func ProfileSchema() *Schema {
+	return profileSchemaOnce()
+}
+
+func newProfileSchema() *Schema {
+	g := schemaGenerator{definitions: map[string]*Schema{}}
+	// nested "if-then-else" for all versions, falls back to the first version
+	format := g.formatSchema(profileFormats[0].Version, profileFormats[0].Type)
+	for _, f := range profileFormats[1:] {
+		format = &Schema{
+			If: &Schema{
+				Properties: map[string]*Schema{"version": versionSchema(f.Version)},
+				Required:   []string{"version"},
+			},
+			Then: g.formatSchema(f.Version, f.Type),
+			Else: format,
+		}
+	}
+	return &Schema{
+		Schema:      SchemaDraft,
+		Title:       SchemaTitle,
+		If:          format.If,
+		Then:        format.Then,
+		Else:        format.Else,
+		Ref:         format.Ref,
+		Definitions: g.definitions,
+	}
+}
+
+// versionFormat returns schema of given format version
+func (s *Schema) versionFormat(version string) *Schema {
+	for _, f := range profileFormats {
+		if f.Version == version {
+			return s.resolve(&Schema{Ref: definitionRef(f.Type)})
+		}
+	}
+	return nil
+}
+
+// resolve follow "$ref" to definitions of the root schema
+func (s *Schema) resolve(schema *Schema) *Schema {
+	for schema != nil && len(schema.Ref) != 0 {
+		schema = s.Definitions[schema.Ref[len(definitionRefPrefix):]]
+	}
+	return schema
+}
+
+const definitionRefPrefix = `#/definitions/`
+
+func definitionRef(t reflect.Type) string {
+	return definitionRefPrefix + t.Name()
+}
+
+func versionSchema(version string) *Schema {
+	var values []interface{}
+	if n, e := strconv.Atoi(version); e == nil {
+		values = append(values, n)
+	}
+	return &Schema{
+		Description: "format version of the definition file",
+		Enum:        append(values, version),
+	}
+}
+
+type schemaGenerator struct {
+	definitions map[string]*Schema
+}
+
+func (g *schemaGenerator) formatSchema(version string, t reflect.Type) *Schema {
+	ref := g.schemaOf(t)
+	g.definitions[t.Name()].Properties["version"] = versionSchema(version)
+	return ref
+}
+
+// schemaOf returns schema of given type, according to how it's bound from JSON. Structs are added to definitions and referenced.
+// Types implementing json.Unmarshaler also accept a string, e.g. HookV2 and StringOrList
+func (g *schemaGenerator) schemaOf(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if values, ok := schemaEnums[t]; ok {
+		return &Schema{Type: "string", Enum: values}
+	}
+	var s *Schema
+	switch t.Kind() {
+	case reflect.Struct:
+		if _, ok := g.definitions[t.Name()]; !ok {
+			def := &Schema{}
+			g.definitions[t.Name()] = def
+			*def = *g.structSchema(t)
+		}
+		s = &Schema{Ref: definitionRef(t)}
+	case reflect.Map:
+		s = &Schema{Type: "object", AdditionalProperties: g.schemaOf(t.Elem())}
+	case reflect.Slice, reflect.Array:
+		s = &Schema{Type: "array", Items: g.schemaOf(t.Elem())}
+	case reflect.Bool:
+		s = &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		s = &Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		s = &Schema{Type: "number"}
+	case reflect.String:
+		s = &Schema{Type: "string"}
+	default:
+		s = &Schema{}
+	}
+	if reflect.PointerTo(t).Implements(jsonUnmarshalerType) && s.Type != "string" {
+		s = &Schema{OneOf: []*Schema{{Type: "string"}, s}}
+	}
+	return s
+}
+
+func (g *schemaGenerator) structSchema(t reflect.Type) *Schema {
+	s := &Schema{
+		Type:                 "object",
+		Properties:           map[string]*Schema{},
+		AdditionalProperties: &Schema{deny: true},
+	}
+	for name, f := range jsonFields(t) {
+		prop := g.schemaOf(f.Type)
+		if desc := f.Tag.Get(tagDesc); len(desc) != 0 {
+			// "description" next to "$ref" is ignored by draft-07, so the reference is wrapped
+			if len(prop.Ref) != 0 {
+				prop = &Schema{AllOf: []*Schema{prop}}
+			}
+			prop.Description = desc
+		}
+		s.Properties[name] = prop
+	}
+	return s
+}
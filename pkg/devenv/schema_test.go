@@ -0,0 +1,69 @@
+package devenv
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestProfileSchemaFormats(t *testing.T) {
+	schema := ProfileSchema()
+	tests := []struct {
+		version  string
+		expected []string
+	}{
+		{version: FormatV1, expected: []string{"services", "pre_start", "post_start", "pre_stop", "post_stop"}},
+		{version: FormatV2, expected: []string{"version", "services", "hooks"}},
+		{version: "3"},
+	}
+	for _, test := range tests {
+		t.Run(test.version, func(t *testing.T) {
+			format := schema.versionFormat(test.version)
+			if test.expected == nil {
+				if format != nil {
+					t.Fatalf("expected no format, but got %v", format)
+				}
+				return
+			}
+			switch {
+			case format == nil:
+				t.Fatalf("expected format of version %s", test.version)
+			case format.Type != "object":
+				t.Errorf(`expected type "object", but got %q`, format.Type)
+			case format.AdditionalProperties == nil || !format.AdditionalProperties.deny:
+				t.Errorf("expected additional properties to be denied")
+			}
+			for _, name := range test.expected {
+				if _, ok := format.Properties[name]; !ok {
+					t.Errorf("expected property %q", name)
+				}
+			}
+		})
+	}
+}
+
+func TestProfileSchemaJSON(t *testing.T) {
+	data, e := json.Marshal(ProfileSchema())
+	if e != nil {
+		t.Fatalf("unexpected error: %v", e)
+	}
+	for _, expected := range []string{
+		`"$schema":"` + SchemaDraft + `"`,
+		`"additionalProperties":false`,
+		`"enum":["none","profile","global"]`,
+	} {
+		if !strings.Contains(string(data), expected) {
+			t.Errorf("expected %s in schema", expected)
+		}
+	}
+	var decoded map[string]interface{}
+	if e := json.Unmarshal(data, &decoded); e != nil {
+		t.Fatalf("schema is not valid JSON: %v", e)
+	}
+	defs, _ := decoded["definitions"].(map[string]interface{})
+	for _, name := range []string{"ProfileV1", "ProfileV2", "HookV2"} {
+		if _, ok := defs[name]; !ok {
+			t.Errorf("expected definition %q", name)
+		}
+	}
+}
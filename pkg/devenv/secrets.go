@@ -0,0 +1,200 @@
+package devenv
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// SecretMask is printed in place of secret values
+	SecretMask = `******`
+	// VarSourceFile secret values read from a file
+	VarSourceFile VarSource = "file"
+	// VarSourceCommand secret values printed by an external command
+	VarSourceCommand VarSource = "command"
+)
+
+// Secret is a variable whose value is resolved right before use, and never printed by devenvctl.
+// Exactly one of File, Env or Command should be set
+type Secret struct {
+	Name string
+	// File path of a file containing the value. "~" is expanded to home directory, relative path is resolved against current directory
+	File string
+	// Env name of an environment variable containing the value
+	Env string
+	// Command shell command printing the value to stdout
+	Command string
+}
+
+func (s Secret) Source() VarSource {
+	switch {
+	case len(s.File) != 0:
+		return VarSourceFile
+	case len(s.Env) != 0:
+		return VarSourceEnv
+	default:
+		return VarSourceCommand
+	}
+}
+
+// Resolve returns the secret's value, with leading and trailing spaces trimmed
+func (s Secret) Resolve() (string, error) {
+	var value string
+	switch s.Source() {
+	case VarSourceFile:
+		path := s.File
+		if strings.HasPrefix(path, "~/") {
+			home, e := os.UserHomeDir()
+			if e != nil {
+				return "", fmt.Errorf(`secret [%s]: %v`, s.Name, e)
+			}
+			path = filepath.Join(home, path[2:])
+		}
+		data, e := os.ReadFile(path)
+		if e != nil {
+			return "", fmt.Errorf(`secret [%s]: unable to read file: %v`, s.Name, e)
+		}
+		value = string(data)
+	case VarSourceEnv:
+		v, ok := os.LookupEnv(s.Env)
+		if !ok {
+			return "", fmt.Errorf(`secret [%s]: environment variable [%s] is not set`, s.Name, s.Env)
+		}
+		value = v
+	default:
+		var stderr bytes.Buffer
+		cmd := exec.Command("sh", "-c", s.Command)
+		cmd.Stderr = &stderr
+		out, e := cmd.Output()
+		if e != nil {
+			return "", fmt.Errorf(`secret [%s]: command failed: %v %s`, s.Name, e, strings.TrimSpace(stderr.String()))
+		}
+		value = string(out)
+	}
+	return strings.TrimSpace(value), nil
+}
+
+// SecretDefinition how a secret is defined in profile definition files
+type SecretDefinition struct {
+	File    string `json:"file" desc:"path of a file containing the value"`
+	Env     string `json:"env" desc:"name of an environment variable containing the value"`
+	Command string `json:"command" desc:"shell command printing the value"`
+}
+
+// toSecrets convert secret definitions to Secrets sorted by name
+func toSecrets(defs map[string]SecretDefinition) ([]Secret, error) {
+	secrets := make([]Secret, 0, len(defs))
+	for name, def := range defs {
+		if !regexVarName.MatchString(name) {
+			return nil, fmt.Errorf(`invalid secret name [%s]`, name)
+		}
+		var count int
+		for _, v := range []string{def.File, def.Env, def.Command} {
+			if len(v) != 0 {
+				count++
+			}
+		}
+		if count != 1 {
+			return nil, fmt.Errorf(`secret [%s] should have exactly one of "file", "env" or "command"`, name)
+		}
+		secrets = append(secrets, Secret{Name: name, File: def.File, Env: def.Env, Command: def.Command})
+	}
+	sort.SliceStable(secrets, func(i, j int) bool {
+		return secrets[i].Name < secrets[j].Name
+	})
+	return secrets, nil
+}
+
+// mergeSecrets combine parent's and child's secrets, child's definitions take precedence
+func mergeSecrets(parent, child []Secret) []Secret {
+	byName := map[string]Secret{}
+	for _, s := range append(append([]Secret{}, parent...), child...) {
+		byName[s.Name] = s
+	}
+	ret := make([]Secret, 0, len(byName))
+	for _, s := range byName {
+		ret = append(ret, s)
+	}
+	sort.SliceStable(ret, func(i, j int) bool {
+		return ret[i].Name < ret[j].Name
+	})
+	return ret
+}
+
+// ResolveSecrets resolve all secrets of given profile. Resolved values are registered for masking, see MaskSecrets
+func ResolveSecrets(p *Profile) ([]Variable, error) {
+	vars := make([]Variable, 0, len(p.Secrets))
+	for _, s := range p.Secrets {
+		value, e := s.Resolve()
+		if e != nil {
+			return nil, e
+		}
+		RegisterSecretValues(value)
+		vars = append(vars, Variable{Name: s.Name, Value: value, Source: s.Source(), Secret: true})
+	}
+	return vars, nil
+}
+
+// SecretPlaceholders returns secrets of given profile as variables without resolving their values.
+// SecretMask is used as their values. For display and rendering purpose only.
+func SecretPlaceholders(p *Profile) []Variable {
+	vars := make([]Variable, 0, len(p.Secrets))
+	for _, s := range p.Secrets {
+		vars = append(vars, Variable{Name: s.Name, Value: SecretMask, Source: s.Source(), Secret: true})
+	}
+	return vars
+}
+
+var secretValues = struct {
+	sync.RWMutex
+	values []string
+}{}
+
+// RegisterSecretValues register values that should be masked by MaskSecrets
+func RegisterSecretValues(values ...string) {
+	secretValues.Lock()
+	defer secretValues.Unlock()
+	for _, v := range values {
+		if len(v) != 0 {
+			secretValues.values = append(secretValues.values, v)
+		}
+	}
+	// longer values first, in case one secret contains another
+	sort.SliceStable(secretValues.values, func(i, j int) bool {
+		return len(secretValues.values[i]) > len(secretValues.values[j])
+	})
+}
+
+// MaskSecrets replace all registered secret values in given text with SecretMask
+func MaskSecrets(text string) string {
+	secretValues.RLock()
+	defer secretValues.RUnlock()
+	for _, v := range secretValues.values {
+		text = strings.ReplaceAll(text, v, SecretMask)
+	}
+	return text
+}
+
+// NewMaskingWriter returns a writer that masks registered secret values before writing to given writer.
+// Note: a secret value split across multiple writes is not masked.
+func NewMaskingWriter(w io.Writer) io.Writer {
+	return maskingWriter{delegate: w}
+}
+
+type maskingWriter struct {
+	delegate io.Writer
+}
+
+func (w maskingWriter) Write(p []byte) (int, error) {
+	if _, e := io.WriteString(w.delegate, MaskSecrets(string(p))); e != nil {
+		return 0, e
+	}
+	return len(p), nil
+}
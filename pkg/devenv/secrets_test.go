@@ -0,0 +1,147 @@
+package devenv
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// withSecretValues register given secret values for the duration of the test
+func withSecretValues(t *testing.T, values ...string) {
+	secretValues.Lock()
+	saved := secretValues.values
+	secretValues.values = nil
+	secretValues.Unlock()
+	t.Cleanup(func() {
+		secretValues.Lock()
+		defer secretValues.Unlock()
+		secretValues.values = saved
+	})
+	RegisterSecretValues(values...)
+}
+
+func TestToSecrets(t *testing.T) {
+	tests := []struct {
+		name     string
+		defs     map[string]SecretDefinition
+		expected []Secret
+		err      string
+	}{
+		{name: "none", expected: []Secret{}},
+		{name: "sorted by name",
+			defs: map[string]SecretDefinition{
+				"TOKEN":    {Env: "GITHUB_TOKEN"},
+				"API_KEY":  {File: "~/.api-key"},
+				"PASSWORD": {Command: "pass show db"},
+			},
+			expected: []Secret{
+				{Name: "API_KEY", File: "~/.api-key"},
+				{Name: "PASSWORD", Command: "pass show db"},
+				{Name: "TOKEN", Env: "GITHUB_TOKEN"},
+			}},
+		{name: "no source", defs: map[string]SecretDefinition{"TOKEN": {}},
+			err: `secret [TOKEN] should have exactly one of "file", "env" or "command"`},
+		{name: "multiple sources", defs: map[string]SecretDefinition{"TOKEN": {Env: "GITHUB_TOKEN", Command: "gh auth token"}},
+			err: `secret [TOKEN] should have exactly one of "file", "env" or "command"`},
+		{name: "invalid name", defs: map[string]SecretDefinition{"api-key": {Env: "API_KEY"}},
+			err: `invalid secret name [api-key]`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			secrets, e := toSecrets(test.defs)
+			if len(test.err) != 0 {
+				if e == nil || e.Error() != test.err {
+					t.Fatalf("expected error %q, but got %v", test.err, e)
+				}
+				return
+			}
+			if e != nil {
+				t.Fatalf("unexpected error: %v", e)
+			}
+			if len(secrets) != len(test.expected) {
+				t.Fatalf("expected %v, but got %v", test.expected, secrets)
+			}
+			for i := range secrets {
+				if secrets[i] != test.expected[i] {
+					t.Errorf("expected %+v, but got %+v", test.expected[i], secrets[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSecretResolve(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secret")
+	if e := os.WriteFile(path, []byte("  from-file\n"), 0600); e != nil {
+		t.Fatalf("unable to write secret file: %v", e)
+	}
+	t.Setenv("DEVENV_TEST_SECRET", "from-env")
+	tests := []struct {
+		name     string
+		secret   Secret
+		expected string
+		err      string
+	}{
+		{name: "file", secret: Secret{Name: "S", File: path}, expected: "from-file"},
+		{name: "env", secret: Secret{Name: "S", Env: "DEVENV_TEST_SECRET"}, expected: "from-env"},
+		{name: "command", secret: Secret{Name: "S", Command: "echo from-command"}, expected: "from-command"},
+		{name: "env not set", secret: Secret{Name: "S", Env: "DEVENV_TEST_NOT_SET"},
+			err: `secret [S]: environment variable [DEVENV_TEST_NOT_SET] is not set`},
+		{name: "command failed", secret: Secret{Name: "S", Command: "echo oops >&2; exit 1"},
+			err: `secret [S]: command failed: exit status 1 oops`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			value, e := test.secret.Resolve()
+			if len(test.err) != 0 {
+				if e == nil || e.Error() != test.err {
+					t.Fatalf("expected error %q, but got %v", test.err, e)
+				}
+				return
+			}
+			if e != nil {
+				t.Fatalf("unexpected error: %v", e)
+			}
+			if value != test.expected {
+				t.Errorf("expected %q, but got %q", test.expected, value)
+			}
+		})
+	}
+}
+
+func TestMaskSecrets(t *testing.T) {
+	withSecretValues(t, "s3cr3t", "", "s3cr3t-and-more")
+	tests := []struct {
+		name     string
+		text     string
+		expected string
+	}{
+		{name: "no secret", text: "echo hello", expected: "echo hello"},
+		{name: "every occurrence", text: "s3cr3t and s3cr3t", expected: SecretMask + " and " + SecretMask},
+		{name: "longest first", text: "TOKEN=s3cr3t-and-more", expected: "TOKEN=" + SecretMask},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if masked := MaskSecrets(test.text); masked != test.expected {
+				t.Errorf("expected %q, but got %q", test.expected, masked)
+			}
+		})
+	}
+}
+
+func TestMaskingWriter(t *testing.T) {
+	withSecretValues(t, "pa", "password")
+	var sb strings.Builder
+	w := NewMaskingWriter(&sb)
+	for _, chunk := range []string{"login with password\n", "pa", "ssword\n"} {
+		n, e := w.Write([]byte(chunk))
+		if e != nil || n != len(chunk) {
+			t.Fatalf("expected %d bytes written, but got %d and error %v", len(chunk), n, e)
+		}
+	}
+	expected := "login with " + SecretMask + "\n" + SecretMask + "ssword\n"
+	if sb.String() != expected {
+		t.Errorf("expected %q, but got %q", expected, sb.String())
+	}
+}
@@ -1,7 +1,12 @@
 package devenv
 
 import (
+	"fmt"
 	"github.com/stonedu1011/devenvctl/pkg/utils"
+	"slices"
+	"sort"
+	"strings"
+	"time"
 )
 
 type Service struct {
@@ -11,9 +16,116 @@ type Service struct {
 	Image          string
 	Mounts         []string
 	BuildArgs      map[string]string
+	DependsOn      []string
+	Environment    map[string]string
+	Readiness      *Readiness
+	Hooks          Hooks
 	owner          *Profile
 }
 
 func (s Service) ContainerName() string {
 	return utils.SnakeCase(s.owner.Name) + "-" + utils.SnakeCase(s.Name)
 }
+
+const (
+	// ReadinessHealthcheck service is ready when its container's healthcheck reports "healthy",
+	// or when it's running if the container has no healthcheck
+	ReadinessHealthcheck ReadinessType = "healthcheck"
+	// ReadinessRunning service is ready as soon as its container is running
+	ReadinessRunning ReadinessType = "running"
+	// ReadinessTCP service is ready when Readiness.Target (host:port or port) accepts TCP connections
+	ReadinessTCP ReadinessType = "tcp"
+	// ReadinessHTTP service is ready when Readiness.Target (URL) responds with 2xx or 3xx status
+	ReadinessHTTP ReadinessType = "http"
+	// ReadinessLog service is ready when its container's log contains a line matching Readiness.Target (regex)
+	ReadinessLog ReadinessType = "log"
+)
+
+type ReadinessType string
+
+type Readiness struct {
+	Type     ReadinessType
+	Target   string
+	Interval time.Duration
+	Timeout  time.Duration
+}
+
+// ResolveServiceOrder returns service names sorted by their dependencies (DependsOn),
+// dependencies go first. Services without dependencies between each other are sorted by name.
+// Error is returned if any service depends on unknown service or if circular dependency is found.
+func ResolveServiceOrder(services map[string]Service) ([]string, error) {
+	names := make([]string, 0, len(services))
+	for k := range services {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	const (
+		visiting = iota + 1
+		visited
+	)
+	states := map[string]int{}
+	ordered := make([]string, 0, len(services))
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch states[name] {
+		case visited:
+			return nil
+		case visiting:
+			return fmt.Errorf(`circular service dependency: %s`, strings.Join(append(slices.Clone(path), name), " -> "))
+		}
+		states[name] = visiting
+		deps := append([]string{}, services[name].DependsOn...)
+		sort.Strings(deps)
+		for _, dep := range deps {
+			if _, ok := services[dep]; !ok {
+				return fmt.Errorf(`service [%s] depends on unknown service [%s]`, name, dep)
+			}
+			if e := visit(dep, append(slices.Clone(path), name)); e != nil {
+				return e
+			}
+		}
+		states[name] = visited
+		ordered = append(ordered, name)
+		return nil
+	}
+	for _, name := range names {
+		if e := visit(name, nil); e != nil {
+			return nil, e
+		}
+	}
+	return ordered, nil
+}
+
+// ResolveServiceDependencies returns given services and all services they depend on, directly or indirectly.
+// The result is sorted in same order as ResolveServiceOrder.
+func ResolveServiceDependencies(services map[string]Service, names ...string) ([]string, error) {
+	order, e := ResolveServiceOrder(services)
+	if e != nil {
+		return nil, e
+	}
+	required := map[string]struct{}{}
+	var visit func(name string)
+	visit = func(name string) {
+		if _, ok := required[name]; ok {
+			return
+		}
+		required[name] = struct{}{}
+		for _, dep := range services[name].DependsOn {
+			visit(dep)
+		}
+	}
+	for _, name := range names {
+		if _, ok := services[name]; !ok {
+			return nil, fmt.Errorf(`unknown service [%s]`, name)
+		}
+		visit(name)
+	}
+	ret := make([]string, 0, len(required))
+	for _, name := range order {
+		if _, ok := required[name]; ok {
+			ret = append(ret, name)
+		}
+	}
+	return ret, nil
+}
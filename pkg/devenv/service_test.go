@@ -0,0 +1,112 @@
+package devenv
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestResolveServiceOrder(t *testing.T) {
+	tests := []struct {
+		name     string
+		deps     map[string][]string
+		expected []string
+		err      string
+	}{
+		{
+			name:     "no dependencies",
+			deps:     map[string][]string{"c": nil, "a": nil, "b": nil},
+			expected: []string{"a", "b", "c"},
+		},
+		{
+			name:     "chain",
+			deps:     map[string][]string{"a": {"b"}, "b": {"c"}, "c": nil},
+			expected: []string{"c", "b", "a"},
+		},
+		{
+			name:     "diamond",
+			deps:     map[string][]string{"app": {"db", "cache"}, "db": {"net"}, "cache": {"net"}, "net": nil},
+			expected: []string{"net", "cache", "db", "app"},
+		},
+		{
+			name: "unknown dependency",
+			deps: map[string][]string{"a": {"x"}},
+			err:  `service [a] depends on unknown service [x]`,
+		},
+		{
+			name: "self dependency",
+			deps: map[string][]string{"a": {"a"}},
+			err:  `circular service dependency: a -> a`,
+		},
+		{
+			// sibling branches must not share the path of their parent
+			name: "cycle after sibling branches",
+			deps: map[string][]string{
+				"a":  {"b", "c"},
+				"b":  {"b1", "b2", "b3"},
+				"b1": nil, "b2": nil, "b3": nil,
+				"c": {"d"},
+				"d": {"e"},
+				"e": {"c"},
+			},
+			err: `circular service dependency: a -> c -> d -> e -> c`,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			services := map[string]Service{}
+			for name, deps := range test.deps {
+				services[name] = Service{Name: name, DependsOn: deps}
+			}
+			order, e := ResolveServiceOrder(services)
+			if len(test.err) != 0 {
+				if e == nil || e.Error() != test.err {
+					t.Fatalf("expected error %q, but got %v", test.err, e)
+				}
+				return
+			}
+			if e != nil {
+				t.Fatalf("unexpected error: %v", e)
+			}
+			if !slices.Equal(order, test.expected) {
+				t.Errorf("expected %v, but got %v", test.expected, order)
+			}
+		})
+	}
+}
+
+func TestResolveServiceDependencies(t *testing.T) {
+	services := map[string]Service{
+		"app":   {Name: "app", DependsOn: []string{"db"}},
+		"db":    {Name: "db", DependsOn: []string{"net"}},
+		"net":   {Name: "net"},
+		"other": {Name: "other"},
+	}
+	tests := []struct {
+		name     string
+		names    []string
+		expected []string
+		err      string
+	}{
+		{name: "closure", names: []string{"app"}, expected: []string{"net", "db", "app"}},
+		{name: "leaf", names: []string{"net"}, expected: []string{"net"}},
+		{name: "multiple", names: []string{"other", "db"}, expected: []string{"net", "db", "other"}},
+		{name: "unknown", names: []string{"x"}, err: `unknown service [x]`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ret, e := ResolveServiceDependencies(services, test.names...)
+			if len(test.err) != 0 {
+				if e == nil || e.Error() != test.err {
+					t.Fatalf("expected error %q, but got %v", test.err, e)
+				}
+				return
+			}
+			if e != nil {
+				t.Fatalf("unexpected error: %v", e)
+			}
+			if !slices.Equal(ret, test.expected) {
+				t.Errorf("expected %v, but got %v", test.expected, ret)
+			}
+		})
+	}
+}
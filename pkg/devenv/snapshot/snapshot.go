@@ -0,0 +1,516 @@
+package snapshot
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/image"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/volume"
+	dockerclient "github.com/docker/docker/client"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// HelperImage is the image of helper containers used to access content of docker volumes
+var HelperImage = `busybox:latest`
+
+const helperMountPoint = `/volume`
+
+// Create archive given data directory and docker volumes as a snapshot described by the manifest.
+// Data directory is skipped if it doesn't exist. Any existing snapshot with same name is replaced.
+func (s *Store) Create(ctx context.Context, client *dockerclient.Client, m *Manifest) (err error) {
+	dst := s.Path(m.Profile, m.Name)
+	if e := os.MkdirAll(filepath.Dir(dst), 0755); e != nil {
+		return fmt.Errorf(`unable to create snapshot directory: %v`, e)
+	}
+	// write to temporary file and rename when finished
+	f, e := os.CreateTemp(filepath.Dir(dst), filepath.Base(dst)+".*")
+	if e != nil {
+		return fmt.Errorf(`unable to create snapshot [%s]: %v`, dst, e)
+	}
+	defer func() {
+		_ = f.Close()
+		if err != nil {
+			_ = os.Remove(f.Name())
+		}
+	}()
+
+	w := newArchiveWriter(f)
+	if e := w.writeManifest(m); e != nil {
+		return fmt.Errorf(`unable to write snapshot manifest: %v`, e)
+	}
+	if e := archiveDir(w.tar, m.DataDir, dataEntryPrefix); e != nil {
+		return fmt.Errorf(`unable to archive data directory [%s]: %v`, m.DataDir, e)
+	}
+	for _, v := range m.Volumes {
+		if e := archiveVolume(ctx, client, w.tar, v.Name); e != nil {
+			return fmt.Errorf(`unable to archive volume [%s]: %v`, v.Name, e)
+		}
+	}
+	if e := w.Close(); e != nil {
+		return fmt.Errorf(`unable to create snapshot [%s]: %v`, dst, e)
+	}
+	if e := f.Close(); e != nil {
+		return fmt.Errorf(`unable to create snapshot [%s]: %v`, dst, e)
+	}
+	return os.Rename(f.Name(), dst)
+}
+
+// Restore replace content of given data directory and volumes recorded in snapshot's manifest.
+// Volumes are re-created with recorded driver and labels.
+// The whole snapshot is verified before anything is changed. Data directory is extracted into a temporary directory,
+// which replaces the data directory only after all volumes are restored.
+func (s *Store) Restore(ctx context.Context, client *dockerclient.Client, profile, name, dataDir string) error {
+	src := s.Path(profile, name)
+	if e := verifyArchive(src); e != nil {
+		return fmt.Errorf(`invalid snapshot [%s]: %v`, src, e)
+	}
+	f, e := os.Open(src)
+	if e != nil {
+		return fmt.Errorf(`unable to open snapshot [%s] of profile [%s]: %v`, name, profile, e)
+	}
+	defer func() { _ = f.Close() }()
+	r, e := newArchiveReader(f)
+	if e != nil {
+		return fmt.Errorf(`invalid snapshot [%s]: %v`, src, e)
+	}
+	defer func() { _ = r.Close() }()
+	m, e := r.readManifest()
+	if e != nil {
+		return fmt.Errorf(`invalid snapshot [%s]: %v`, src, e)
+	}
+
+	// extract data directory into a temporary directory next to it
+	tmpDir, e := newRestoreDir(dataDir)
+	if e != nil {
+		return e
+	}
+	defer func() { _ = os.RemoveAll(tmpDir) }()
+
+	// volumes are reset after data directory is extracted, i.e. before the first volume entry
+	var volumesReset bool
+	resetVolumes := func() error {
+		if volumesReset {
+			return nil
+		}
+		volumesReset = true
+		for _, v := range m.Volumes {
+			if e := resetVolume(ctx, client, v); e != nil {
+				return e
+			}
+		}
+		return nil
+	}
+	var restorer *volumeRestorer
+	defer func() {
+		if restorer != nil {
+			_ = restorer.Close(ctx)
+		}
+	}()
+	for {
+		hdr, e := r.tar.Next()
+		switch {
+		case errors.Is(e, io.EOF):
+			if e := resetVolumes(); e != nil {
+				return e
+			}
+			if restorer != nil {
+				e = restorer.Close(ctx)
+				restorer = nil
+				if e != nil {
+					return e
+				}
+			}
+			return replaceDir(dataDir, tmpDir)
+		case e != nil:
+			return fmt.Errorf(`invalid snapshot [%s]: %v`, src, e)
+		}
+
+		switch {
+		case strings.HasPrefix(hdr.Name, dataEntryPrefix):
+			if e := extractEntry(r.tar, hdr, tmpDir, strings.TrimPrefix(hdr.Name, dataEntryPrefix)); e != nil {
+				return e
+			}
+		case strings.HasPrefix(hdr.Name, volumeEntryPrefix):
+			if e := resetVolumes(); e != nil {
+				return e
+			}
+			vol, rel, _ := strings.Cut(strings.TrimPrefix(hdr.Name, volumeEntryPrefix), "/")
+			if restorer == nil || restorer.volume != vol {
+				if restorer != nil {
+					if e := restorer.Close(ctx); e != nil {
+						return e
+					}
+				}
+				if restorer, e = newVolumeRestorer(ctx, client, vol); e != nil {
+					return e
+				}
+			}
+			if e := restorer.write(hdr, rel, r.tar); e != nil {
+				return e
+			}
+		}
+	}
+}
+
+// verifyArchive read through the whole snapshot archive, so that truncated or corrupted archives and unsafe entries
+// are found before anything is restored
+func verifyArchive(path string) error {
+	f, e := os.Open(path)
+	if e != nil {
+		return e
+	}
+	defer func() { _ = f.Close() }()
+	r, e := newArchiveReader(f)
+	if e != nil {
+		return e
+	}
+	defer func() { _ = r.Close() }()
+	m, e := r.readManifest()
+	if e != nil {
+		return e
+	}
+	volumes := map[string]struct{}{}
+	for _, v := range m.Volumes {
+		volumes[v.Name] = struct{}{}
+	}
+	for {
+		hdr, e := r.tar.Next()
+		switch {
+		case errors.Is(e, io.EOF):
+			// read till the end of gzip stream, which verifies its checksum
+			_, e = io.Copy(io.Discard, r.gz)
+			return e
+		case e != nil:
+			return e
+		}
+		var rel string
+		switch {
+		case strings.HasPrefix(hdr.Name, dataEntryPrefix):
+			rel = strings.TrimPrefix(hdr.Name, dataEntryPrefix)
+		case strings.HasPrefix(hdr.Name, volumeEntryPrefix):
+			var vol string
+			vol, rel, _ = strings.Cut(strings.TrimPrefix(hdr.Name, volumeEntryPrefix), "/")
+			if _, ok := volumes[vol]; !ok {
+				return fmt.Errorf(`volume [%s] is not in manifest`, vol)
+			}
+		}
+		if len(rel) != 0 {
+			if _, e := safeRelPath(rel); e != nil {
+				return e
+			}
+			if hdr.Typeflag == tar.TypeSymlink {
+				if e := checkLinkTarget(rel, hdr.Linkname); e != nil {
+					return e
+				}
+			}
+		}
+		if _, e := io.Copy(io.Discard, r.tar); e != nil {
+			return e
+		}
+	}
+}
+
+/**********************
+	Data Directory
+ **********************/
+
+func archiveDir(tw *tar.Writer, dir, prefix string) error {
+	if _, e := os.Stat(dir); errors.Is(e, fs.ErrNotExist) {
+		return nil
+	}
+	return filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, e := filepath.Rel(dir, p)
+		if e != nil || rel == "." {
+			return e
+		}
+		info, e := d.Info()
+		if e != nil {
+			return e
+		}
+		var link string
+		if info.Mode()&fs.ModeSymlink != 0 {
+			if link, e = os.Readlink(p); e != nil {
+				return e
+			}
+		}
+		hdr, e := tar.FileInfoHeader(info, link)
+		if e != nil {
+			return e
+		}
+		hdr.Name = prefix + filepath.ToSlash(rel)
+		if d.IsDir() {
+			hdr.Name += "/"
+		}
+		if e := tw.WriteHeader(hdr); e != nil {
+			return e
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		f, e := os.Open(p)
+		if e != nil {
+			return e
+		}
+		defer func() { _ = f.Close() }()
+		_, e = io.Copy(tw, f)
+		return e
+	})
+}
+
+// newRestoreDir create an empty temporary directory next to given data directory
+func newRestoreDir(dir string) (string, error) {
+	if len(dir) == 0 || filepath.Clean(dir) == string(filepath.Separator) {
+		return "", fmt.Errorf(`refuse to reset data directory [%s]`, dir)
+	}
+	parent := filepath.Dir(filepath.Clean(dir))
+	if e := os.MkdirAll(parent, 0755); e != nil {
+		return "", fmt.Errorf(`unable to create directory [%s]: %v`, parent, e)
+	}
+	tmpDir, e := os.MkdirTemp(parent, "."+filepath.Base(dir)+".restore-*")
+	if e != nil {
+		return "", fmt.Errorf(`unable to create temporary directory in [%s]: %v`, parent, e)
+	}
+	if e := os.Chmod(tmpDir, 0755); e != nil {
+		return "", fmt.Errorf(`unable to create temporary directory in [%s]: %v`, parent, e)
+	}
+	return tmpDir, nil
+}
+
+// replaceDir replace given directory with src. The original directory is put back if src cannot be moved in
+func replaceDir(dir, src string) error {
+	old := src + ".old"
+	if e := os.Rename(dir, old); e != nil && !errors.Is(e, fs.ErrNotExist) {
+		return fmt.Errorf(`unable to clean up data directory [%s]: %v`, dir, e)
+	}
+	if e := os.Rename(src, dir); e != nil {
+		_ = os.Rename(old, dir)
+		return fmt.Errorf(`unable to restore data directory [%s]: %v`, dir, e)
+	}
+	if e := os.RemoveAll(old); e != nil {
+		logger.Debugf(`Unable to remove old data directory [%s]: %v`, old, e)
+	}
+	return nil
+}
+
+func extractEntry(r io.Reader, hdr *tar.Header, dir, name string) error {
+	rel, e := safeRelPath(name)
+	if e != nil || rel == "." {
+		return e
+	}
+	dst := filepath.Join(dir, rel)
+	if e := checkNoSymlink(dir, rel); e != nil {
+		return fmt.Errorf(`unable to restore [%s]: %v`, dst, e)
+	}
+	mode := hdr.FileInfo().Mode()
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		e = os.MkdirAll(dst, mode.Perm()|0700)
+	case tar.TypeSymlink:
+		if e = checkLinkTarget(rel, hdr.Linkname); e == nil {
+			e = os.Symlink(hdr.Linkname, dst)
+		}
+	case tar.TypeReg:
+		var f *os.File
+		if f, e = os.OpenFile(dst, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, mode.Perm()); e != nil {
+			break
+		}
+		_, e = io.Copy(f, r)
+		if ce := f.Close(); e == nil {
+			e = ce
+		}
+	default:
+		logger.Debugf(`Skipped unsupported entry in snapshot: %s`, hdr.Name)
+	}
+	if e != nil {
+		return fmt.Errorf(`unable to restore [%s]: %v`, dst, e)
+	}
+	return nil
+}
+
+// checkLinkTarget make sure target of a symlink entry is relative and stays within the root directory of the entry
+func checkLinkTarget(rel, target string) error {
+	if filepath.IsAbs(filepath.FromSlash(target)) || path.IsAbs(target) {
+		return fmt.Errorf(`invalid symlink in archive: %s -> %s`, filepath.ToSlash(rel), target)
+	}
+	if _, e := safeRelPath(path.Join(path.Dir(filepath.ToSlash(rel)), target)); e != nil {
+		return fmt.Errorf(`invalid symlink in archive: %s -> %s`, filepath.ToSlash(rel), target)
+	}
+	return nil
+}
+
+// checkNoSymlink make sure none of existing elements of the relative path is a symlink,
+// so that entries are never written through symlinks
+func checkNoSymlink(dir, rel string) error {
+	p := dir
+	for _, elem := range strings.Split(rel, string(filepath.Separator)) {
+		p = filepath.Join(p, elem)
+		fi, e := os.Lstat(p)
+		switch {
+		case errors.Is(e, fs.ErrNotExist):
+			return nil
+		case e != nil:
+			return e
+		case fi.Mode()&fs.ModeSymlink != 0:
+			return fmt.Errorf(`refuse to write through symlink [%s]`, p)
+		}
+	}
+	return nil
+}
+
+/**********************
+	Docker Volumes
+ **********************/
+
+func archiveVolume(ctx context.Context, client *dockerclient.Client, tw *tar.Writer, vol string) error {
+	id, e := createHelperContainer(ctx, client, vol)
+	if e != nil {
+		return e
+	}
+	defer removeHelperContainer(ctx, client, id)
+
+	rc, _, e := client.CopyFromContainer(ctx, id, helperMountPoint)
+	if e != nil {
+		return e
+	}
+	defer func() { _ = rc.Close() }()
+	tr := tar.NewReader(rc)
+	for {
+		hdr, e := tr.Next()
+		switch {
+		case errors.Is(e, io.EOF):
+			return nil
+		case e != nil:
+			return e
+		}
+		// entries are prefixed with base name of the copied path
+		_, rel, _ := strings.Cut(hdr.Name, "/")
+		if len(rel) == 0 {
+			continue
+		}
+		hdr.Name = volumeEntryPrefix + vol + "/" + rel
+		if e := tw.WriteHeader(hdr); e != nil {
+			return e
+		}
+		if _, e := io.Copy(tw, tr); e != nil {
+			return e
+		}
+	}
+}
+
+func resetVolume(ctx context.Context, client *dockerclient.Client, v VolumeManifest) error {
+	if e := client.VolumeRemove(ctx, v.Name, true); e != nil && !dockerclient.IsErrNotFound(e) {
+		return fmt.Errorf(`unable to remove volume [%s]: %v`, v.Name, e)
+	}
+	if _, e := client.VolumeCreate(ctx, volume.CreateOptions{
+		Name:   v.Name,
+		Driver: v.Driver,
+		Labels: v.Labels,
+	}); e != nil {
+		return fmt.Errorf(`unable to create volume [%s]: %v`, v.Name, e)
+	}
+	return nil
+}
+
+// volumeRestorer stream tar entries into a volume via helper container
+type volumeRestorer struct {
+	volume    string
+	container string
+	client    *dockerclient.Client
+	pw        *io.PipeWriter
+	tar       *tar.Writer
+	errCh     chan error
+}
+
+func newVolumeRestorer(ctx context.Context, client *dockerclient.Client, vol string) (*volumeRestorer, error) {
+	id, e := createHelperContainer(ctx, client, vol)
+	if e != nil {
+		return nil, e
+	}
+	pr, pw := io.Pipe()
+	restorer := &volumeRestorer{
+		volume:    vol,
+		container: id,
+		client:    client,
+		pw:        pw,
+		tar:       tar.NewWriter(pw),
+		errCh:     make(chan error, 1),
+	}
+	go func() {
+		e := client.CopyToContainer(ctx, id, "/", pr, types.CopyToContainerOptions{})
+		_ = pr.CloseWithError(e)
+		restorer.errCh <- e
+	}()
+	return restorer, nil
+}
+
+func (r *volumeRestorer) write(hdr *tar.Header, rel string, content io.Reader) error {
+	if len(rel) == 0 {
+		return nil
+	}
+	if _, e := safeRelPath(rel); e != nil {
+		return e
+	}
+	hdr.Name = path.Join(path.Base(helperMountPoint), rel)
+	if hdr.Typeflag == tar.TypeDir {
+		hdr.Name += "/"
+	}
+	if e := r.tar.WriteHeader(hdr); e != nil {
+		return fmt.Errorf(`unable to restore volume [%s]: %v`, r.volume, e)
+	}
+	if _, e := io.Copy(r.tar, content); e != nil {
+		return fmt.Errorf(`unable to restore volume [%s]: %v`, r.volume, e)
+	}
+	return nil
+}
+
+func (r *volumeRestorer) Close(ctx context.Context) error {
+	defer removeHelperContainer(ctx, r.client, r.container)
+	_ = r.tar.Close()
+	_ = r.pw.Close()
+	if e := <-r.errCh; e != nil {
+		return fmt.Errorf(`unable to restore volume [%s]: %v`, r.volume, e)
+	}
+	return nil
+}
+
+func createHelperContainer(ctx context.Context, client *dockerclient.Client, vol string) (string, error) {
+	if _, _, e := client.ImageInspectWithRaw(ctx, HelperImage); e != nil {
+		logger.Debugf(`Pulling image [%s] ...`, HelperImage)
+		rc, e := client.ImagePull(ctx, HelperImage, image.PullOptions{})
+		if e != nil {
+			return "", fmt.Errorf(`unable to pull helper image [%s]: %v`, HelperImage, e)
+		}
+		_, _ = io.Copy(io.Discard, rc)
+		_ = rc.Close()
+	}
+	resp, e := client.ContainerCreate(ctx, &container.Config{
+		Image: HelperImage,
+		Cmd:   []string{"true"},
+	}, &container.HostConfig{
+		Mounts: []mount.Mount{
+			{Type: mount.TypeVolume, Source: vol, Target: helperMountPoint},
+		},
+	}, nil, nil, "")
+	if e != nil {
+		return "", fmt.Errorf(`unable to create helper container for volume [%s]: %v`, vol, e)
+	}
+	return resp.ID, nil
+}
+
+func removeHelperContainer(ctx context.Context, client *dockerclient.Client, id string) {
+	if e := client.ContainerRemove(ctx, id, container.RemoveOptions{Force: true}); e != nil {
+		logger.Debugf(`Unable to remove helper container [%s]: %v`, id, e)
+	}
+}
@@ -0,0 +1,198 @@
+package snapshot
+
+import (
+	"archive/tar"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testEntry an archive entry. Content is written for regular files
+type testEntry struct {
+	name     string
+	typeflag byte
+	link     string
+	content  string
+}
+
+// writeTestSnapshot write snapshot "test" of profile "test" with given manifest and entries. Returns the store
+func writeTestSnapshot(t *testing.T, m *Manifest, entries ...testEntry) *Store {
+	s := &Store{Dir: t.TempDir()}
+	path := s.Path("test", "test")
+	if e := os.MkdirAll(filepath.Dir(path), 0755); e != nil {
+		t.Fatalf("unable to create snapshot directory: %v", e)
+	}
+	f, e := os.Create(path)
+	if e != nil {
+		t.Fatalf("unable to create snapshot: %v", e)
+	}
+	defer func() { _ = f.Close() }()
+	w := newArchiveWriter(f)
+	if e := w.writeManifest(m); e != nil {
+		t.Fatalf("unable to write manifest: %v", e)
+	}
+	for _, entry := range entries {
+		hdr := &tar.Header{Name: entry.name, Typeflag: entry.typeflag, Linkname: entry.link, Mode: 0644, Size: int64(len(entry.content))}
+		if entry.typeflag != tar.TypeReg {
+			hdr.Mode, hdr.Size = 0755, 0
+		}
+		if e := w.tar.WriteHeader(hdr); e != nil {
+			t.Fatalf("unable to write entry: %v", e)
+		}
+		if _, e := w.tar.Write([]byte(entry.content)); e != nil {
+			t.Fatalf("unable to write entry: %v", e)
+		}
+	}
+	if e := w.Close(); e != nil {
+		t.Fatalf("unable to close snapshot: %v", e)
+	}
+	return s
+}
+
+func TestVerifyArchive(t *testing.T) {
+	manifest := &Manifest{Profile: "test", Name: "test", Volumes: []VolumeManifest{{Name: "test_db"}}}
+	tests := []struct {
+		name    string
+		entries []testEntry
+		err     string
+	}{
+		{name: "valid", entries: []testEntry{
+			{name: "data/db/", typeflag: tar.TypeDir},
+			{name: "data/db/current", typeflag: tar.TypeSymlink, link: "../logs"},
+			{name: "data/db/pg.conf", typeflag: tar.TypeReg, content: "max_connections=10"},
+			{name: "volumes/test_db/base/1", typeflag: tar.TypeReg, content: "1"},
+		}},
+		{name: "parent directory", entries: []testEntry{{name: "data/../../etc/passwd", typeflag: tar.TypeReg}},
+			err: `invalid path in archive: ../../etc/passwd`},
+		{name: "absolute symlink", entries: []testEntry{{name: "data/db/passwd", typeflag: tar.TypeSymlink, link: "/etc/passwd"}},
+			err: `invalid symlink in archive: db/passwd -> /etc/passwd`},
+		{name: "escaping symlink", entries: []testEntry{{name: "data/db/up", typeflag: tar.TypeSymlink, link: "../../up"}},
+			err: `invalid symlink in archive: db/up -> ../../up`},
+		{name: "escaping volume symlink", entries: []testEntry{{name: "volumes/test_db/root", typeflag: tar.TypeSymlink, link: ".."}},
+			err: `invalid symlink in archive: root -> ..`},
+		{name: "unknown volume", entries: []testEntry{{name: "volumes/other/file", typeflag: tar.TypeReg}},
+			err: `volume [other] is not in manifest`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := writeTestSnapshot(t, manifest, test.entries...)
+			e := verifyArchive(s.Path("test", "test"))
+			switch {
+			case len(test.err) == 0 && e != nil:
+				t.Errorf("unexpected error: %v", e)
+			case len(test.err) != 0 && (e == nil || e.Error() != test.err):
+				t.Errorf("expected error %q, but got %v", test.err, e)
+			}
+		})
+	}
+}
+
+func TestVerifyTruncatedArchive(t *testing.T) {
+	s := writeTestSnapshot(t, &Manifest{Profile: "test", Name: "test"},
+		testEntry{name: "data/file", typeflag: tar.TypeReg, content: strings.Repeat("data", 1024)})
+	path := s.Path("test", "test")
+	data, e := os.ReadFile(path)
+	if e != nil {
+		t.Fatalf("unable to read snapshot: %v", e)
+	}
+	if e := os.WriteFile(path, data[:len(data)-10], 0644); e != nil {
+		t.Fatalf("unable to truncate snapshot: %v", e)
+	}
+	if e := verifyArchive(path); e == nil {
+		t.Errorf("expected error of truncated archive")
+	}
+}
+
+func TestExtractEntry(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry testEntry
+		setup func(t *testing.T, dir string)
+		err   string
+	}{
+		{name: "regular file", entry: testEntry{name: "db/pg.conf", typeflag: tar.TypeReg, content: "max_connections=10"},
+			setup: func(t *testing.T, dir string) { _ = os.Mkdir(filepath.Join(dir, "db"), 0755) }},
+		{name: "relative symlink", entry: testEntry{name: "db/current", typeflag: tar.TypeSymlink, link: "../logs"},
+			setup: func(t *testing.T, dir string) { _ = os.Mkdir(filepath.Join(dir, "db"), 0755) }},
+		{name: "parent directory", entry: testEntry{name: "../escape", typeflag: tar.TypeReg},
+			err: `invalid path in archive: ../escape`},
+		{name: "absolute path", entry: testEntry{name: "/etc/passwd", typeflag: tar.TypeReg},
+			err: `invalid path in archive: /etc/passwd`},
+		{name: "absolute symlink", entry: testEntry{name: "passwd", typeflag: tar.TypeSymlink, link: "/etc/passwd"},
+			err: `invalid symlink in archive: passwd -> /etc/passwd`},
+		{name: "escaping symlink", entry: testEntry{name: "db/up", typeflag: tar.TypeSymlink, link: "../.."},
+			err: `invalid symlink in archive: db/up -> ../..`},
+		{name: "write through symlink", entry: testEntry{name: "link/passwd", typeflag: tar.TypeReg, content: "root"},
+			setup: func(t *testing.T, dir string) {
+				if e := os.Symlink(t.TempDir(), filepath.Join(dir, "link")); e != nil {
+					t.Fatalf("unable to create symlink: %v", e)
+				}
+			},
+			err: `refuse to write through symlink`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if test.setup != nil {
+				test.setup(t, dir)
+			}
+			hdr := &tar.Header{Name: test.entry.name, Typeflag: test.entry.typeflag, Linkname: test.entry.link, Mode: 0644}
+			e := extractEntry(strings.NewReader(test.entry.content), hdr, dir, test.entry.name)
+			if len(test.err) != 0 {
+				if e == nil || !strings.Contains(e.Error(), test.err) {
+					t.Fatalf("expected error %q, but got %v", test.err, e)
+				}
+				return
+			}
+			if e != nil {
+				t.Fatalf("unexpected error: %v", e)
+			}
+			dst := filepath.Join(dir, filepath.FromSlash(test.entry.name))
+			switch test.entry.typeflag {
+			case tar.TypeReg:
+				if data, e := os.ReadFile(dst); e != nil || string(data) != test.entry.content {
+					t.Errorf("expected content %q, but got %q, %v", test.entry.content, data, e)
+				}
+			case tar.TypeSymlink:
+				if link, e := os.Readlink(dst); e != nil || link != test.entry.link {
+					t.Errorf("expected symlink to %s, but got %s, %v", test.entry.link, link, e)
+				}
+			}
+		})
+	}
+}
+
+func TestCreateAndRestoreDataDir(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "data")
+	if e := os.MkdirAll(filepath.Join(src, "db"), 0755); e != nil {
+		t.Fatalf("unable to create data directory: %v", e)
+	}
+	_ = os.WriteFile(filepath.Join(src, "db", "pg.conf"), []byte("max_connections=10"), 0644)
+	_ = os.Symlink("pg.conf", filepath.Join(src, "db", "current.conf"))
+
+	s := &Store{Dir: t.TempDir()}
+	m := &Manifest{Profile: "test", Name: "before-upgrade", CreatedAt: time.Now(), DataDir: src}
+	if e := s.Create(context.Background(), nil, m); e != nil {
+		t.Fatalf("unable to create snapshot: %v", e)
+	}
+
+	// data directory is replaced as a whole
+	dst := filepath.Join(t.TempDir(), "data")
+	_ = os.MkdirAll(dst, 0755)
+	_ = os.WriteFile(filepath.Join(dst, "stale"), []byte("stale"), 0644)
+	if e := s.Restore(context.Background(), nil, "test", "before-upgrade", dst); e != nil {
+		t.Fatalf("unable to restore snapshot: %v", e)
+	}
+	if data, e := os.ReadFile(filepath.Join(dst, "db", "current.conf")); e != nil || string(data) != "max_connections=10" {
+		t.Errorf("expected data directory restored, but got %q, %v", data, e)
+	}
+	if _, e := os.Stat(filepath.Join(dst, "stale")); e == nil {
+		t.Errorf("expected stale file removed")
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(dst)); len(entries) != 1 {
+		t.Errorf("expected temporary directories removed, but got %v", entries)
+	}
+}
@@ -0,0 +1,224 @@
+package snapshot
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/cisco-open/go-lanai/pkg/log"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	RelHomeSnapshotDir = `.devenv/snapshots`
+	archiveExt         = `.tar.gz`
+	manifestEntry      = `manifest.json`
+	dataEntryPrefix    = `data/`
+	volumeEntryPrefix  = `volumes/`
+)
+
+var logger = log.New("CLI")
+
+var regexName = regexp.MustCompile(`^[a-zA-Z0-9][\w.-]*$`)
+
+// Manifest describes content of a snapshot archive. It's always the first entry of the archive.
+type Manifest struct {
+	Profile   string           `json:"profile"`
+	Name      string           `json:"name"`
+	CreatedAt time.Time        `json:"created_at"`
+	DataDir   string           `json:"data_dir"`
+	Volumes   []VolumeManifest `json:"volumes"`
+	// Size of the archive file in bytes. Not stored in archive
+	Size int64 `json:"-"`
+}
+
+// HumanSize returns Size in human-readable format
+func (m Manifest) HumanSize() string {
+	units := []string{"B", "KB", "MB", "GB"}
+	v := float64(m.Size)
+	for i := range units {
+		if v < 1024 {
+			if i == 0 {
+				return fmt.Sprintf(`%.0f%s`, v, units[i])
+			}
+			return fmt.Sprintf(`%.2f%s`, v, units[i])
+		}
+		v = v / 1024
+	}
+	return fmt.Sprintf(`%.2fGB`, v)
+}
+
+type VolumeManifest struct {
+	Name   string            `json:"name"`
+	Driver string            `json:"driver"`
+	Labels map[string]string `json:"labels"`
+}
+
+// DefaultStore returns Store under "~/.devenv/snapshots"
+func DefaultStore() *Store {
+	homeDir, _ := os.UserHomeDir()
+	return &Store{Dir: filepath.Join(homeDir, RelHomeSnapshotDir)}
+}
+
+// Store manages snapshot archives as "<Dir>/<profile>/<name>.tar.gz"
+type Store struct {
+	Dir string
+}
+
+func (s *Store) Path(profile, name string) string {
+	return filepath.Join(s.Dir, profile, name+archiveExt)
+}
+
+func (s *Store) Exists(profile, name string) bool {
+	_, e := os.Stat(s.Path(profile, name))
+	return e == nil
+}
+
+// List returns manifests of all snapshots of given profile, sorted by creation time
+func (s *Store) List(profile string) ([]*Manifest, error) {
+	entries, e := os.ReadDir(filepath.Join(s.Dir, profile))
+	switch {
+	case errors.Is(e, fs.ErrNotExist):
+		return nil, nil
+	case e != nil:
+		return nil, fmt.Errorf(`unable to list snapshots of profile [%s]: %v`, profile, e)
+	}
+	manifests := make([]*Manifest, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), archiveExt) {
+			continue
+		}
+		m, e := s.Manifest(profile, strings.TrimSuffix(entry.Name(), archiveExt))
+		if e != nil {
+			return nil, e
+		}
+		manifests = append(manifests, m)
+	}
+	sort.SliceStable(manifests, func(i, j int) bool { return manifests[i].CreatedAt.Before(manifests[j].CreatedAt) })
+	return manifests, nil
+}
+
+// Manifest read manifest of given snapshot
+func (s *Store) Manifest(profile, name string) (*Manifest, error) {
+	path := s.Path(profile, name)
+	f, e := os.Open(path)
+	if e != nil {
+		return nil, fmt.Errorf(`unable to open snapshot [%s] of profile [%s]: %v`, name, profile, e)
+	}
+	defer func() { _ = f.Close() }()
+	r, e := newArchiveReader(f)
+	if e != nil {
+		return nil, fmt.Errorf(`invalid snapshot [%s]: %v`, path, e)
+	}
+	defer func() { _ = r.Close() }()
+	m, e := r.readManifest()
+	if e != nil {
+		return nil, fmt.Errorf(`invalid snapshot [%s]: %v`, path, e)
+	}
+	if fi, e := f.Stat(); e == nil {
+		m.Size = fi.Size()
+	}
+	return m, nil
+}
+
+func (s *Store) Delete(profile, name string) error {
+	if e := os.Remove(s.Path(profile, name)); e != nil {
+		return fmt.Errorf(`unable to delete snapshot [%s] of profile [%s]: %v`, name, profile, e)
+	}
+	return nil
+}
+
+func ValidateName(name string) error {
+	if !regexName.MatchString(name) {
+		return fmt.Errorf(`invalid snapshot name [%s]: only letters, digits, "_", "-" and "." are allowed`, name)
+	}
+	return nil
+}
+
+/**********************
+	Archive Format
+ **********************/
+
+type archiveWriter struct {
+	gz  *gzip.Writer
+	tar *tar.Writer
+}
+
+func newArchiveWriter(w io.Writer) *archiveWriter {
+	gz := gzip.NewWriter(w)
+	return &archiveWriter{gz: gz, tar: tar.NewWriter(gz)}
+}
+
+func (w *archiveWriter) writeManifest(m *Manifest) error {
+	data, e := json.MarshalIndent(m, "", "  ")
+	if e != nil {
+		return e
+	}
+	if e := w.tar.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     manifestEntry,
+		Mode:     0644,
+		Size:     int64(len(data)),
+		ModTime:  m.CreatedAt,
+	}); e != nil {
+		return e
+	}
+	_, e = w.tar.Write(data)
+	return e
+}
+
+func (w *archiveWriter) Close() error {
+	if e := w.tar.Close(); e != nil {
+		return e
+	}
+	return w.gz.Close()
+}
+
+type archiveReader struct {
+	gz  *gzip.Reader
+	tar *tar.Reader
+}
+
+func newArchiveReader(r io.Reader) (*archiveReader, error) {
+	gz, e := gzip.NewReader(r)
+	if e != nil {
+		return nil, e
+	}
+	return &archiveReader{gz: gz, tar: tar.NewReader(gz)}, nil
+}
+
+func (r *archiveReader) readManifest() (*Manifest, error) {
+	hdr, e := r.tar.Next()
+	if e != nil {
+		return nil, fmt.Errorf(`missing manifest: %v`, e)
+	}
+	if hdr.Name != manifestEntry {
+		return nil, fmt.Errorf(`missing manifest`)
+	}
+	var m Manifest
+	if e := json.NewDecoder(r.tar).Decode(&m); e != nil {
+		return nil, fmt.Errorf(`invalid manifest: %v`, e)
+	}
+	return &m, nil
+}
+
+func (r *archiveReader) Close() error {
+	return r.gz.Close()
+}
+
+// safeRelPath clean relative path of archive entry and make sure it doesn't escape its root directory
+func safeRelPath(name string) (string, error) {
+	p := filepath.Clean(filepath.FromSlash(name))
+	if filepath.IsAbs(p) || p == ".." || strings.HasPrefix(p, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf(`invalid path in archive: %s`, name)
+	}
+	return p, nil
+}
@@ -0,0 +1,158 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	RelHomeStateDir = `.devenv/state`
+	recordFileExt   = `.json`
+)
+
+const (
+	OutcomeInProgress Outcome = "in-progress"
+	OutcomeSucceeded  Outcome = "succeeded"
+	OutcomeFailed     Outcome = "failed"
+)
+
+type Outcome string
+
+// Record is the state of last action performed on a profile
+type Record struct {
+	Profile        string            `json:"profile"`
+	Action         string            `json:"action"`
+	Services       []string          `json:"services,omitempty"`
+	DefinitionPath string            `json:"definition_path"`
+	WorkingDir     string            `json:"working_dir"`
+	ComposePath    string            `json:"compose_path"`
+	ComposeHash    string            `json:"compose_hash"`
+	Variables      map[string]string `json:"variables"`
+	StartedAt      time.Time         `json:"started_at"`
+	FinishedAt     time.Time         `json:"finished_at"`
+	Outcome        Outcome           `json:"outcome"`
+	Error          string            `json:"error,omitempty"`
+	// LastFullStart when all services of the profile were last started successfully. Carried over from previous records
+	LastFullStart time.Time `json:"last_full_start"`
+}
+
+// Store persists state records. Only latest record of each profile is kept.
+type Store interface {
+	Save(r *Record) error
+	// Load returns latest record of given profile. fs.ErrNotExist is returned if no record is found
+	Load(profile string) (*Record, error)
+	// List returns latest records of all profiles, sorted by profile name
+	List() ([]*Record, error)
+}
+
+// DefaultStore returns FileStore under "~/.devenv/state"
+func DefaultStore() Store {
+	homeDir, _ := os.UserHomeDir()
+	if len(homeDir) == 0 {
+		return NewFileStore("")
+	}
+	return NewFileStore(filepath.Join(homeDir, RelHomeStateDir))
+}
+
+func NewFileStore(dir string) *FileStore {
+	return &FileStore{Dir: dir}
+}
+
+// FileStore is a Store that save each profile's record as a JSON file in Dir
+type FileStore struct {
+	Dir string
+}
+
+func (s *FileStore) Save(r *Record) error {
+	if e := s.ensureDir(); e != nil {
+		return e
+	}
+	data, e := json.MarshalIndent(r, "", "  ")
+	if e != nil {
+		return fmt.Errorf(`unable to serialize state of profile [%s]: %v`, r.Profile, e)
+	}
+	// write to temporary file and rename, so readers never see partially written file
+	path := s.path(r.Profile)
+	tmp, e := os.CreateTemp(s.Dir, filepath.Base(path)+".*")
+	if e != nil {
+		return fmt.Errorf(`unable to save state of profile [%s]: %v`, r.Profile, e)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	_, e = tmp.Write(data)
+	if ce := tmp.Close(); e == nil {
+		e = ce
+	}
+	if e == nil {
+		e = os.Rename(tmp.Name(), path)
+	}
+	if e != nil {
+		return fmt.Errorf(`unable to save state of profile [%s]: %v`, r.Profile, e)
+	}
+	return nil
+}
+
+func (s *FileStore) Load(profile string) (*Record, error) {
+	if len(s.Dir) == 0 {
+		return nil, fs.ErrNotExist
+	}
+	return s.load(s.path(profile))
+}
+
+func (s *FileStore) List() ([]*Record, error) {
+	if len(s.Dir) == 0 {
+		return nil, nil
+	}
+	entries, e := os.ReadDir(s.Dir)
+	switch {
+	case errors.Is(e, fs.ErrNotExist):
+		return nil, nil
+	case e != nil:
+		return nil, fmt.Errorf(`unable to read state directory [%s]: %v`, s.Dir, e)
+	}
+	records := make([]*Record, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), recordFileExt) {
+			continue
+		}
+		r, e := s.load(filepath.Join(s.Dir, entry.Name()))
+		if e != nil {
+			return nil, e
+		}
+		records = append(records, r)
+	}
+	sort.SliceStable(records, func(i, j int) bool { return records[i].Profile < records[j].Profile })
+	return records, nil
+}
+
+func (s *FileStore) load(path string) (*Record, error) {
+	data, e := os.ReadFile(path)
+	if e != nil {
+		return nil, e
+	}
+	var r Record
+	if e := json.Unmarshal(data, &r); e != nil {
+		return nil, fmt.Errorf(`invalid state file [%s]: %v`, path, e)
+	}
+	return &r, nil
+}
+
+func (s *FileStore) path(profile string) string {
+	return filepath.Join(s.Dir, profile+recordFileExt)
+}
+
+func (s *FileStore) ensureDir() error {
+	if len(s.Dir) == 0 {
+		return fmt.Errorf(`state directory is not available`)
+	}
+	if e := os.MkdirAll(s.Dir, 0755); e != nil {
+		return fmt.Errorf(`unable to create state directory [%s]: %v`, s.Dir, e)
+	}
+	return nil
+}
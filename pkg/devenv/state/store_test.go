@@ -0,0 +1,122 @@
+package state
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileStore(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	tests := []struct {
+		name  string
+		saved []*Record
+		check func(t *testing.T, s *FileStore)
+	}{
+		{
+			name: "round trip",
+			saved: []*Record{{
+				Profile: "golanai", Action: "start", Services: []string{"db"}, ComposeHash: "sha256:abc",
+				Variables: map[string]string{"TOKEN": "****"}, StartedAt: now, FinishedAt: now.Add(time.Minute),
+				Outcome: OutcomeSucceeded, LastFullStart: now,
+			}},
+			check: func(t *testing.T, s *FileStore) {
+				r, e := s.Load("golanai")
+				switch {
+				case e != nil:
+					t.Fatalf("unexpected error: %v", e)
+				case r.Action != "start" || len(r.Services) != 1 || r.Services[0] != "db" || r.ComposeHash != "sha256:abc":
+					t.Errorf("unexpected record: %+v", r)
+				case r.Variables["TOKEN"] != "****" || r.Outcome != OutcomeSucceeded:
+					t.Errorf("unexpected record: %+v", r)
+				case !r.StartedAt.Equal(now) || !r.FinishedAt.Equal(now.Add(time.Minute)) || !r.LastFullStart.Equal(now):
+					t.Errorf("unexpected time of record: %+v", r)
+				}
+			},
+		},
+		{
+			name: "latest record kept",
+			saved: []*Record{
+				{Profile: "golanai", Action: "start", Outcome: OutcomeInProgress},
+				{Profile: "golanai", Action: "start", Outcome: OutcomeFailed, Error: "oops"},
+			},
+			check: func(t *testing.T, s *FileStore) {
+				r, e := s.Load("golanai")
+				if e != nil {
+					t.Fatalf("unexpected error: %v", e)
+				}
+				if r.Outcome != OutcomeFailed || r.Error != "oops" {
+					t.Errorf("expected latest record, but got %+v", r)
+				}
+				// temporary files are renamed or removed
+				entries, _ := os.ReadDir(s.Dir)
+				if len(entries) != 1 || entries[0].Name() != "golanai.json" {
+					t.Errorf("expected single state file, but got %v", entries)
+				}
+			},
+		},
+		{
+			name:  "list sorted by profile",
+			saved: []*Record{{Profile: "kafka"}, {Profile: "consul"}, {Profile: "golanai"}},
+			check: func(t *testing.T, s *FileStore) {
+				_ = os.WriteFile(filepath.Join(s.Dir, "notes.txt"), []byte("not a record"), 0644)
+				_ = os.Mkdir(filepath.Join(s.Dir, "dir.json"), 0755)
+				records, e := s.List()
+				if e != nil {
+					t.Fatalf("unexpected error: %v", e)
+				}
+				if len(records) != 3 || records[0].Profile != "consul" || records[1].Profile != "golanai" || records[2].Profile != "kafka" {
+					t.Errorf("expected records sorted by profile, but got %v", records)
+				}
+			},
+		},
+		{
+			name: "not found",
+			check: func(t *testing.T, s *FileStore) {
+				if _, e := s.Load("golanai"); !errors.Is(e, fs.ErrNotExist) {
+					t.Errorf("expected %v, but got %v", fs.ErrNotExist, e)
+				}
+				if records, e := s.List(); e != nil || len(records) != 0 {
+					t.Errorf("expected no records, but got %v, %v", records, e)
+				}
+			},
+		},
+		{
+			name:  "invalid state file",
+			saved: []*Record{{Profile: "golanai"}},
+			check: func(t *testing.T, s *FileStore) {
+				_ = os.WriteFile(filepath.Join(s.Dir, "golanai.json"), []byte("{"), 0644)
+				if _, e := s.Load("golanai"); e == nil {
+					t.Errorf("expected error of invalid state file")
+				}
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := NewFileStore(filepath.Join(t.TempDir(), "state"))
+			for _, r := range test.saved {
+				if e := s.Save(r); e != nil {
+					t.Fatalf("unable to save record: %v", e)
+				}
+			}
+			test.check(t, s)
+		})
+	}
+}
+
+func TestFileStoreWithoutDir(t *testing.T) {
+	s := NewFileStore("")
+	if e := s.Save(&Record{Profile: "golanai"}); e == nil {
+		t.Errorf("expected error when state directory is not available")
+	}
+	if _, e := s.Load("golanai"); !errors.Is(e, fs.ErrNotExist) {
+		t.Errorf("expected %v, but got %v", fs.ErrNotExist, e)
+	}
+	if records, e := s.List(); e != nil || records != nil {
+		t.Errorf("expected no records, but got %v, %v", records, e)
+	}
+}
@@ -1,15 +1,60 @@
 package devenv
 
 import (
+	"errors"
 	"fmt"
 	"github.com/stonedu1011/devenvctl/pkg/utils"
 	"github.com/stonedu1011/devenvctl/pkg/utils/tmplutils"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
 	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// VarSourceBuiltin variables derived from the profile, e.g. image names and PROJECT_NAME
+	VarSourceBuiltin VarSource = "builtin"
+	// VarSourceProfile default values in profile's "variables" section
+	VarSourceProfile VarSource = "profile"
+	// VarSourceDotEnv values from profile's .env file. See DotEnvPath
+	VarSourceDotEnv VarSource = "dotenv"
+	// VarSourceEnv values from environment variables prefixed with EnvVarPrefix
+	VarSourceEnv VarSource = "environment"
+	// VarSourceCLI values given by command line, i.e. "--set key=value"
+	VarSourceCLI VarSource = "cli"
+)
+
+// VarSource where value of a variable comes from
+type VarSource string
+
+const (
+	// EnvVarPrefix environment variables with this prefix override user variables, e.g. DEVENV_VAR_FOO=bar sets FOO
+	EnvVarPrefix = `DEVENV_VAR_`
+)
+
+var (
+	// TemplateDotEnvPath path of the profile's .env file, relative to profile's FS
+	TemplateDotEnvPath = tmplutils.MustParse(`{{.Dir}}/devenv-{{.Name}}.env`)
+	regexVarName       = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
 )
 
 type Variable struct {
-	Name  string
-	Value string
+	Name   string
+	Value  string
+	Source VarSource
+	// Secret if true, the value is masked when printed. See DisplayValue
+	Secret bool
+}
+
+// DisplayValue returns the value to print, SecretMask if the variable is secret
+func (v Variable) DisplayValue() string {
+	if v.Secret {
+		return SecretMask
+	}
+	return v.Value
 }
 
 func (v Variable) String() string {
@@ -43,10 +88,35 @@ func (v Variables) KVMap() map[string]string {
 	return vars
 }
 
+// MaskedKVMap same as KVMap, but values of secret variables are replaced with SecretMask
+func (v Variables) MaskedKVMap() map[string]string {
+	vars := map[string]string{}
+	for _, k := range v.Keys() {
+		entry := v.Get(k)
+		vars[entry.Name] = entry.DisplayValue()
+	}
+	return vars
+}
+
+// DotEnv returns variables in .env format, one "NAME=value" per line. Values are quoted if necessary, and secrets are masked
+func (v Variables) DotEnv() string {
+	var sb strings.Builder
+	for _, k := range v.Keys() {
+		entry := v.Get(k)
+		value := entry.DisplayValue()
+		if strings.ContainsAny(value, " \t\n\r#'\"\\$") {
+			value = strconv.Quote(value)
+		}
+		sb.WriteString(entry.Name + "=" + value + "\n")
+	}
+	return sb.String()
+}
+
 func NewVariablesWithProfile(p *Profile) Variables {
 	vars := Variables{
 		OrderedMap: utils.NewOrderedMapWithCap[string, Variable](len(p.Services)*5+5),
 	}
+	vars.Add(p.UserVariables...)
 	vars.Add(ResolveServiceVars(p)...)
 	vars.Add(ResolveBuildArgs(p)...)
 	vars.Add(ResolveGlobalVars(p)...)
@@ -58,7 +128,7 @@ func ResolveBuildArgs(p *Profile) []Variable {
 	for _, s := range p.Services {
 		for arg, v := range s.BuildArgs {
 			n := tmplutils.MustSprint(TemplateBuildArgName, arg)
-			vars = append(vars, Variable{Name: n, Value: v})
+			vars = append(vars, Variable{Name: n, Value: v, Source: VarSourceBuiltin})
 		}
 	}
 	sort.SliceStable(vars, func(i, j int) bool {
@@ -71,8 +141,8 @@ func ResolveServiceVars(p *Profile) []Variable {
 	vars := make([]Variable, 0, len(p.Services)*2)
 	for _, s := range p.Services {
 		vars = append(vars,
-			Variable{Name: tmplutils.MustSprint(TemplateServiceImage, s), Value: s.Image},
-			Variable{Name: tmplutils.MustSprint(TemplateServiceContainer, s), Value: s.ContainerName()},
+			Variable{Name: tmplutils.MustSprint(TemplateServiceImage, s), Value: s.Image, Source: VarSourceBuiltin},
+			Variable{Name: tmplutils.MustSprint(TemplateServiceContainer, s), Value: s.ContainerName(), Source: VarSourceBuiltin},
 		)
 	}
 	sort.SliceStable(vars, func(i, j int) bool {
@@ -83,10 +153,112 @@ func ResolveServiceVars(p *Profile) []Variable {
 
 func ResolveGlobalVars(p *Profile) []Variable {
 	vars := []Variable{
-		{Name: VarProjectName, Value: p.Name},
+		{Name: VarProjectName, Value: p.Name, Source: VarSourceBuiltin},
 	}
 	sort.SliceStable(vars, func(i, j int) bool {
 		return vars[i].Name < vars[j].Name
 	})
 	return vars
 }
+
+// ResolveUserVariables resolve user variables of given profile. Defaults in profile's "variables" section are overridden,
+// in order, by profile's .env file (see TemplateDotEnvPath), environment variables prefixed with EnvVarPrefix and given overrides.
+// Each layer may also introduce new variables. The result is sorted by name.
+func ResolveUserVariables(p *Profile, overrides map[string]string) ([]Variable, error) {
+	resolved := map[string]Variable{}
+	set := func(src VarSource, kvs map[string]string) error {
+		for k, v := range kvs {
+			if !regexVarName.MatchString(k) {
+				return fmt.Errorf(`invalid variable name [%s] from %s`, k, src)
+			}
+			resolved[k] = Variable{Name: k, Value: v, Source: src}
+		}
+		return nil
+	}
+	if e := set(VarSourceProfile, p.Variables); e != nil {
+		return nil, e
+	}
+	dotEnv, e := loadDotEnv(p.FS, DotEnvPath(&p.ProfileMetadata))
+	if e != nil {
+		return nil, e
+	}
+	if e := set(VarSourceDotEnv, dotEnv); e != nil {
+		return nil, e
+	}
+	if e := set(VarSourceEnv, prefixedEnv(EnvVarPrefix)); e != nil {
+		return nil, e
+	}
+	if e := set(VarSourceCLI, overrides); e != nil {
+		return nil, e
+	}
+
+	vars := make([]Variable, 0, len(resolved))
+	for _, v := range resolved {
+		vars = append(vars, v)
+	}
+	sort.SliceStable(vars, func(i, j int) bool {
+		return vars[i].Name < vars[j].Name
+	})
+	return vars, nil
+}
+
+// DotEnvPath returns path of the profile's .env file within the profile's FS
+func DotEnvPath(meta *ProfileMetadata) string {
+	return filepath.Clean(tmplutils.MustSprint(TemplateDotEnvPath, meta))
+}
+
+// loadDotEnv parse a .env file with "KEY=VALUE" lines. Empty lines and lines starting with "#" are ignored,
+// "export " prefix is allowed and values may be quoted. Returns nil if the file doesn't exist
+func loadDotEnv(fsys fs.FS, path string) (map[string]string, error) {
+	if fsys == nil {
+		return nil, nil
+	}
+	data, e := fs.ReadFile(fsys, path)
+	switch {
+	case errors.Is(e, fs.ErrNotExist):
+		return nil, nil
+	case e != nil:
+		return nil, fmt.Errorf(`unable to read "%s": %v`, utils.AbsPath(path, fsys), e)
+	}
+	kvs := map[string]string{}
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		k, v, ok := strings.Cut(strings.TrimPrefix(line, "export "), "=")
+		if !ok {
+			return nil, fmt.Errorf(`invalid line %d in "%s": expected KEY=VALUE`, i+1, utils.AbsPath(path, fsys))
+		}
+		k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+		if len(v) >= 2 && (v[0] == '"' || v[0] == '\'') && v[len(v)-1] == v[0] {
+			v = v[1 : len(v)-1]
+		}
+		kvs[k] = v
+	}
+	return kvs, nil
+}
+
+// prefixedEnv returns environment variables with given prefix, keyed by names without the prefix
+func prefixedEnv(prefix string) map[string]string {
+	kvs := map[string]string{}
+	for _, env := range os.Environ() {
+		if k, v, ok := strings.Cut(env, "="); ok && strings.HasPrefix(k, prefix) && len(k) > len(prefix) {
+			kvs[strings.TrimPrefix(k, prefix)] = v
+		}
+	}
+	return kvs
+}
+
+// ParseVariableOverrides parse "key=value" pairs, e.g. values of "--set" flags
+func ParseVariableOverrides(pairs []string) (map[string]string, error) {
+	kvs := make(map[string]string, len(pairs))
+	for _, pair := range pairs {
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok || !regexVarName.MatchString(k) {
+			return nil, fmt.Errorf(`invalid variable [%s], expected format is key=value`, pair)
+		}
+		kvs[k] = v
+	}
+	return kvs, nil
+}
@@ -0,0 +1,139 @@
+package devenv
+
+import (
+	"maps"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestResolveUserVariables(t *testing.T) {
+	p := &Profile{
+		ProfileMetadata: ProfileMetadata{
+			FS:   fstest.MapFS{"profiles/devenv-test.env": {Data: []byte("DOTENV=dotenv\nENV=dotenv\nCLI=dotenv\nNEW_DOTENV=dotenv\n")}},
+			Name: "test",
+			Dir:  "profiles",
+		},
+		Variables: map[string]string{"PROFILE": "profile", "DOTENV": "profile", "ENV": "profile", "CLI": "profile"},
+	}
+	t.Setenv(EnvVarPrefix+"ENV", "environment")
+	t.Setenv(EnvVarPrefix+"CLI", "environment")
+	t.Setenv(EnvVarPrefix, "ignored")
+
+	vars, e := ResolveUserVariables(p, map[string]string{"CLI": "cli", "NEW_CLI": "cli"})
+	if e != nil {
+		t.Fatalf("unexpected error: %v", e)
+	}
+	expected := []Variable{
+		{Name: "CLI", Value: "cli", Source: VarSourceCLI},
+		{Name: "DOTENV", Value: "dotenv", Source: VarSourceDotEnv},
+		{Name: "ENV", Value: "environment", Source: VarSourceEnv},
+		{Name: "NEW_CLI", Value: "cli", Source: VarSourceCLI},
+		{Name: "NEW_DOTENV", Value: "dotenv", Source: VarSourceDotEnv},
+		{Name: "PROFILE", Value: "profile", Source: VarSourceProfile},
+	}
+	if len(vars) != len(expected) {
+		t.Fatalf("expected %v, but got %v", expected, vars)
+	}
+	for i := range expected {
+		if vars[i] != expected[i] {
+			t.Errorf("expected %+v, but got %+v", expected[i], vars[i])
+		}
+	}
+}
+
+func TestResolveUserVariablesInvalidName(t *testing.T) {
+	p := &Profile{
+		ProfileMetadata: ProfileMetadata{Name: "test"},
+		Variables:       map[string]string{"not-valid": "value"},
+	}
+	_, e := ResolveUserVariables(p, nil)
+	if e == nil || e.Error() != `invalid variable name [not-valid] from profile` {
+		t.Errorf("expected invalid variable name, but got %v", e)
+	}
+}
+
+func TestLoadDotEnv(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected map[string]string
+		err      string
+	}{
+		{name: "empty", content: "", expected: map[string]string{}},
+		{name: "comments and empty lines",
+			content:  "# comment\n\n  # indented comment\nKEY=value\n",
+			expected: map[string]string{"KEY": "value"}},
+		{name: "export prefix",
+			content:  "export KEY=value\n",
+			expected: map[string]string{"KEY": "value"}},
+		{name: "quoted values",
+			content:  `DOUBLE="a b"` + "\n" + `SINGLE='c # d'` + "\n" + `MISMATCHED="e'` + "\n" + `INNER=f="g"`,
+			expected: map[string]string{"DOUBLE": "a b", "SINGLE": "c # d", "MISMATCHED": `"e'`, "INNER": `f="g"`}},
+		{name: "whitespaces",
+			content:  "  KEY = value  \r\nEMPTY=\n",
+			expected: map[string]string{"KEY": "value", "EMPTY": ""}},
+		{name: "invalid line",
+			content: "KEY=value\nINVALID\n",
+			err:     `devenv-test.env": expected KEY=VALUE`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fsys := fstest.MapFS{"profiles/devenv-test.env": {Data: []byte(test.content)}}
+			kvs, e := loadDotEnv(fsys, "profiles/devenv-test.env")
+			if len(test.err) != 0 {
+				if e == nil || !strings.HasPrefix(e.Error(), "invalid line 2") || !strings.HasSuffix(e.Error(), test.err) {
+					t.Fatalf("expected error %q, but got %v", test.err, e)
+				}
+				return
+			}
+			if e != nil {
+				t.Fatalf("unexpected error: %v", e)
+			}
+			if !maps.Equal(kvs, test.expected) {
+				t.Errorf("expected %v, but got %v", test.expected, kvs)
+			}
+		})
+	}
+}
+
+func TestLoadDotEnvNotExist(t *testing.T) {
+	kvs, e := loadDotEnv(fstest.MapFS{}, "profiles/devenv-test.env")
+	if e != nil || kvs != nil {
+		t.Errorf("expected no variables without error, but got %v and %v", kvs, e)
+	}
+}
+
+func TestParseVariableOverrides(t *testing.T) {
+	tests := []struct {
+		name     string
+		pairs    []string
+		expected map[string]string
+		err      string
+	}{
+		{name: "none", expected: map[string]string{}},
+		{name: "pairs",
+			pairs:    []string{"A=1", "B=x=y", "EMPTY=", "A=2"},
+			expected: map[string]string{"A": "2", "B": "x=y", "EMPTY": ""}},
+		{name: "missing value", pairs: []string{"A"}, err: `invalid variable [A], expected format is key=value`},
+		{name: "invalid name", pairs: []string{"1A=1"}, err: `invalid variable [1A=1], expected format is key=value`},
+		{name: "empty name", pairs: []string{"=1"}, err: `invalid variable [=1], expected format is key=value`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			kvs, e := ParseVariableOverrides(test.pairs)
+			if len(test.err) != 0 {
+				if e == nil || e.Error() != test.err {
+					t.Fatalf("expected error %q, but got %v", test.err, e)
+				}
+				return
+			}
+			if e != nil {
+				t.Fatalf("unexpected error: %v", e)
+			}
+			if !maps.Equal(kvs, test.expected) {
+				t.Errorf("expected %v, but got %v", test.expected, kvs)
+			}
+		})
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"github.com/cisco-open/go-lanai/pkg/log"
 	"github.com/cisco-open/go-lanai/pkg/utils"
 	"github.com/spf13/cobra"
+	"os"
 )
 
 const (
@@ -30,8 +31,17 @@ var (
 func init() {
 	MustUpdateLoggingConfiguration(NewLogConfig(log.LevelInfo, logTemplate))
 	cobra.OnInitialize(func() {
-		if GlobalArgs.Verbose {
+		if GlobalArgs.Output == OutputJson {
+			// stdout is reserved for events, any other output goes to stderr
+			eventOutput = os.Stdout
+			os.Stdout = os.Stderr
+		}
+		switch {
+		case GlobalArgs.Verbose:
 			MustUpdateLoggingConfiguration(NewLogConfig(log.LevelDebug, logVerboseTemplate))
+		case GlobalArgs.Output == OutputJson:
+			// re-create loggers with redirected stdout
+			MustUpdateLoggingConfiguration(NewLogConfig(log.LevelInfo, logTemplate))
 		}
 	})
 }
@@ -43,12 +53,17 @@ func New(name string) *cobra.Command {
 		Long:               description,
 		FParseErrWhitelist: cobra.FParseErrWhitelist{UnknownFlags: true},
 		PersistentPreRunE: cmdutils.MergeRunE(
+			ValidateOutputRunE(),
+			ReserveStdoutRunE(),
 			cmdutils.EnsureDir(&GlobalArgs.TmpDir, GlobalArgs.WorkingDir, true, "temporary directory"),
 			PrintHeaderRunE(),
 			SearchProfilesRunE(),
 		),
 	}
 	cmdutils.PersistentFlags(cmd, &GlobalArgs)
+	// values may contain commas, so "--set" is registered as string array instead of string slice
+	cmd.PersistentFlags().StringArrayVar(&GlobalArgs.Variables, "set", nil,
+		"override a user variable of the profile, in \"key=value\" format. Can be repeated")
 	return cmd
 }
 
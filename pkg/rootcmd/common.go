@@ -7,12 +7,15 @@ import (
 	"github.com/cisco-open/go-lanai/pkg/log"
 	"github.com/spf13/cobra"
 	"github.com/stonedu1011/devenvctl/pkg/devenv"
+	"github.com/stonedu1011/devenvctl/pkg/devenv/plan"
 	"github.com/stonedu1011/devenvctl/pkg/tmpls"
 	"github.com/stonedu1011/devenvctl/pkg/utils/tmplutils"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 )
 
 var logger = log.New("CLI")
@@ -24,11 +27,28 @@ var (
 	LoadedProfile *devenv.Profile
 )
 
+const (
+	OutputText = "text"
+	OutputJson = "json"
+)
+
+const (
+	// AnnotationStdoutResult commands with this annotation write their result to stdout (see ResultOutput),
+	// while header, logs and everything else go to stderr
+	AnnotationStdoutResult = `devenvctl.stdout-result`
+)
+
 var (
 	GlobalArgs = Global{
-		WorkingDir: DefaultWorkingDir(),
-		TmpDir:     DefaultTemporaryDir(),
+		WorkingDir:  DefaultWorkingDir(),
+		TmpDir:      DefaultTemporaryDir(),
+		Concurrency: plan.DefaultConcurrency,
+		Output:      OutputText,
 	}
+	// eventOutput where events are written in "json" output mode. See NewEventSink
+	eventOutput io.Writer
+	// resultOutput where commands annotated with AnnotationStdoutResult write their result. See ResultOutput
+	resultOutput io.Writer = os.Stdout
 )
 
 type Global struct {
@@ -36,6 +56,11 @@ type Global struct {
 	TmpDir      string   `flag:"tmp-dir" desc:"temporary directory."`
 	Verbose     bool     `flag:"verbose,v" desc:"show debug information"`
 	SearchPaths []string `flag:"search-paths,s" desc:"additional paths to search for profiles definitions"`
+	Concurrency int      `flag:"concurrency" desc:"max number of independent steps (e.g. hooks of unrelated services) running at same time. 1 to run all steps sequentially"`
+	Engine      string   `flag:"engine" desc:"how to run profiles: \"compose\" (docker compose CLI) or \"docker\" (Docker Engine API). Overrides profile's \"engine\""`
+	Output      string   `flag:"output" desc:"output format: \"text\" or \"json\". In \"json\" mode, plan execution events are written to stdout as newline-delimited JSON, and everything else to stderr"`
+	// Variables "key=value" overrides of profile's user variables, registered as repeatable "--set" flag. See New
+	Variables []string
 }
 
 func DefaultWorkingDir() string {
@@ -70,6 +95,75 @@ func RequireProfileArgs() cobra.PositionalArgs {
 	}
 }
 
+// RequireProfileAndServicesArgs requires profile name as first argument, followed by optional service names
+func RequireProfileAndServicesArgs() cobra.PositionalArgs {
+	return func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			return errors.New("missing environment's profile name")
+		}
+		return RequireProfileArgs()(cmd, args[:1])
+	}
+}
+
+// NewEventSink returns plan.EventSink according to "--output", or nil if events are not requested
+func NewEventSink() plan.EventSink {
+	if GlobalArgs.Output != OutputJson || eventOutput == nil {
+		return nil
+	}
+	return plan.NewJsonEventSink(eventOutput)
+}
+
+// ParseOptionalDuration parse duration flag such as "90s" or "5m". Returns 0 if value is empty
+func ParseOptionalDuration(flag, value string) (time.Duration, error) {
+	if len(value) == 0 {
+		return 0, nil
+	}
+	d, e := time.ParseDuration(value)
+	if e != nil {
+		return 0, fmt.Errorf(`invalid --%s "%s": %v`, flag, value, e)
+	}
+	return d, nil
+}
+
+func ValidateOutputRunE() cmdutils.RunE {
+	return func(cmd *cobra.Command, args []string) error {
+		switch GlobalArgs.Output {
+		case OutputText, OutputJson:
+			return nil
+		default:
+			return fmt.Errorf(`unsupported output format [%s], expecting "%s" or "%s"`, GlobalArgs.Output, OutputText, OutputJson)
+		}
+	}
+}
+
+// ReserveStdoutRunE redirect everything else to stderr for commands annotated with AnnotationStdoutResult,
+// so that their result written to ResultOutput can be piped
+func ReserveStdoutRunE() cmdutils.RunE {
+	return func(cmd *cobra.Command, args []string) error {
+		if _, ok := cmd.Annotations[AnnotationStdoutResult]; !ok || os.Stdout == os.Stderr {
+			return nil
+		}
+		resultOutput = os.Stdout
+		os.Stdout = os.Stderr
+		// re-create loggers with redirected stdout
+		if GlobalArgs.Verbose {
+			MustUpdateLoggingConfiguration(NewLogConfig(log.LevelDebug, logVerboseTemplate))
+		} else {
+			MustUpdateLoggingConfiguration(NewLogConfig(log.LevelInfo, logTemplate))
+		}
+		return nil
+	}
+}
+
+// ResultOutput returns where the result of a command should be written. It's the original stdout even if it's redirected,
+// e.g. by ReserveStdoutRunE or in "json" output mode
+func ResultOutput() io.Writer {
+	if eventOutput != nil {
+		return eventOutput
+	}
+	return resultOutput
+}
+
 func PrintHeaderRunE() cmdutils.RunE {
 	return func(cmd *cobra.Command, args []string) error {
 		tmplData := map[string]interface{}{
@@ -91,15 +185,7 @@ func SearchProfilesRunE() cmdutils.RunE {
 // LoadProfileRunE common RunE for any command that requires profile as argument
 func LoadProfileRunE() cmdutils.RunE {
 	return func(cmd *cobra.Command, args []string) error {
-		// Arguments should be verified at this moment
-		pName := args[0]
-		profiles, e := SearchProfiles()
-		if e != nil {
-			return e
-		}
-		pMeta := profiles[pName]
-		LoadedProfile, e = devenv.LoadProfile(pMeta)
-		if e != nil {
+		if e := LoadProfileQuietlyRunE()(cmd, args); e != nil {
 			return e
 		}
 		if e := tmplutils.Print(tmpls.OutputTemplate.Lookup("profile.tmpl"), LoadedProfile); e != nil {
@@ -112,3 +198,22 @@ func LoadProfileRunE() cmdutils.RunE {
 		return nil
 	}
 }
+
+// LoadProfileQuietlyRunE same as LoadProfileRunE, but doesn't print profile information
+func LoadProfileQuietlyRunE() cmdutils.RunE {
+	return func(cmd *cobra.Command, args []string) error {
+		// Arguments should be verified at this moment
+		pName := args[0]
+		profiles, e := SearchProfiles()
+		if e != nil {
+			return e
+		}
+		pMeta := profiles[pName]
+		overrides, e := devenv.ParseVariableOverrides(GlobalArgs.Variables)
+		if e != nil {
+			return e
+		}
+		LoadedProfile, e = devenv.LoadProfile(pMeta, devenv.WithProfiles(profiles), devenv.WithVariables(overrides))
+		return e
+	}
+}
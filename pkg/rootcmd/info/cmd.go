@@ -44,6 +44,12 @@ func Run(_ *cobra.Command, _ []string) error {
 		return e
 	}
 
+	// secrets are not resolved, only their names and sources are shown
+	vars = append(devenv.NewVariablesWithProfile(rootcmd.LoadedProfile).List(), devenv.SecretPlaceholders(rootcmd.LoadedProfile)...)
+	if e := tmplutils.Print(tmpls.OutputTemplate.Lookup("variables.tmpl"), vars); e != nil {
+		return e
+	}
+
 	if e := tmplutils.Print(tmpls.OutputTemplate.Lookup("hooks.tmpl"), rootcmd.LoadedProfile); e != nil {
 		return e
 	}
@@ -0,0 +1,93 @@
+package logs
+
+import (
+	"context"
+	"fmt"
+	"github.com/cisco-open/go-lanai/cmd/lanai-cli/cmdutils"
+	"github.com/docker/docker/api/types/container"
+	dockerclient "github.com/docker/docker/client"
+	"github.com/spf13/cobra"
+	"github.com/stonedu1011/devenvctl/pkg/devenv"
+	"github.com/stonedu1011/devenvctl/pkg/devenv/plan"
+	"github.com/stonedu1011/devenvctl/pkg/rootcmd"
+)
+
+const (
+	CommandName = "logs"
+)
+
+var (
+	Cmd = &cobra.Command{
+		Use:                fmt.Sprintf(`%s <profile> [service...]`, CommandName),
+		Short:              "Show logs of profile's services. All running services are included if no service is specified",
+		FParseErrWhitelist: cobra.FParseErrWhitelist{UnknownFlags: true},
+		Args:               rootcmd.RequireProfileAndServicesArgs(),
+		PreRunE:            rootcmd.LoadProfileQuietlyRunE(),
+		RunE:               Run,
+	}
+	Args = Arguments{
+		Tail: "all",
+	}
+)
+
+type Arguments struct {
+	Follow     bool   `flag:"follow,f" desc:"follow log output"`
+	Since      string `flag:"since" desc:"show logs since timestamp (e.g. 2013-01-02T13:23:37Z) or relative (e.g. 42m for 42 minutes)"`
+	Tail       string `flag:"tail,n" desc:"number of lines to show from the end of the logs for each container"`
+	Timestamps bool   `flag:"timestamps,t" desc:"show timestamps"`
+}
+
+func init() {
+	cmdutils.PersistentFlags(Cmd, &Args)
+}
+
+func Run(cmd *cobra.Command, args []string) error {
+	client, e := plan.NewDockerClient()
+	if e != nil {
+		return e
+	}
+	defer func() { _ = client.Close() }()
+
+	services := args[1:]
+	if len(services) == 0 {
+		if services, e = resolveRuntimeServices(cmd.Context(), client, rootcmd.LoadedProfile); e != nil {
+			return e
+		}
+	}
+	if len(services) == 0 {
+		return fmt.Errorf(`profile [%s] is not running`, rootcmd.LoadedProfile.Name)
+	}
+
+	exec := plan.NewContainerMonitorExecutable(client, func(exec *plan.ContainerMonitorExecutable) {
+		exec.Names = services
+		exec.Desc = "logs"
+		exec.Resolver = plan.ComposeContainerResolver(rootcmd.LoadedProfile.Name)
+		exec.LogsOptions = &container.LogsOptions{
+			ShowStdout: true,
+			ShowStderr: true,
+			Follow:     Args.Follow,
+			Since:      Args.Since,
+			Tail:       Args.Tail,
+			Timestamps: Args.Timestamps,
+		}
+	})
+	return exec.Exec(cmd.Context(), plan.ExecOption{
+		Verbose:   rootcmd.GlobalArgs.Verbose,
+		EventSink: rootcmd.NewEventSink(),
+	})
+}
+
+// resolveRuntimeServices find profile's services that have containers, in order of service dependencies
+func resolveRuntimeServices(ctx context.Context, client *dockerclient.Client, p *devenv.Profile) ([]string, error) {
+	status, e := plan.ResolveProfileStatus(ctx, client, p)
+	if e != nil {
+		return nil, e
+	}
+	services := make([]string, 0, len(status.Services))
+	for _, s := range status.Services {
+		if !s.Missing() {
+			services = append(services, s.Name)
+		}
+	}
+	return services, nil
+}
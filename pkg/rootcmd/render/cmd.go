@@ -0,0 +1,82 @@
+package render
+
+import (
+	"fmt"
+	"github.com/cisco-open/go-lanai/cmd/lanai-cli/cmdutils"
+	"github.com/cisco-open/go-lanai/pkg/log"
+	"github.com/spf13/cobra"
+	"github.com/stonedu1011/devenvctl/pkg/devenv/plan"
+	"github.com/stonedu1011/devenvctl/pkg/rootcmd"
+	"github.com/stonedu1011/devenvctl/pkg/utils"
+	"os"
+	"path/filepath"
+)
+
+var logger = log.New("CLI")
+
+const (
+	CommandName = "render"
+	dotEnvFile  = `.env`
+)
+
+var (
+	Cmd = &cobra.Command{
+		Use:   fmt.Sprintf(`%s <profile>`, CommandName),
+		Short: "Render docker compose file of the profile without running it. Docker is not required",
+		Long: `Render docker compose file of the profile without running it. Docker is not required.
+With --output-dir, the rendered docker compose file, a .env file with all variables and the resource directory are written to given directory.
+Otherwise, the rendered docker compose file is printed to stdout. Secrets are not resolved, and are rendered as "******".`,
+		FParseErrWhitelist: cobra.FParseErrWhitelist{UnknownFlags: true},
+		Args:               rootcmd.RequireProfileArgs(),
+		PreRunE:            rootcmd.LoadProfileQuietlyRunE(),
+		RunE:               Run,
+		Annotations:        map[string]string{rootcmd.AnnotationStdoutResult: ""},
+	}
+	Args = Arguments{}
+)
+
+type Arguments struct {
+	OutputDir string `flag:"output-dir,o" desc:"directory to write rendered files to. The rendered docker compose file is printed to stdout if not set"`
+}
+
+func init() {
+	cmdutils.PersistentFlags(Cmd, &Args)
+}
+
+func Run(_ *cobra.Command, _ []string) error {
+	// without --output-dir, render into a private temporary directory, so the working directory of running profiles is not touched
+	var dir string
+	if len(Args.OutputDir) != 0 {
+		dir = utils.AbsPath(Args.OutputDir, rootcmd.GlobalArgs.WorkingDir)
+		if e := os.MkdirAll(dir, 0755); e != nil {
+			return fmt.Errorf(`unable to create directory [%s]: %v`, dir, e)
+		}
+	} else {
+		var e error
+		if dir, e = os.MkdirTemp("", CommandName+"-*"); e != nil {
+			return fmt.Errorf(`unable to create temporary directory: %v`, e)
+		}
+		defer func() { _ = os.RemoveAll(dir) }()
+	}
+	metadata, e := plan.NewDockerComposePlanner(rootcmd.LoadedProfile, dir).Render()
+	if e != nil {
+		return e
+	}
+
+	if len(Args.OutputDir) == 0 {
+		data, e := os.ReadFile(metadata.ComposePath)
+		if e != nil {
+			return fmt.Errorf(`unable to read rendered docker compose file [%s]: %v`, metadata.ComposePath, e)
+		}
+		_, e = rootcmd.ResultOutput().Write(data)
+		return e
+	}
+
+	envPath := filepath.Join(dir, dotEnvFile)
+	if e := os.WriteFile(envPath, []byte(metadata.Variables.DotEnv()), 0644); e != nil {
+		return fmt.Errorf(`unable to write [%s]: %v`, envPath, e)
+	}
+	logger.Infof(`Rendered profile [%s] into [%s]: %s, %s, %s`, rootcmd.LoadedProfile.Name, dir,
+		filepath.Base(metadata.ComposePath), dotEnvFile, filepath.Base(metadata.ResourceDir))
+	return nil
+}
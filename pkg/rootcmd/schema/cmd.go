@@ -0,0 +1,41 @@
+package schema
+
+import (
+	"encoding/json"
+	"github.com/cisco-open/go-lanai/cmd/lanai-cli/cmdutils"
+	"github.com/spf13/cobra"
+	"github.com/stonedu1011/devenvctl/pkg/devenv"
+	"github.com/stonedu1011/devenvctl/pkg/rootcmd"
+)
+
+const (
+	CommandName = "schema"
+)
+
+var (
+	Cmd = &cobra.Command{
+		Use:   CommandName,
+		Short: "Print JSON Schema of profile definition files",
+		Long: `Print JSON Schema of profile definition files to stdout, for editors to autocomplete and highlight errors.
+e.g. "devenvctl schema > devenv-schema.json", and add "# yaml-language-server: $schema=./devenv-schema.json" to definition files.
+The same schema is used to check definition files when profiles are loaded.`,
+		FParseErrWhitelist: cobra.FParseErrWhitelist{UnknownFlags: true},
+		Args:               cobra.NoArgs,
+		RunE:               Run,
+		Annotations:        map[string]string{rootcmd.AnnotationStdoutResult: ""},
+	}
+	Args = Arguments{}
+)
+
+type Arguments struct {
+}
+
+func init() {
+	cmdutils.PersistentFlags(Cmd, &Args)
+}
+
+func Run(_ *cobra.Command, _ []string) error {
+	enc := json.NewEncoder(rootcmd.ResultOutput())
+	enc.SetIndent("", "  ")
+	return enc.Encode(devenv.ProfileSchema())
+}
@@ -0,0 +1,189 @@
+package snapshot
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"github.com/cisco-open/go-lanai/cmd/lanai-cli/cmdutils"
+	"github.com/cisco-open/go-lanai/pkg/log"
+	dockerclient "github.com/docker/docker/client"
+	"github.com/spf13/cobra"
+	"github.com/stonedu1011/devenvctl/pkg/devenv/plan"
+	"github.com/stonedu1011/devenvctl/pkg/devenv/snapshot"
+	"github.com/stonedu1011/devenvctl/pkg/rootcmd"
+	"github.com/stonedu1011/devenvctl/pkg/tmpls"
+	"github.com/stonedu1011/devenvctl/pkg/utils"
+	"github.com/stonedu1011/devenvctl/pkg/utils/tmplutils"
+	"io"
+)
+
+const (
+	CommandName = "snapshot"
+)
+
+var logger = log.New("CLI")
+
+var (
+	Cmd = &cobra.Command{
+		Use:   fmt.Sprintf(`%s <create|list|restore|delete>`, CommandName),
+		Short: "Manage snapshots of profile's local data and persisted volumes",
+	}
+	CreateCmd = &cobra.Command{
+		Use:                `create <profile> <name>`,
+		Short:              "Stop profile and archive its local data directory and persisted volumes",
+		FParseErrWhitelist: cobra.FParseErrWhitelist{UnknownFlags: true},
+		Args:               requireProfileAndNameArgs(),
+		PreRunE:            rootcmd.LoadProfileQuietlyRunE(),
+		RunE:               RunCreate,
+	}
+	ListCmd = &cobra.Command{
+		Use:                `list <profile>`,
+		Short:              "List snapshots of profile",
+		FParseErrWhitelist: cobra.FParseErrWhitelist{UnknownFlags: true},
+		Args:               rootcmd.RequireProfileArgs(),
+		RunE:               RunList,
+	}
+	RestoreCmd = &cobra.Command{
+		Use:                `restore <profile> <name>`,
+		Short:              "Stop profile and replace its local data directory and persisted volumes with snapshot",
+		FParseErrWhitelist: cobra.FParseErrWhitelist{UnknownFlags: true},
+		Args:               requireProfileAndNameArgs(),
+		PreRunE:            rootcmd.LoadProfileQuietlyRunE(),
+		RunE:               RunRestore,
+	}
+	DeleteCmd = &cobra.Command{
+		Use:                `delete <profile> <name>`,
+		Short:              "Delete snapshot of profile",
+		FParseErrWhitelist: cobra.FParseErrWhitelist{UnknownFlags: true},
+		Args:               requireProfileAndNameArgs(),
+		RunE:               RunDelete,
+	}
+	Args = Arguments{}
+)
+
+//go:embed list.tmpl
+var templateFS embed.FS
+
+type Arguments struct {
+	DryRun bool `flag:"dry-run" desc:"print out commands instead of run them"`
+}
+
+func init() {
+	cmdutils.PersistentFlags(Cmd, &Args)
+	Cmd.AddCommand(CreateCmd, ListCmd, RestoreCmd, DeleteCmd)
+}
+
+func RunCreate(cmd *cobra.Command, args []string) error {
+	if snapshot.DefaultStore().Exists(args[0], args[1]) {
+		logger.Warnf(`Snapshot [%s] of profile [%s] already exists and will be replaced`, args[1], args[0])
+	}
+	return runWithProfileStopped(cmd, func(client *dockerclient.Client) plan.Executable {
+		return &plan.SnapshotCreateExecutable{
+			ApiClient: client,
+			Store:     snapshot.DefaultStore(),
+			Profile:   rootcmd.LoadedProfile,
+			Name:      args[1],
+		}
+	})
+}
+
+func RunRestore(cmd *cobra.Command, args []string) error {
+	// fail fast if snapshot is not readable
+	if _, e := snapshot.DefaultStore().Manifest(args[0], args[1]); e != nil {
+		return e
+	}
+	return runWithProfileStopped(cmd, func(client *dockerclient.Client) plan.Executable {
+		return &plan.SnapshotRestoreExecutable{
+			ApiClient: client,
+			Store:     snapshot.DefaultStore(),
+			Profile:   rootcmd.LoadedProfile,
+			Name:      args[1],
+		}
+	})
+}
+
+func RunList(_ *cobra.Command, args []string) error {
+	manifests, e := snapshot.DefaultStore().List(args[0])
+	if e != nil {
+		return e
+	}
+	return tmplutils.PrintFS(templateFS, "list.tmpl", map[string]interface{}{
+		"Profile":   args[0],
+		"Snapshots": manifests,
+	})
+}
+
+func RunDelete(_ *cobra.Command, args []string) error {
+	store := snapshot.DefaultStore()
+	if Args.DryRun {
+		fmt.Printf("- delete snapshot [%s] of profile [%s]: %s\n", args[1], args[0], store.Path(args[0], args[1]))
+		return nil
+	}
+	if e := store.Delete(args[0], args[1]); e != nil {
+		return e
+	}
+	logger.Infof(`Snapshot [%s] of profile [%s] deleted`, args[1], args[0])
+	return nil
+}
+
+// runWithProfileStopped stop the loaded profile and execute the snapshot step afterward.
+// Profile is left stopped.
+func runWithProfileStopped(cmd *cobra.Command, stepFn func(client *dockerclient.Client) plan.Executable) error {
+	client, e := plan.NewDockerClient()
+	if e != nil {
+		return e
+	}
+	defer func() { _ = client.Close() }()
+
+	tmpDir := utils.AbsPath(rootcmd.GlobalArgs.TmpDir, rootcmd.GlobalArgs.WorkingDir)
+	planner, e := plan.NewPlanner(rootcmd.LoadedProfile, tmpDir, rootcmd.GlobalArgs.Engine, func(cfg *plan.PlannerConfig) {
+		cfg.NoPrune = true
+	})
+	if e != nil {
+		return e
+	}
+	stop, e := planner.Plan(plan.ActionStop)
+	if e != nil {
+		return e
+	}
+	p := plan.CombineExecutionPlans(stop.Metadata(),
+		plan.NewExecutionPlan(nil, plan.PrintExecutable(fmt.Sprintf(`Stopping [%s] ...`, rootcmd.LoadedProfile.Name))),
+		stop,
+		plan.NewExecutionPlan(nil, stepFn(client)),
+	)
+	if closer, ok := p.(io.Closer); ok {
+		defer func() { _ = closer.Close() }()
+	}
+
+	if rootcmd.GlobalArgs.Verbose {
+		if e := tmplutils.Print(tmpls.OutputTemplate.Lookup("docker_plan.tmpl"), p.Metadata()); e != nil {
+			return e
+		}
+	}
+
+	if e := p.Execute(cmd.Context(), func(opt *plan.ExecOption) {
+		opt.DryRun = Args.DryRun
+		opt.Verbose = rootcmd.GlobalArgs.Verbose
+		opt.Concurrency = rootcmd.GlobalArgs.Concurrency
+		opt.EventSink = rootcmd.NewEventSink()
+	}); e != nil {
+		return e
+	}
+	if !Args.DryRun {
+		logger.Infof(`Profile [%s] is stopped. Use "start %s" to start it again`, rootcmd.LoadedProfile.Name, rootcmd.LoadedProfile.Name)
+	}
+	return nil
+}
+
+// requireProfileAndNameArgs requires profile name and a valid snapshot name as arguments
+func requireProfileAndNameArgs() cobra.PositionalArgs {
+	return func(cmd *cobra.Command, args []string) error {
+		if len(args) != 2 {
+			return errors.New("requires profile name and snapshot name")
+		}
+		if e := rootcmd.RequireProfileArgs()(cmd, args[:1]); e != nil {
+			return e
+		}
+		return snapshot.ValidateName(args[1])
+	}
+}
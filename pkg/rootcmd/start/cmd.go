@@ -9,6 +9,7 @@ import (
 	"github.com/stonedu1011/devenvctl/pkg/tmpls"
 	"github.com/stonedu1011/devenvctl/pkg/utils"
 	"github.com/stonedu1011/devenvctl/pkg/utils/tmplutils"
+	"time"
 )
 
 const (
@@ -17,27 +18,55 @@ const (
 
 var (
 	Cmd = &cobra.Command{
-		Use:                fmt.Sprintf(`%s <profile>`, CommandName),
+		Use:                fmt.Sprintf(`%s <profile> [service...]`, CommandName),
 		Short:              "Start profile",
 		FParseErrWhitelist: cobra.FParseErrWhitelist{UnknownFlags: true},
-		Args:               rootcmd.RequireProfileArgs(),
+		Args:               rootcmd.RequireProfileAndServicesArgs(),
 		PreRunE:            rootcmd.LoadProfileRunE(),
 		RunE:               Run,
 	}
-	Args = Arguments{}
+	Args = Arguments{
+		WaitTimeout: plan.DefaultReadinessTimeout.String(),
+		Rollback:    true,
+	}
 )
 
 type Arguments struct {
-	DryRun bool `flag:"dry-run" desc:"print out commands instead of run them"`
+	DryRun      bool   `flag:"dry-run" desc:"print out commands instead of run them"`
+	NoWait      bool   `flag:"no-wait" desc:"don't wait for services to be ready before post-start hooks"`
+	WaitTimeout string `flag:"wait-timeout" desc:"how long to wait for services to be ready, e.g. 90s, 5m"`
+	Rollback    bool   `flag:"rollback" desc:"undo what has been done (e.g. bring services down) if start fails midway"`
+	HookTimeout string `flag:"hook-timeout" desc:"timeout of each hook, e.g. 90s, 5m. Overrides timeouts defined in profile"`
+	NoPrune     bool   `flag:"no-prune" desc:"skip Docker pruning, regardless of profile's \"prune\" policy"`
+	NoPreflight bool   `flag:"no-preflight" desc:"don't check conflicts of ports, container names, networks and volumes before starting"`
+	NoRollback  bool   `flag:"no-rollback" desc:"leave everything as-is if start fails midway. Takes precedence over --rollback"`
 }
 
 func init() {
 	cmdutils.PersistentFlags(Cmd, &Args)
 }
 
-func Run(cmd *cobra.Command, _ []string) error {
+func Run(cmd *cobra.Command, args []string) error {
 	tmpDir := utils.AbsPath(rootcmd.GlobalArgs.TmpDir, rootcmd.GlobalArgs.WorkingDir)
-	planner := plan.NewDockerComposePlanner(rootcmd.LoadedProfile, tmpDir)
+	waitTimeout, e := time.ParseDuration(Args.WaitTimeout)
+	if e != nil {
+		return fmt.Errorf(`invalid --wait-timeout "%s": %v`, Args.WaitTimeout, e)
+	}
+	hookTimeout, e := rootcmd.ParseOptionalDuration("hook-timeout", Args.HookTimeout)
+	if e != nil {
+		return e
+	}
+	planner, e := plan.NewPlanner(rootcmd.LoadedProfile, tmpDir, rootcmd.GlobalArgs.Engine, func(cfg *plan.PlannerConfig) {
+		cfg.Services = args[1:]
+		cfg.NoWait = Args.NoWait
+		cfg.NoPrune = Args.NoPrune
+		cfg.NoPreflight = Args.NoPreflight
+		cfg.WaitTimeout = waitTimeout
+		cfg.HookTimeout = hookTimeout
+	})
+	if e != nil {
+		return e
+	}
 	p, e := planner.Plan(plan.ActionStart)
 	if e != nil {
 		return e
@@ -52,5 +81,8 @@ func Run(cmd *cobra.Command, _ []string) error {
 	return p.Execute(cmd.Context(), func(opt *plan.ExecOption) {
 		opt.DryRun = Args.DryRun
 		opt.Verbose = rootcmd.GlobalArgs.Verbose
+		opt.Concurrency = rootcmd.GlobalArgs.Concurrency
+		opt.EventSink = rootcmd.NewEventSink()
+		opt.Rollback = Args.Rollback && !Args.NoRollback
 	})
 }
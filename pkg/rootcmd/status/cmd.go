@@ -0,0 +1,63 @@
+package status
+
+import (
+	"embed"
+	"fmt"
+	"github.com/cisco-open/go-lanai/cmd/lanai-cli/cmdutils"
+	"github.com/spf13/cobra"
+	"github.com/stonedu1011/devenvctl/pkg/devenv/plan"
+	"github.com/stonedu1011/devenvctl/pkg/rootcmd"
+	"github.com/stonedu1011/devenvctl/pkg/utils/tmplutils"
+)
+
+const (
+	CommandName = "status"
+)
+
+var (
+	Cmd = &cobra.Command{
+		Use:                fmt.Sprintf(`%s <profile>`, CommandName),
+		Short:              "Show runtime status of profile's services. Exit with error if not all services are running",
+		FParseErrWhitelist: cobra.FParseErrWhitelist{UnknownFlags: true},
+		Args:               rootcmd.RequireProfileArgs(),
+		PreRunE:            rootcmd.LoadProfileQuietlyRunE(),
+		RunE:               Run,
+	}
+	Args = Arguments{}
+)
+
+//go:embed output.tmpl
+var templateFS embed.FS
+
+type Arguments struct {
+}
+
+func init() {
+	cmdutils.PersistentFlags(Cmd, &Args)
+}
+
+func Run(cmd *cobra.Command, _ []string) error {
+	client, e := plan.NewDockerClient()
+	if e != nil {
+		return e
+	}
+	defer func() { _ = client.Close() }()
+
+	status, e := plan.ResolveProfileStatus(cmd.Context(), client, rootcmd.LoadedProfile)
+	if e != nil {
+		return e
+	}
+	if e := tmplutils.PrintFS(templateFS, "output.tmpl", status); e != nil {
+		return e
+	}
+
+	switch running := status.RunningCount(); {
+	case running == len(status.Services):
+		return nil
+	case running == 0:
+		return fmt.Errorf(`profile [%s] is not running`, rootcmd.LoadedProfile.Name)
+	default:
+		return fmt.Errorf(`profile [%s] is partially running: %d/%d services are running`,
+			rootcmd.LoadedProfile.Name, running, len(status.Services))
+	}
+}
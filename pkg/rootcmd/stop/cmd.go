@@ -17,10 +17,10 @@ const (
 
 var (
 	Cmd = &cobra.Command{
-		Use:                fmt.Sprintf(`%s <profile>`, CommandName),
+		Use:                fmt.Sprintf(`%s <profile> [service...]`, CommandName),
 		Short:              "Stop profile",
 		FParseErrWhitelist: cobra.FParseErrWhitelist{UnknownFlags: true},
-		Args:               rootcmd.RequireProfileArgs(),
+		Args:               rootcmd.RequireProfileAndServicesArgs(),
 		PreRunE:            rootcmd.LoadProfileRunE(),
 		RunE:               Run,
 	}
@@ -28,16 +28,29 @@ var (
 )
 
 type Arguments struct {
-	DryRun bool `flag:"dry-run" desc:"print out commands instead of run them"`
+	DryRun      bool   `flag:"dry-run" desc:"print out commands instead of run them"`
+	HookTimeout string `flag:"hook-timeout" desc:"timeout of each hook, e.g. 90s, 5m. Overrides timeouts defined in profile"`
+	NoPrune     bool   `flag:"no-prune" desc:"skip Docker pruning, regardless of profile's \"prune\" policy"`
 }
 
 func init() {
 	cmdutils.PersistentFlags(Cmd, &Args)
 }
 
-func Run(cmd *cobra.Command, _ []string) error {
+func Run(cmd *cobra.Command, args []string) error {
 	tmpDir := utils.AbsPath(rootcmd.GlobalArgs.TmpDir, rootcmd.GlobalArgs.WorkingDir)
-	planner := plan.NewDockerComposePlanner(rootcmd.LoadedProfile, tmpDir)
+	hookTimeout, e := rootcmd.ParseOptionalDuration("hook-timeout", Args.HookTimeout)
+	if e != nil {
+		return e
+	}
+	planner, e := plan.NewPlanner(rootcmd.LoadedProfile, tmpDir, rootcmd.GlobalArgs.Engine, func(cfg *plan.PlannerConfig) {
+		cfg.Services = args[1:]
+		cfg.NoPrune = Args.NoPrune
+		cfg.HookTimeout = hookTimeout
+	})
+	if e != nil {
+		return e
+	}
 	p, e := planner.Plan(plan.ActionStop)
 	if e != nil {
 		return e
@@ -52,5 +65,7 @@ func Run(cmd *cobra.Command, _ []string) error {
 	return p.Execute(cmd.Context(), func(opt *plan.ExecOption) {
 		opt.DryRun = Args.DryRun
 		opt.Verbose = rootcmd.GlobalArgs.Verbose
+		opt.Concurrency = rootcmd.GlobalArgs.Concurrency
+		opt.EventSink = rootcmd.NewEventSink()
 	})
 }
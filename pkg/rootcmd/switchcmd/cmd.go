@@ -0,0 +1,191 @@
+package switchcmd
+
+import (
+	"fmt"
+	"github.com/cisco-open/go-lanai/cmd/lanai-cli/cmdutils"
+	"github.com/cisco-open/go-lanai/pkg/log"
+	"github.com/spf13/cobra"
+	"github.com/stonedu1011/devenvctl/pkg/devenv"
+	"github.com/stonedu1011/devenvctl/pkg/devenv/plan"
+	"github.com/stonedu1011/devenvctl/pkg/rootcmd"
+	"github.com/stonedu1011/devenvctl/pkg/rootcmd/start"
+	"github.com/stonedu1011/devenvctl/pkg/tmpls"
+	"github.com/stonedu1011/devenvctl/pkg/utils"
+	"github.com/stonedu1011/devenvctl/pkg/utils/tmplutils"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+const (
+	CommandName = "switch"
+)
+
+var logger = log.New("CLI")
+
+var (
+	Cmd = &cobra.Command{
+		Use:                fmt.Sprintf(`%s <profile>`, CommandName),
+		Short:              "Stop all running profiles and start specified profile",
+		FParseErrWhitelist: cobra.FParseErrWhitelist{UnknownFlags: true},
+		Args:               rootcmd.RequireProfileArgs(),
+		PreRunE:            rootcmd.LoadProfileRunE(),
+		RunE:               Run,
+	}
+	Args = Arguments{
+		Rollback: true,
+	}
+)
+
+type Arguments struct {
+	DryRun      bool   `flag:"dry-run" desc:"print out commands instead of run them"`
+	HookTimeout string `flag:"hook-timeout" desc:"timeout of each hook, e.g. 90s, 5m. Overrides timeouts defined in profile"`
+	NoPrune     bool   `flag:"no-prune" desc:"skip Docker pruning, regardless of profile's \"prune\" policy"`
+	NoPreflight bool   `flag:"no-preflight" desc:"don't check conflicts of ports, container names, networks and volumes before starting"`
+	Rollback    bool   `flag:"rollback" desc:"undo what has been done (e.g. bring services down) if start fails midway"`
+	NoRollback  bool   `flag:"no-rollback" desc:"leave everything as-is if start fails midway. Takes precedence over --rollback"`
+}
+
+func init() {
+	cmdutils.PersistentFlags(Cmd, &Args)
+}
+
+func Run(cmd *cobra.Command, _ []string) error {
+	tmpDir := utils.AbsPath(rootcmd.GlobalArgs.TmpDir, rootcmd.GlobalArgs.WorkingDir)
+	hookTimeout, e := rootcmd.ParseOptionalDuration("hook-timeout", Args.HookTimeout)
+	if e != nil {
+		return e
+	}
+	running, e := resolveRunningProfiles(cmd)
+	if e != nil {
+		return e
+	}
+
+	// stop plans. Note: each profile need its own working directory, because plans are prepared before execution
+	plans := make([]plan.ExecutionPlan, 0, len(running)*2+2)
+	for _, p := range running {
+		wd := filepath.Join(tmpDir, p.Name)
+		if e := os.MkdirAll(wd, 0755); e != nil {
+			return fmt.Errorf(`unable to create directory [%s]: %v`, wd, e)
+		}
+		planner, e := plan.NewPlanner(p, wd, rootcmd.GlobalArgs.Engine, func(cfg *plan.PlannerConfig) {
+			cfg.NoPrune = true
+			cfg.HookTimeout = hookTimeout
+		})
+		if e != nil {
+			return e
+		}
+		stop, e := planner.Plan(plan.ActionStop)
+		if e != nil {
+			return e
+		}
+		plans = append(plans,
+			plan.NewExecutionPlan(nil, plan.PrintExecutable(fmt.Sprintf(`Stopping [%s] ...`, p.Name))),
+			stop,
+		)
+	}
+
+	// start plan
+	wd := filepath.Join(tmpDir, rootcmd.LoadedProfile.Name)
+	if e := os.MkdirAll(wd, 0755); e != nil {
+		return fmt.Errorf(`unable to create directory [%s]: %v`, wd, e)
+	}
+	planner, e := plan.NewPlanner(rootcmd.LoadedProfile, wd, rootcmd.GlobalArgs.Engine, func(cfg *plan.PlannerConfig) {
+		cfg.NoPrune = Args.NoPrune
+		cfg.NoPreflight = Args.NoPreflight
+		cfg.HookTimeout = hookTimeout
+	})
+	if e != nil {
+		return e
+	}
+	start, e := planner.Plan(plan.ActionStart)
+	if e != nil {
+		return e
+	}
+	plans = append(plans,
+		plan.NewExecutionPlan(nil, plan.PrintExecutable(fmt.Sprintf(`Starting [%s] ...`, rootcmd.LoadedProfile.Name))),
+		start,
+	)
+
+	p := plan.CombineExecutionPlans(start.Metadata(), plans...)
+	if closer, ok := p.(io.Closer); ok {
+		defer func() { _ = closer.Close() }()
+	}
+
+	if rootcmd.GlobalArgs.Verbose {
+		if e := tmplutils.Print(tmpls.OutputTemplate.Lookup("docker_plan.tmpl"), p.Metadata()); e != nil {
+			return e
+		}
+	}
+
+	e = p.Execute(cmd.Context(), func(opt *plan.ExecOption) {
+		opt.DryRun = Args.DryRun
+		opt.Verbose = rootcmd.GlobalArgs.Verbose
+		opt.Concurrency = rootcmd.GlobalArgs.Concurrency
+		opt.EventSink = rootcmd.NewEventSink()
+		opt.Rollback = Args.Rollback && !Args.NoRollback
+	})
+	if e != nil && !Args.DryRun {
+		logStoppedProfiles(cmd, running)
+	}
+	return e
+}
+
+// logStoppedProfiles tell user which profiles might have been stopped by a failed switch, and how to bring them back
+func logStoppedProfiles(cmd *cobra.Command, running []*devenv.Profile) {
+	for _, p := range running {
+		if p.Name == rootcmd.LoadedProfile.Name {
+			continue
+		}
+		logger.Warnf(`Profile [%s] was running before switching and might have been stopped. To bring it back: %s %s %s`,
+			p.Name, cmd.Root().Name(), start.CommandName, p.Name)
+	}
+}
+
+// resolveRunningProfiles find known profiles that have running containers
+func resolveRunningProfiles(cmd *cobra.Command) ([]*devenv.Profile, error) {
+	client, e := plan.NewDockerClient()
+	if e != nil {
+		return nil, e
+	}
+	defer func() { _ = client.Close() }()
+	projects, e := plan.ListRunningComposeProjects(cmd.Context(), client)
+	if e != nil {
+		return nil, fmt.Errorf(`unable to find running profiles: %v`, e)
+	}
+
+	profiles, e := rootcmd.SearchProfiles()
+	if e != nil {
+		return nil, e
+	}
+	byProject := map[string]*devenv.ProfileMetadata{}
+	for _, meta := range profiles {
+		byProject[plan.ComposeProjectName(meta.Name)] = meta
+	}
+
+	running := make([]*devenv.Profile, 0, len(projects))
+	for _, project := range projects {
+		meta, ok := byProject[project]
+		if !ok {
+			logger.Debugf(`Ignoring docker compose project [%s]: not a known profile`, project)
+			continue
+		}
+		p, e := devenv.LoadProfile(meta, devenv.WithProfiles(profiles))
+		if e != nil {
+			return nil, fmt.Errorf(`unable to load running profile [%s]: %v`, meta.Name, e)
+		}
+		running = append(running, p)
+	}
+	sort.SliceStable(running, func(i, j int) bool { return running[i].Name < running[j].Name })
+	if len(running) == 0 {
+		logger.Infof(`No running profiles found`)
+	} else {
+		names := make([]string, len(running))
+		for i := range running {
+			names[i] = running[i].Name
+		}
+		logger.Infof(`Running profiles: %v`, names)
+	}
+	return running, nil
+}
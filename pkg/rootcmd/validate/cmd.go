@@ -0,0 +1,116 @@
+package validate
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"github.com/cisco-open/go-lanai/cmd/lanai-cli/cmdutils"
+	"github.com/spf13/cobra"
+	"github.com/stonedu1011/devenvctl/pkg/devenv"
+	"github.com/stonedu1011/devenvctl/pkg/devenv/plan"
+	"github.com/stonedu1011/devenvctl/pkg/rootcmd"
+	"github.com/stonedu1011/devenvctl/pkg/utils"
+	"github.com/stonedu1011/devenvctl/pkg/utils/tmplutils"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+const (
+	CommandName = "validate"
+	validateDir = `validate`
+)
+
+var (
+	Cmd = &cobra.Command{
+		Use:   fmt.Sprintf(`%s [profile...]`, CommandName),
+		Short: "Check profile definitions without running them. Exit with error if any problem is found",
+		Long: `Check profile definitions without running them. All available profiles are checked if none is given. Docker is not required.
+Definition files are checked strictly: unknown keys and values of wrong kind are reported. The docker compose file is rendered and checked against the profile:
+services and container hooks should be services in the compose file, variables referenced in the compose file should be defined,
+script hooks should exist in the resource directory, and mounts should be used by bind volumes.`,
+		FParseErrWhitelist: cobra.FParseErrWhitelist{UnknownFlags: true},
+		Args:               requireKnownProfiles,
+		RunE:               Run,
+	}
+	Args = Arguments{}
+)
+
+//go:embed output.tmpl
+var templateFS embed.FS
+
+type Arguments struct {
+}
+
+type Result struct {
+	Name     string
+	Problems devenv.Problems
+}
+
+func init() {
+	cmdutils.PersistentFlags(Cmd, &Args)
+}
+
+func requireKnownProfiles(_ *cobra.Command, args []string) error {
+	profiles, e := rootcmd.SearchProfiles()
+	if e != nil {
+		return e
+	}
+	for _, name := range args {
+		if _, ok := profiles[name]; !ok {
+			return fmt.Errorf(`unknown profile [%s]`, name)
+		}
+	}
+	return nil
+}
+
+func Run(_ *cobra.Command, args []string) error {
+	names := args
+	if len(names) == 0 {
+		for name := range rootcmd.Profiles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+	overrides, e := devenv.ParseVariableOverrides(rootcmd.GlobalArgs.Variables)
+	if e != nil {
+		return e
+	}
+
+	results := make([]Result, 0, len(names))
+	var count, failed int
+	for _, name := range names {
+		problems := validate(rootcmd.Profiles[name], overrides)
+		results = append(results, Result{Name: name, Problems: problems})
+		if len(problems) != 0 {
+			count += len(problems)
+			failed++
+		}
+	}
+	if e := tmplutils.PrintFS(templateFS, "output.tmpl", map[string]interface{}{
+		"Results": results,
+	}); e != nil {
+		return e
+	}
+	if failed != 0 {
+		return fmt.Errorf(`%d problem(s) found in %d profile(s)`, count, failed)
+	}
+	return nil
+}
+
+func validate(meta *devenv.ProfileMetadata, overrides map[string]string) devenv.Problems {
+	p, e := devenv.LoadProfile(meta, devenv.WithProfiles(rootcmd.Profiles), devenv.WithVariables(overrides), devenv.WithStrict())
+	if e != nil {
+		var problems devenv.Problems
+		if errors.As(e, &problems) {
+			return problems
+		}
+		return devenv.Problems{{Position: devenv.Position{Path: meta.DisplayPath}, Message: e.Error()}}
+	}
+	tmpDir := utils.AbsPath(rootcmd.GlobalArgs.TmpDir, rootcmd.GlobalArgs.WorkingDir)
+	dir := filepath.Join(tmpDir, validateDir, meta.Name)
+	if e := os.MkdirAll(dir, 0755); e != nil {
+		return devenv.Problems{{Position: devenv.Position{Path: meta.DisplayPath}, Message: fmt.Sprintf(`unable to create directory [%s]: %v`, dir, e)}}
+	}
+	return plan.ValidateProfile(p, dir)
+}
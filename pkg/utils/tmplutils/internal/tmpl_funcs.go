@@ -1,9 +1,16 @@
 package internal
 
 import (
+	"bytes"
 	"encoding"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"gopkg.in/yaml.v3"
 	"math"
+	"os"
+	"reflect"
+	"sort"
 	"strconv"
 	"strings"
 	"text/template"
@@ -16,6 +23,38 @@ var (
 		"cap":   Capped,
 		"pad":   Padding,
 		"join":  Join,
+		// values
+		"default":  DefaultValue,
+		"required": Required,
+		"empty":    IsEmpty,
+		"coalesce": Coalesce,
+		"env":      os.Getenv,
+		// encoding
+		"toYaml": ToYaml,
+		"toJson": ToJson,
+		"quote":  Quote,
+		"squote": SingleQuote,
+		// strings
+		"indent":     Indent,
+		"nindent":    NewLineIndent,
+		"upper":      strings.ToUpper,
+		"lower":      strings.ToLower,
+		"trim":       strings.TrimSpace,
+		"trimPrefix": func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+		"trimSuffix": func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+		"replace":    func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+		"contains":   func(substr, s string) bool { return strings.Contains(s, substr) },
+		"hasPrefix":  func(prefix, s string) bool { return strings.HasPrefix(s, prefix) },
+		"hasSuffix":  func(suffix, s string) bool { return strings.HasSuffix(s, suffix) },
+		"split":      func(sep, s string) []string { return strings.Split(s, sep) },
+		// lists and dicts
+		"list":      func(values ...interface{}) []interface{} { return values },
+		"dict":      Dict,
+		"has":       Has,
+		"first":     First,
+		"last":      Last,
+		"uniq":      Uniq,
+		"sortAlpha": SortAlpha,
 	}
 )
 
@@ -45,9 +84,10 @@ func Capped(cap int, v interface{}) string {
 	}
 }
 
+// Join join non-empty values with given separator. Lists are flattened, e.g. `{{join "," .List}}`
 func Join(sep string, values ...interface{}) string {
 	strs := make([]string, 0, len(values))
-	for _, v := range values {
+	for _, v := range flatten(values) {
 		s := Sprint(v)
 		if s != "" {
 			strs = append(strs, s)
@@ -75,3 +115,202 @@ func Sprint(val interface{}) string {
 	return fmt.Sprintf("%v", val)
 }
 
+
+// DefaultValue returns given value if not empty, otherwise the default value. e.g. `{{.Vars.PORT | default "8080"}}`
+func DefaultValue(def interface{}, v ...interface{}) interface{} {
+	if len(v) == 0 || IsEmpty(v[0]) {
+		return def
+	}
+	return v[0]
+}
+
+// Required fails rendering with given message if the value is empty. e.g. `{{required "PORT is required" .Vars.PORT}}`
+func Required(msg string, v interface{}) (interface{}, error) {
+	if IsEmpty(v) {
+		return nil, errors.New(msg)
+	}
+	return v, nil
+}
+
+// Coalesce returns the first non-empty value
+func Coalesce(values ...interface{}) interface{} {
+	for _, v := range values {
+		if !IsEmpty(v) {
+			return v
+		}
+	}
+	return nil
+}
+
+// IsEmpty returns true if given value is nil, zero value, or an empty string/slice/map
+func IsEmpty(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return rv.Len() == 0
+	case reflect.Pointer, reflect.Interface:
+		return rv.IsNil()
+	default:
+		return rv.IsZero()
+	}
+}
+
+// ToYaml encode given value as YAML without trailing newline. Usually used with nindent, e.g. `{{.Environment | toYaml | nindent 6}}`
+// The value is converted to JSON compatible types first, so json tags and json.Marshaler are respected
+func ToYaml(v interface{}) (string, error) {
+	data, e := json.Marshal(v)
+	if e != nil {
+		return "", e
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var generic interface{}
+	if e := dec.Decode(&generic); e != nil {
+		return "", e
+	}
+	out, e := yaml.Marshal(yamlNumbers(generic))
+	if e != nil {
+		return "", e
+	}
+	return strings.TrimSuffix(string(out), "\n"), nil
+}
+
+// yamlNumbers replace json.Number in decoded JSON with int64 or float64, so integers are not encoded in exponent form
+func yamlNumbers(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k := range val {
+			val[k] = yamlNumbers(val[k])
+		}
+	case []interface{}:
+		for i := range val {
+			val[i] = yamlNumbers(val[i])
+		}
+	case json.Number:
+		if i, e := val.Int64(); e == nil {
+			return i
+		}
+		f, _ := val.Float64()
+		return f
+	}
+	return v
+}
+
+// ToJson encode given value as compact JSON
+func ToJson(v interface{}) (string, error) {
+	data, e := json.Marshal(v)
+	if e != nil {
+		return "", e
+	}
+	return string(data), nil
+}
+
+// Quote returns double-quoted string of given value, with special characters escaped
+func Quote(v interface{}) string {
+	return strconv.Quote(Sprint(v))
+}
+
+// SingleQuote returns single-quoted string of given value. Single quotes within the value are escaped as in YAML
+func SingleQuote(v interface{}) string {
+	return "'" + strings.ReplaceAll(Sprint(v), "'", "''") + "'"
+}
+
+// Indent add given number of spaces in front of every line
+func Indent(spaces int, v interface{}) string {
+	pad := strings.Repeat(" ", spaces)
+	return pad + strings.ReplaceAll(Sprint(v), "\n", "\n"+pad)
+}
+
+// NewLineIndent same as Indent, with a leading newline. e.g. `key: {{.Value | toYaml | nindent 2}}`
+func NewLineIndent(spaces int, v interface{}) string {
+	return "\n" + Indent(spaces, v)
+}
+
+// Dict create a map from key-value pairs. e.g. `{{template "partial" dict "name" .Name "port" 8080}}`
+func Dict(kvs ...interface{}) (map[string]interface{}, error) {
+	if len(kvs)%2 != 0 {
+		return nil, fmt.Errorf(`dict expects even number of arguments, but got %d`, len(kvs))
+	}
+	ret := make(map[string]interface{}, len(kvs)/2)
+	for i := 0; i < len(kvs); i += 2 {
+		ret[Sprint(kvs[i])] = kvs[i+1]
+	}
+	return ret, nil
+}
+
+// Has returns true if the list contains given value. e.g. `{{if has "consul" .Names}}`
+func Has(needle interface{}, list interface{}) bool {
+	for _, v := range flatten([]interface{}{list}) {
+		if reflect.DeepEqual(v, needle) {
+			return true
+		}
+	}
+	return false
+}
+
+// First returns the first element of a list, or nil if empty
+func First(list interface{}) interface{} {
+	values := flatten([]interface{}{list})
+	if len(values) == 0 {
+		return nil
+	}
+	return values[0]
+}
+
+// Last returns the last element of a list, or nil if empty
+func Last(list interface{}) interface{} {
+	values := flatten([]interface{}{list})
+	if len(values) == 0 {
+		return nil
+	}
+	return values[len(values)-1]
+}
+
+// Uniq returns elements of a list without duplicates, in their original order
+func Uniq(list interface{}) []interface{} {
+	values := flatten([]interface{}{list})
+	ret := make([]interface{}, 0, len(values))
+	for _, v := range values {
+		if !Has(v, ret) {
+			ret = append(ret, v)
+		}
+	}
+	return ret
+}
+
+// SortAlpha returns elements of a list, or keys of a map, as sorted strings
+func SortAlpha(list interface{}) []string {
+	var values []interface{}
+	if rv := reflect.ValueOf(list); rv.Kind() == reflect.Map {
+		for _, k := range rv.MapKeys() {
+			values = append(values, k.Interface())
+		}
+	} else {
+		values = flatten([]interface{}{list})
+	}
+	ret := make([]string, len(values))
+	for i := range values {
+		ret[i] = Sprint(values[i])
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// flatten expand slices and arrays in given values, except []byte
+func flatten(values []interface{}) []interface{} {
+	ret := make([]interface{}, 0, len(values))
+	for _, v := range values {
+		rv := reflect.ValueOf(v)
+		if _, isBytes := v.([]byte); isBytes || (rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array) {
+			ret = append(ret, v)
+			continue
+		}
+		for i := 0; i < rv.Len(); i++ {
+			ret = append(ret, rv.Index(i).Interface())
+		}
+	}
+	return ret
+}
@@ -21,11 +21,22 @@ type tmplType interface {
 	*template.Template | string | []byte
 }
 
+// NewTemplate create a template with all available functions. See internal.TmplFuncMap.
+// In addition, `{{include "name" .}}` renders a named template into a string, so that its output can be piped, e.g. to "nindent"
 func NewTemplate() *template.Template {
-	return template.New("template").
+	t := template.New("template").
 		Option("missingkey=zero").
 		Funcs(internal.TmplFuncMap).
 		Funcs(internal.TmplColorFuncMap)
+	return t.Funcs(template.FuncMap{
+		"include": func(name string, data interface{}) (string, error) {
+			var buf bytes.Buffer
+			if e := t.ExecuteTemplate(&buf, name, data); e != nil {
+				return "", e
+			}
+			return buf.String(), nil
+		},
+	})
 }
 
 func Parse(tmplText string) (*template.Template, error) {